@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==================== JSON 接口采集源 ====================
+//
+// kind=api 的采集源不回放浏览器轨迹，而是直接调用站点的后台搜索接口。
+// 配置保存在 sources.config 中，例如：
+//
+//	{
+//	  "url": "http://www.ccgp-shandong.gov.cn/api/search?kw={{.Keyword}}&page={{.Page}}&size={{.PageSize}}",
+//	  "method": "GET",
+//	  "items_path": "data.records",
+//	  "fields": {
+//	    "title": "title | trim",
+//	    "url":   "http://www.ccgp-shandong.gov.cn/#/detail?id={{.id}}",
+//	    "date":  "publishTime | date"
+//	  },
+//	  "max_pages": 3
+//	}
+//
+// 请求模板参数：{{.Keyword}}、{{.Page}}、{{.PageSize}}、{{.Offset}}、{{.StartDate}}、{{.EndDate}}。
+// 字段映射的值是相对于单条记录的路径表达式（a.b[0].c），也可以是包含 {{.路径}} 的模板（数组下标写作 a.b.0.c），
+// 两者都可以接选择器处理器（| trim、| regex:...、| date、| abs）。
+
+// SourceKind 采集源类型
+const (
+	SourceKindTrace = "trace"
+	SourceKindAPI   = "api"
+)
+
+// APISourceConfig JSON 接口采集源配置
+type APISourceConfig struct {
+	URL           string            `json:"url"`             // 请求地址模板
+	Method        string            `json:"method"`          // GET（默认）/POST
+	Headers       map[string]string `json:"headers"`         // 额外请求头
+	Body          string            `json:"body"`            // POST 请求体模板（JSON 或表单）
+	ItemsPath     string            `json:"items_path"`      // 响应中记录数组的路径，空表示响应本身
+	Fields        map[string]string `json:"fields"`          // Tender 字段 → 路径表达式/模板
+	PageStart     int               `json:"page_start"`      // 起始页码（默认 1）
+	PageSize      int               `json:"page_size"`       // 每页条数（默认 20）
+	MaxPages      int               `json:"max_pages"`       // 每个关键词最多请求页数（默认 1）
+	DateRangeDays int               `json:"date_range_days"` // StartDate 距今天数（默认 30）
+	DateFormat    string            `json:"date_format"`     // StartDate/EndDate 格式（默认 2006-01-02）
+}
+
+// parseAPISourceConfig 解析并校验接口配置
+func parseAPISourceConfig(raw string) (*APISourceConfig, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, fmt.Errorf("接口采集源缺少配置")
+	}
+	var cfg APISourceConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return nil, fmt.Errorf("解析接口配置失败: %v", err)
+	}
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("接口配置缺少 url")
+	}
+	if cfg.Fields["title"] == "" || cfg.Fields["url"] == "" {
+		return nil, fmt.Errorf("接口配置的 fields 必须包含 title 和 url")
+	}
+
+	cfg.Method = strings.ToUpper(cfg.Method)
+	if cfg.Method == "" {
+		cfg.Method = "GET"
+	}
+	if cfg.PageStart <= 0 {
+		cfg.PageStart = 1
+	}
+	if cfg.PageSize <= 0 {
+		cfg.PageSize = 20
+	}
+	if cfg.MaxPages <= 0 {
+		cfg.MaxPages = 1
+	}
+	if cfg.DateRangeDays <= 0 {
+		cfg.DateRangeDays = 30
+	}
+	if cfg.DateFormat == "" {
+		cfg.DateFormat = "2006-01-02"
+	}
+	return &cfg, nil
+}
+
+func init() {
+	registerKindAdapter(SourceKindAPI, newAPIAdapter)
+}
+
+// apiAdapter 调用 JSON 搜索接口的适配器
+type apiAdapter struct {
+	env *adapterEnv
+	cfg *APISourceConfig
+}
+
+func newAPIAdapter(env *adapterEnv) (SourceAdapter, error) {
+	cfg, err := parseAPISourceConfig(env.Source.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &apiAdapter{env: env, cfg: cfg}, nil
+}
+
+func (a *apiAdapter) Search(ctx context.Context, keyword string) (ListIterator, error) {
+	return &apiIterator{adapter: a, keyword: keyword, page: a.cfg.PageStart}, nil
+}
+
+func (a *apiAdapter) Detail(ctx context.Context, item map[string]string) (map[string]string, error) {
+	return a.env.runDetailTrace(ctx, item)
+}
+
+// apiIterator 按需翻页的接口结果迭代器，增量采集提前停止时不再请求后续页
+type apiIterator struct {
+	adapter *apiAdapter
+	keyword string
+	page    int
+	buffer  []map[string]string
+	done    bool
+}
+
+func (it *apiIterator) Next(ctx context.Context) (map[string]string, error) {
+	for len(it.buffer) == 0 {
+		if it.done {
+			return nil, io.EOF
+		}
+		if err := it.fetch(ctx); err != nil {
+			return nil, err
+		}
+	}
+	item := it.buffer[0]
+	it.buffer = it.buffer[1:]
+	return item, nil
+}
+
+// fetch 请求下一页并填充缓冲区
+func (it *apiIterator) fetch(ctx context.Context) error {
+	cfg := it.adapter.cfg
+	first := it.page == cfg.PageStart
+	if !first {
+		if err := politeDelay(ctx, it.adapter.env.Limits); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	params := map[string]string{
+		"Keyword":   it.keyword,
+		"Page":      strconv.Itoa(it.page),
+		"PageSize":  strconv.Itoa(cfg.PageSize),
+		"Offset":    strconv.Itoa((it.page - cfg.PageStart) * cfg.PageSize),
+		"StartDate": now.AddDate(0, 0, -cfg.DateRangeDays).Format(cfg.DateFormat),
+		"EndDate":   now.Format(cfg.DateFormat),
+	}
+
+	data, requestURL, err := fetchAPIPage(ctx, cfg, params)
+	if err != nil {
+		if !first && ctx.Err() == nil {
+			log.Printf("⚠️ 第 %d 页请求失败，停止翻页: %v", it.page, err)
+			it.done = true
+			return nil
+		}
+		return err
+	}
+
+	records, ok := jsonPath(data, cfg.ItemsPath).([]interface{})
+	if !ok {
+		return fmt.Errorf("响应中未找到记录数组: %s", cfg.ItemsPath)
+	}
+	log.Printf("📡 接口第 %d 页返回 %d 条记录", it.page, len(records))
+
+	baseURL := it.adapter.env.Source.BaseURL
+	if baseURL == "" {
+		baseURL = requestURL
+	}
+	for _, record := range records {
+		item := mapAPIRecord(record, cfg.Fields, baseURL)
+		if item["title"] == "" || item["url"] == "" {
+			log.Printf("  跳过无效数据: title=%s, url=%s", item["title"], item["url"])
+			continue
+		}
+		it.buffer = append(it.buffer, item)
+	}
+
+	it.page++
+	if len(records) < cfg.PageSize || it.page >= cfg.PageStart+cfg.MaxPages {
+		it.done = true // 最后一页
+	}
+	return nil
+}
+
+// fetchAPIPage 发送一次接口请求并解析 JSON 响应
+func fetchAPIPage(ctx context.Context, cfg *APISourceConfig, params map[string]string) (interface{}, string, error) {
+	urlParams := make(map[string]string, len(params))
+	for k, v := range params {
+		urlParams[k] = url.QueryEscape(v)
+	}
+	requestURL := replaceParams(cfg.URL, urlParams)
+
+	var body io.Reader
+	contentType := ""
+	if cfg.Body != "" {
+		trimmed := strings.TrimSpace(cfg.Body)
+		bodyParams := urlParams
+		contentType = "application/x-www-form-urlencoded"
+		if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			bodyParams = make(map[string]string, len(params))
+			for k, v := range params {
+				quoted, _ := json.Marshal(v)
+				bodyParams[k] = string(quoted[1 : len(quoted)-1])
+			}
+			contentType = "application/json"
+		}
+		body = strings.NewReader(replaceParams(cfg.Body, bodyParams))
+	}
+
+	release, err := beginRequest(ctx, requestURL)
+	if err != nil {
+		return nil, requestURL, err
+	}
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, cfg.Method, requestURL, body)
+	if err != nil {
+		return nil, requestURL, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("User-Agent", userAgentFrom(ctx))
+	req.Header.Set("Accept", "application/json, text/plain, */*")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := doProxiedRequest(ctx, req)
+	if err != nil {
+		return nil, requestURL, fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, requestURL, fmt.Errorf("读取响应失败: %v", err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, requestURL, fmt.Errorf("请求失败: HTTP %d", resp.StatusCode)
+	}
+
+	// UseNumber 避免长整型 ID 被转成浮点数
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return nil, requestURL, fmt.Errorf("解析JSON响应失败: %v", err)
+	}
+	return data, requestURL, nil
+}
+
+// mapAPIRecord 按字段映射将一条接口记录转换为列表项
+func mapAPIRecord(record interface{}, fields map[string]string, baseURL string) map[string]string {
+	flat := make(map[string]string)
+	flattenJSON("", record, flat)
+
+	item := make(map[string]string)
+	for field, expr := range fields {
+		parts := splitTopLevel(expr, '|')
+		spec := strings.TrimSpace(parts[0])
+		var filters []string
+		for _, f := range parts[1:] {
+			if f = strings.TrimSpace(f); f != "" {
+				filters = append(filters, f)
+			}
+		}
+
+		var value string
+		if strings.Contains(spec, "{{") {
+			value = replaceParams(spec, flat)
+		} else {
+			value = jsonString(jsonPath(record, spec))
+		}
+		item[field] = strings.TrimSpace(applySelectorFilters(value, filters, baseURL))
+	}
+
+	if item["url"] != "" {
+		item["url"] = normalizeURL(item["url"], baseURL, nil)
+	}
+	return item
+}
+
+// jsonPath 按路径表达式取值，支持 a.b.c、a[0].b 和以 $ 开头的写法
+func jsonPath(data interface{}, path string) interface{} {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	path = strings.ReplaceAll(strings.ReplaceAll(path, "[", "."), "]", "")
+
+	current := data
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			continue
+		}
+		switch v := current.(type) {
+		case map[string]interface{}:
+			current = v[key]
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return nil
+			}
+			current = v[idx]
+		default:
+			return nil
+		}
+	}
+	return current
+}
+
+// jsonString 将 JSON 值转换为字符串，对象和数组保留为 JSON
+func jsonString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		data, _ := json.Marshal(val)
+		return string(data)
+	}
+}
+
+// flattenJSON 将记录展开为 a.b[0].c → a.b.0.c 形式的键，供模板替换使用
+func flattenJSON(prefix string, v interface{}, out map[string]string) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			flattenJSON(key, child, out)
+		}
+	case []interface{}:
+		for i, child := range val {
+			flattenJSON(fmt.Sprintf("%s.%d", prefix, i), child, out)
+		}
+	default:
+		if prefix != "" {
+			out[prefix] = jsonString(val)
+		}
+	}
+}
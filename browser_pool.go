@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// ==================== 浏览器池 ====================
+//
+// 浏览器进程长期运行，任务通过租约使用：
+//   - 默认（incognito）：共享浏览器进程，每个任务一个隐身上下文，任务间 Cookie 互不影响
+//   - profile：每个采集源独占一个持久化用户目录（data/browser-profiles/<代码>），保留登录状态
+//
+// 共享浏览器按代理区分（代理是进程启动参数），单个浏览器同时租给的任务数不超过
+// BROWSER_MAX_TASKS（一个任务可能打开多个标签页），满员时启动新进程，总数不超过 BROWSER_POOL_SIZE，再满则排队等待。
+// 健康检查定时探测浏览器，崩溃的进程被移除，下次租用时重新启动。
+
+const (
+	BrowserModeIncognito = "incognito"
+	BrowserModeProfile   = "profile"
+
+	browserHealthInterval = 30 * time.Second
+)
+
+var (
+	browserPoolSize    = getEnvInt("BROWSER_POOL_SIZE", 2)
+	browserMaxTasks    = getEnvInt("BROWSER_MAX_TASKS", getEnvInt("BROWSER_MAX_PAGES", 5)) // BROWSER_MAX_PAGES 为旧名称
+	browserIdleTimeout = time.Duration(getEnvInt("BROWSER_IDLE_MINUTES", 10)) * time.Minute
+
+	profileNameSanitizer = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+)
+
+// getEnvInt 读取整数环境变量，无效时使用默认值
+func getEnvInt(key string, defaultValue int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return defaultValue
+}
+
+// pooledBrowser 池中的浏览器进程
+type pooledBrowser struct {
+	id         int
+	key        string // 共享浏览器为代理地址，独占浏览器为 profile:<代码>
+	profile    string // 持久化用户目录，共享浏览器为空
+	proxy      string
+	browser    *rod.Browser
+	launcher   *launcher.Launcher
+	leases     int
+	retired    bool // 不再分配新租约，空闲后关闭
+	launchedAt time.Time
+	lastUsed   time.Time
+}
+
+// BrowserPool 浏览器池
+type BrowserPool struct {
+	mu       sync.Mutex
+	browsers []*pooledBrowser
+	released chan struct{} // 租约释放或浏览器启动结束时关闭，唤醒等待者
+	nextID   int
+	waiting  int
+
+	launched int64
+	crashed  int64
+	leased   int64
+}
+
+// BrowserPoolStats 浏览器池指标
+type BrowserPoolStats struct {
+	Browsers     int                   `json:"browsers"`
+	ActiveLeases int                   `json:"active_leases"`
+	Waiting      int                   `json:"waiting"`
+	MaxBrowsers  int                   `json:"max_browsers"`
+	MaxTasks     int                   `json:"max_tasks_per_browser"`
+	Launched     int64                 `json:"launched_total"`
+	Crashed      int64                 `json:"crashed_total"`
+	Leased       int64                 `json:"leased_total"`
+	Instances    []BrowserInstanceStat `json:"instances"`
+}
+
+// BrowserInstanceStat 单个浏览器进程的状态
+type BrowserInstanceStat struct {
+	ID       int    `json:"id"`
+	Profile  string `json:"profile,omitempty"`
+	Proxy    string `json:"proxy,omitempty"`
+	Leases   int    `json:"leases"`
+	UptimeS  int    `json:"uptime_seconds"`
+	IdleS    int    `json:"idle_seconds"`
+	Retiring bool   `json:"retiring,omitempty"`
+}
+
+// browserLease 任务对浏览器的租约
+type browserLease struct {
+	pool      *BrowserPool
+	entry     *pooledBrowser
+	browser   *rod.Browser // 隐身上下文或独占浏览器本身
+	incognito bool
+	once      sync.Once
+}
+
+var (
+	browserPool     *BrowserPool
+	browserPoolOnce sync.Once
+)
+
+// getBrowserPool 获取全局浏览器池，首次使用时启动健康检查
+func getBrowserPool() *BrowserPool {
+	browserPoolOnce.Do(func() {
+		browserPool = &BrowserPool{released: make(chan struct{})}
+		go browserPool.healthLoop()
+	})
+	return browserPool
+}
+
+// profileDirFor 采集源的持久化用户目录
+func profileDirFor(code string) string {
+	return filepath.Join(dataDir, "browser-profiles", profileNameSanitizer.ReplaceAllString(code, "_"))
+}
+
+// Acquire 租用浏览器：profile 非空时使用该采集源的独占浏览器，否则在共享浏览器中创建隐身上下文
+func (p *BrowserPool) Acquire(ctx context.Context, proxyURL, profile string) (*browserLease, error) {
+	for {
+		p.mu.Lock()
+		entry, launch := p.pick(proxyURL, profile)
+		if entry != nil {
+			entry.leases++
+			entry.lastUsed = time.Now()
+			p.leased++
+			p.mu.Unlock()
+			return p.newLease(entry)
+		}
+		if launch {
+			// 先占位，避免并发任务重复启动
+			p.nextID++
+			entry = &pooledBrowser{id: p.nextID, key: poolKey(proxyURL, profile), profile: profile, proxy: proxyURL, leases: 1}
+			p.browsers = append(p.browsers, entry)
+			p.leased++
+			p.mu.Unlock()
+
+			err := p.launch(entry)
+			p.mu.Lock()
+			if err != nil {
+				p.remove(entry)
+			}
+			// 启动期间等待同一用户目录或池位的任务重新选择
+			p.broadcast()
+			p.mu.Unlock()
+			if err != nil {
+				return nil, err
+			}
+			return p.newLease(entry)
+		}
+
+		released := p.released
+		p.waiting++
+		p.mu.Unlock()
+		log.Printf("⏳ 浏览器池已满，等待空闲浏览器")
+		select {
+		case <-released:
+		case <-ctx.Done():
+		}
+		p.mu.Lock()
+		p.waiting--
+		p.mu.Unlock()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func poolKey(proxyURL, profile string) string {
+	if profile != "" {
+		return "profile:" + profile
+	}
+	return proxyURL
+}
+
+// pick 在持有锁时选择可用的浏览器，没有可用浏览器时返回是否可以启动新进程
+func (p *BrowserPool) pick(proxyURL, profile string) (*pooledBrowser, bool) {
+	key := poolKey(proxyURL, profile)
+	var best *pooledBrowser
+	for _, b := range p.browsers {
+		if b.key != key || b.retired || b.browser == nil {
+			continue
+		}
+		if profile != "" {
+			// 用户目录同时只能被一个进程使用：代理变化且空闲时重启，否则沿用原代理
+			if b.proxy != proxyURL && b.leases == 0 {
+				b.retired = true
+				continue
+			}
+			return b, false
+		}
+		if b.leases < browserMaxTasks && (best == nil || b.leases < best.leases) {
+			best = b
+		}
+	}
+	if best != nil {
+		return best, false
+	}
+
+	for _, b := range p.browsers {
+		// 同一用户目录的进程正在启动或等待关闭
+		if profile != "" && b.key == key {
+			if b.retired && b.leases == 0 {
+				p.closeEntry(b)
+				break
+			}
+			return nil, false
+		}
+	}
+	if len(p.browsers) < browserPoolSize {
+		return nil, true
+	}
+	// 已满时关闭一个空闲浏览器腾出位置
+	for _, b := range p.browsers {
+		if b.leases == 0 && b.browser != nil {
+			p.closeEntry(b)
+			return nil, true
+		}
+	}
+	return nil, false
+}
+
+// launch 启动浏览器进程（不持有锁）
+func (p *BrowserPool) launch(entry *pooledBrowser) error {
+	browser, l, err := setupBrowser(entry.proxy, entry.profile)
+	if err != nil {
+		return err
+	}
+	if entry.profile != "" {
+		l = nil
+	}
+	p.mu.Lock()
+	entry.browser, entry.launcher = browser, l
+	entry.launchedAt, entry.lastUsed = time.Now(), time.Now()
+	p.launched++
+	count := len(p.browsers)
+	p.mu.Unlock()
+	log.Printf("✅ 浏览器池启动浏览器 #%d（当前 %d 个）", entry.id, count)
+	return nil
+}
+
+// newLease 为租约创建隐身上下文
+func (p *BrowserPool) newLease(entry *pooledBrowser) (*browserLease, error) {
+	lease := &browserLease{pool: p, entry: entry, browser: entry.browser}
+	if entry.profile != "" {
+		return lease, nil
+	}
+	incognito, err := entry.browser.Incognito()
+	if err != nil {
+		lease.Release(true)
+		return nil, fmt.Errorf("创建隐身上下文失败: %v", err)
+	}
+	lease.browser, lease.incognito = incognito, true
+	return lease, nil
+}
+
+// Browser 租约可用的浏览器
+func (l *browserLease) Browser() *rod.Browser {
+	return l.browser
+}
+
+// Proxy 租约浏览器实际使用的代理
+func (l *browserLease) Proxy() string {
+	return l.entry.proxy
+}
+
+// Release 归还租约；retire 为 true 时该浏览器不再分配新任务，空闲后关闭
+func (l *browserLease) Release(retire bool) {
+	l.once.Do(func() {
+		if l.incognito {
+			l.browser.Close() // 关闭隐身上下文
+		}
+		p := l.pool
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		l.entry.leases--
+		l.entry.lastUsed = time.Now()
+		if retire {
+			l.entry.retired = true
+		}
+		if l.entry.retired && l.entry.leases == 0 {
+			p.closeEntry(l.entry)
+		}
+		p.broadcast()
+	})
+}
+
+// broadcast 唤醒所有等待租用的任务（持有锁时调用）
+func (p *BrowserPool) broadcast() {
+	close(p.released)
+	p.released = make(chan struct{})
+}
+
+// closeEntry 关闭浏览器并移出池（持有锁时调用）
+func (p *BrowserPool) closeEntry(b *pooledBrowser) {
+	p.remove(b)
+	if b.browser != nil {
+		go closeBrowserProcess(b.browser, b.launcher)
+		b.browser, b.launcher = nil, nil
+	}
+}
+
+// closeBrowserProcess 关闭浏览器进程；共享浏览器的临时用户目录一并删除（独占浏览器不设置 launcher，保留用户目录）
+func closeBrowserProcess(browser *rod.Browser, l *launcher.Launcher) {
+	browser.Close()
+	if l != nil {
+		l.Cleanup()
+	}
+}
+
+func (p *BrowserPool) remove(b *pooledBrowser) {
+	for i, x := range p.browsers {
+		if x == b {
+			p.browsers = append(p.browsers[:i], p.browsers[i+1:]...)
+			break
+		}
+	}
+}
+
+// healthLoop 定时探测浏览器：移除崩溃的进程，关闭长时间空闲的进程
+func (p *BrowserPool) healthLoop() {
+	ticker := time.NewTicker(browserHealthInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		p.checkHealth()
+	}
+}
+
+func (p *BrowserPool) checkHealth() {
+	p.mu.Lock()
+	browsers := append([]*pooledBrowser(nil), p.browsers...)
+	p.mu.Unlock()
+
+	for _, b := range browsers {
+		if b.browser == nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		_, err := proto.BrowserGetVersion{}.Call(b.browser.Context(ctx))
+		cancel()
+
+		p.mu.Lock()
+		switch {
+		case err != nil:
+			log.Printf("💥 浏览器 #%d 无响应，已移除，下次使用时重启: %v", b.id, err)
+			p.crashed++
+			p.closeEntry(b)
+			p.broadcast()
+		case b.leases == 0 && time.Since(b.lastUsed) > browserIdleTimeout:
+			log.Printf("💤 浏览器 #%d 空闲超过 %v，已关闭", b.id, browserIdleTimeout)
+			p.closeEntry(b)
+		}
+		p.mu.Unlock()
+	}
+}
+
+// Stats 浏览器池指标
+func (p *BrowserPool) Stats() BrowserPoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := BrowserPoolStats{
+		Browsers: len(p.browsers), Waiting: p.waiting,
+		MaxBrowsers: browserPoolSize, MaxTasks: browserMaxTasks,
+		Launched: p.launched, Crashed: p.crashed, Leased: p.leased,
+		Instances: []BrowserInstanceStat{},
+	}
+	for _, b := range p.browsers {
+		stats.ActiveLeases += b.leases
+		stat := BrowserInstanceStat{ID: b.id, Leases: b.leases, Retiring: b.retired}
+		if b.profile != "" {
+			stat.Profile = filepath.Base(b.profile)
+		}
+		if b.proxy != "" {
+			stat.Proxy = maskProxyURL(b.proxy)
+		}
+		if !b.launchedAt.IsZero() {
+			stat.UptimeS = int(time.Since(b.launchedAt).Seconds())
+			stat.IdleS = int(time.Since(b.lastUsed).Seconds())
+		}
+		stats.Instances = append(stats.Instances, stat)
+	}
+	return stats
+}
+
+// Close 关闭池中所有浏览器
+func (p *BrowserPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, b := range append([]*pooledBrowser(nil), p.browsers...) {
+		p.remove(b)
+		if b.browser != nil {
+			closeBrowserProcess(b.browser, b.launcher)
+		}
+	}
+}
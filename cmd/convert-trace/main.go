@@ -1,604 +1,164 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"time"
-)
-
-type ChromeRecording struct {
-	Title string       `json:"title"`
-	URL   string       `json:"url"`
-	Steps []ChromeStep `json:"steps"`
-}
-
-type ChromeStep struct {
-	Type           string          `json:"type"`
-	URL            string          `json:"url,omitempty"`
-	Selectors      [][]string      `json:"selectors,omitempty"`
-	Value          string          `json:"value,omitempty"`
-	Target         string          `json:"target,omitempty"`
-	Key            string          `json:"key,omitempty"`
-	AssertedEvents []AssertedEvent `json:"assertedEvents,omitempty"`
-}
 
-type AssertedEvent struct {
-	Type  string `json:"type"`
-	URL   string `json:"url"`
-	Title string `json:"title"`
-}
-
-type SimplifiedTrace struct {
-	Name  string      `json:"name"`
-	Type  string      `json:"type"`
-	URL   string      `json:"url"`
-	Steps []TraceStep `json:"steps"`
-}
-
-type TraceStep struct {
-	Action         string            `json:"action"`
-	URL            string            `json:"url,omitempty"`
-	Selector       string            `json:"selector,omitempty"`
-	Value          string            `json:"value,omitempty"`
-	ImageSelector  string            `json:"image_selector,omitempty"`
-	InputSelector  string            `json:"input_selector,omitempty"`
-	Type           string            `json:"type,omitempty"`
-	Fields         map[string]string `json:"fields,omitempty"`
-	WaitTime       int               `json:"wait_time,omitempty"`
-	WaitForVisible string            `json:"wait_for_visible,omitempty"`
-	Pagination     *PaginationConfig `json:"pagination,omitempty"`
-}
-
-type PaginationConfig struct {
-	Selector   string `json:"selector"`
-	NextButton string `json:"next_button"`
-	MaxPages   int    `json:"max_pages"`
-	MaxItems   int    `json:"max_items"`
-}
-
-type IntermediateStep struct {
-	Type     string
-	Selector string
-	Value    string
-	URL      string
-	Target   string
-}
-
-type ListFieldInfo struct {
-	TitleSelector string
-	URLSelector   string
-	DateSelector  string
-	HasLink       bool
-}
+	"tender-monitor/traceconv"
+)
 
-func convertChromeRecording(input string, traceType string) (*SimplifiedTrace, error) {
+// convertRecording 读取录制文件并转换，自动识别 Chrome DevTools、Selenium IDE（.side）、
+// Playwright、Puppeteer 格式，转换规则与服务端上传一致（见 traceconv 包）
+func convertRecording(input string, traceType string) (*traceconv.TraceFile, string, error) {
 	data, err := os.ReadFile(input)
 	if err != nil {
-		return nil, fmt.Errorf("读取文件失败: %v", err)
-	}
-
-	var recording ChromeRecording
-	if err := json.Unmarshal(data, &recording); err != nil {
-		return nil, fmt.Errorf("解析JSON失败: %v", err)
+		return nil, "", fmt.Errorf("读取文件失败: %v", err)
 	}
 
-	trace := &SimplifiedTrace{
-		Name:  recording.Title,
-		Type:  traceType,
-		URL:   recording.URL,
-		Steps: []TraceStep{},
-	}
-
-	var intermediate []IntermediateStep
-	pendingChanges := make(map[string]string)
-	var pageNavigated bool
-	var listFieldInfo ListFieldInfo
-	var listFieldCaptured bool
-	var paginationSelector string
-
-	flushAllPendingChanges := func() {
-		for selector, value := range pendingChanges {
-			if value != "" {
-				intermediate = append(intermediate, IntermediateStep{
-					Type:     "change",
-					Selector: selector,
-					Value:    value,
-				})
-			}
-		}
-		pendingChanges = make(map[string]string)
-	}
-
-	// 分析步骤，检测翻页和列表点击
-	analyzeSteps := recording.Steps
-
-	for i, step := range analyzeSteps {
-		// 检测翻页控件
-		if step.Type == "click" {
-			selector := extractSelector(step.Selectors)
-			if isPaginationClick(selector) {
-				paginationSelector = selector
-				continue
-			}
-		}
-
-		// 检测列表行点击（可能导致页面跳转）
-		if step.Type == "click" && i < len(analyzeSteps)-1 {
-			nextStep := analyzeSteps[i+1]
-			// 如果下一步是 navigate，说明当前点击导致了页面跳转
-			if nextStep.Type == "navigate" {
-				selector := extractSelector(step.Selectors)
-				if isListItemClick(selector) {
-					if !listFieldCaptured {
-						listFieldInfo = parseListClickSelectors(step.Selectors)
-						listFieldCaptured = true
-					}
-					// 记录这个是列表项点击，会导致跳转
-					intermediate = append(intermediate, IntermediateStep{
-						Type:     "listItemClick",
-						Selector: selector,
-						URL:      nextStep.URL,
-					})
-					continue
-				}
-			}
-		}
-	}
-
-	// 重新处理所有步骤
-	for _, step := range recording.Steps {
-		switch step.Type {
-		case "setViewport", "keyUp", "keyDown", "scroll":
-			continue
-
-		case "navigate":
-			trace.URL = step.URL
-			intermediate = append(intermediate, IntermediateStep{
-				Type: "navigate",
-				URL:  step.URL,
-			})
-
-		case "click":
-			if pageNavigated {
-				continue
-			}
-			selector := extractSelector(step.Selectors)
-			if selector == "" {
-				continue
-			}
-
-			// 跳过翻页按钮，不加入轨迹
-			if isPaginationClick(selector) {
-				continue
-			}
-
-			flushAllPendingChanges()
-
-			if len(intermediate) > 0 {
-				last := intermediate[len(intermediate)-1]
-				if last.Type == "click" && last.Selector == selector {
-					continue
-				}
-			}
-
-			// 检测点击列表行的情况
-			if traceType == "list" && isListNavigationClick(selector) {
-				if !listFieldCaptured {
-					listFieldInfo = parseListClickSelectors(step.Selectors)
-					listFieldCaptured = true
-				}
-				pageNavigated = true
-				continue
-			}
-
-			// 跳过列表项点击（已在上文处理）
-			if isListItemClick(selector) {
-				pageNavigated = true
-				continue
-			}
-
-			if isInputFieldClick(selector, pendingChanges) {
-				continue
-			}
-
-			intermediate = append(intermediate, IntermediateStep{
-				Type:     "click",
-				Selector: selector,
-				Target:   step.Target,
-			})
-
-		case "change":
-			selector := extractSelector(step.Selectors)
-			if selector == "" {
-				continue
-			}
-			pendingChanges[selector] = step.Value
-
-		case "waitForElement":
-			flushAllPendingChanges()
-			selector := extractSelector(step.Selectors)
-			if selector != "" {
-				intermediate = append(intermediate, IntermediateStep{
-					Type:     "waitForElement",
-					Selector: selector,
-				})
-			}
-		}
+	recording, format, err := traceconv.ParseAnyRecording(data)
+	if err != nil {
+		return nil, "", err
 	}
 
-	flushAllPendingChanges()
-
-	trace.Steps = buildFinalSteps(intermediate, traceType, listFieldInfo, listFieldCaptured, paginationSelector)
-
-	return trace, nil
+	return traceconv.Convert(recording, traceType), format, nil
 }
 
-func buildFinalSteps(intermediate []IntermediateStep, traceType string, listFieldInfo ListFieldInfo, hasListFieldInfo bool, paginationSelector string) []TraceStep {
-	var result []TraceStep
-
-	for i, step := range intermediate {
-		switch step.Type {
-		case "navigate", "listItemClick":
-			result = append(result, TraceStep{
-				Action: "navigate",
-				URL:    step.URL,
-			})
-			result = append(result, TraceStep{
-				Action:   "wait",
-				WaitTime: 2000,
-			})
-
-		case "click":
-			result = append(result, TraceStep{
-				Action:   "click",
-				Selector: step.Selector,
-			})
-			waitTime := 500
-			if isSearchButton(step.Selector) {
-				waitTime = 3000
-			}
-			result = append(result, TraceStep{
-				Action:   "wait",
-				WaitTime: waitTime,
-			})
-
-		case "change":
-			value := step.Value
-			if isKeywordInput(step.Selector) {
-				value = "{{.Keyword}}"
-			}
-
-			if isCaptchaInput(step.Selector, step.Value) {
-				prevClick := findPrevClick(intermediate, i)
-				result = append(result, TraceStep{
-					Action:        "captcha",
-					ImageSelector: prevClick,
-					InputSelector: step.Selector,
-				})
-			} else {
-				result = append(result, TraceStep{
-					Action:   "input",
-					Selector: step.Selector,
-					Value:    value,
-				})
-			}
-
-		case "waitForElement":
-			result = append(result, TraceStep{
-				Action:         "wait",
-				WaitForVisible: step.Selector,
-			})
-		}
+func backupFile(filePath string) error {
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return nil
 	}
 
-	if traceType == "list" {
-		fields := map[string]string{
-			"title": "td:nth-child(1) span",
-			"date":  "td:nth-child(3)",
-			"url":   "td:nth-child(1) span",
-		}
-		if hasListFieldInfo {
-			fields = map[string]string{
-				"title": listFieldInfo.TitleSelector,
-				"date":  listFieldInfo.DateSelector,
-				"url":   listFieldInfo.URLSelector,
-			}
-		}
-
-		// 检测列表容器选择器
-		listSelector := detectListContainer(intermediate)
-
-		extractStep := TraceStep{
-			Action:   "extract",
-			Type:     "list",
-			Selector: listSelector,
-			Fields:   fields,
-		}
-
-		// 添加翻页配置
-		if paginationSelector != "" {
-			extractStep.Pagination = &PaginationConfig{
-				Selector:   paginationSelector,
-				NextButton: paginationSelector,
-				MaxPages:   10,
-				MaxItems:   100,
-			}
-		}
+	timestamp := time.Now().Format("20060102_150405")
+	ext := filepath.Ext(filePath)
+	base := filePath[:len(filePath)-len(ext)]
+	backupPath := fmt.Sprintf("%s_%s%s", base, timestamp, ext)
 
-		result = append(result, extractStep)
-	} else if traceType == "detail" {
-		result = append(result, TraceStep{
-			Action: "extract",
-			Type:   "detail",
-			Fields: map[string]string{
-				"amount":  "span:contains('预算金额')",
-				"contact": "span:contains('联系人')",
-				"phone":   "span:contains('联系电话')",
-			},
-		})
+	if err := os.Rename(filePath, backupPath); err != nil {
+		return err
 	}
 
-	return result
+	fmt.Printf("📦 备份: %s\n", backupPath)
+	return nil
 }
 
-func findPrevClick(steps []IntermediateStep, currentIndex int) string {
-	for i := currentIndex - 1; i >= 0; i-- {
-		if steps[i].Type == "click" {
-			return steps[i].Selector
+// lintFiles 校验轨迹文件（轨迹格式或可导入的录制），有错误时返回 false
+func lintFiles(files []string) bool {
+	ok := true
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Printf("❌ %s: 读取文件失败: %v\n", file, err)
+			ok = false
+			continue
 		}
-	}
-	return "img[src*='captcha']"
-}
-
-func extractSelector(selectors [][]string) string {
-	if len(selectors) == 0 {
-		return ""
-	}
-
-	for _, selectorGroup := range selectors {
-		if len(selectorGroup) > 0 {
-			s := selectorGroup[0]
-			if !strings.HasPrefix(s, "aria/") && !strings.HasPrefix(s, "text/") && !strings.HasPrefix(s, "pierce/") {
-				return s
-			}
+		_, issues, err := traceconv.LintJSON(data)
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", file, err)
+			ok = false
+			continue
 		}
-	}
-
-	for _, selectorGroup := range selectors {
-		if len(selectorGroup) > 0 {
-			s := selectorGroup[0]
-			if strings.HasPrefix(s, "pierce/") {
-				return strings.TrimPrefix(s, "pierce/")
-			}
+		if len(issues) == 0 {
+			fmt.Printf("✅ %s: 未发现问题\n", file)
+			continue
 		}
-	}
-
-	for _, selectorGroup := range selectors {
-		if len(selectorGroup) > 0 {
-			return selectorGroup[0]
+		fmt.Printf("%s:\n", file)
+		for _, issue := range issues {
+			fmt.Printf("   %s\n", issue)
 		}
-	}
-
-	return ""
-}
-
-func isKeywordInput(selector string) bool {
-	keywords := []string{"标题", "关键词", "keyword", "title"}
-	selectorLower := strings.ToLower(selector)
-	for _, kw := range keywords {
-		if strings.Contains(selectorLower, kw) {
-			return true
+		if traceconv.HasErrors(issues) {
+			ok = false
 		}
 	}
-	return false
+	return ok
 }
 
-func isInputFieldClick(selector string, pendingChanges map[string]string) bool {
-	if strings.Contains(selector, "input") || strings.Contains(selector, "[role=\"textbox\"]") {
-		return true
+// exportFile 把轨迹文件导出为 Chrome DevTools Recorder 录制，keyword 用于替换 {{.Keyword}}
+func exportFile(input, output, keyword string) error {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return fmt.Errorf("读取文件失败: %v", err)
 	}
-	return false
-}
-
-func detectListContainer(intermediate []IntermediateStep) string {
-	for _, step := range intermediate {
-		if step.Type == "click" && step.Selector != "" {
-			selector := step.Selector
-			if strings.Contains(selector, "li:nth-of-type") {
-				return "li"
-			}
-			if strings.Contains(selector, "tr:nth-of-type") {
-				return "tr"
-			}
-			if strings.Contains(selector, "div:nth-of-type") {
-				return "div"
-			}
-		}
+	trace, _, err := traceconv.LintJSON(data)
+	if err != nil {
+		return err
 	}
-	return "tbody tr"
-}
 
-func isListNavigationClick(selector string) bool {
-	return strings.Contains(selector, "tr:nth-of-type") ||
-		strings.Contains(selector, "tbody tr") ||
-		strings.Contains(selector, "td.el-table")
-}
+	recording, warnings := traceconv.ToRecording(trace, func(s string) string {
+		return strings.ReplaceAll(s, "{{.Keyword}}", keyword)
+	})
 
-func isListItemClick(selector string) bool {
-	if selector == "" {
-		return false
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(recording); err != nil {
+		return fmt.Errorf("生成JSON失败: %v", err)
 	}
-	// 检测常见的列表项选择器模式
-	listPatterns := []string{
-		"li:nth-of-type", "li: nth-of-type",
-		"tr:nth-of-type", "tbody tr",
-		"div:nth-of-type", ".item", ".list-item",
-		"a[href", "span",
+	os.MkdirAll(filepath.Dir(output), 0755)
+	if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("保存文件失败: %v", err)
 	}
-	for _, p := range listPatterns {
-		if strings.Contains(selector, p) {
-			return true
-		}
-	}
-	return false
-}
 
-func isPaginationClick(selector string) bool {
-	if selector == "" {
-		return false
-	}
-	pagePatterns := []string{
-		"page", "pager", "pagination",
-		"next", "prev", "previous",
-		"下页", "上页", "下一页", "上一页",
-		"第", "页",
-		"a:nth-of-type", "li:nth-of-type",
-	}
-	selectorLower := strings.ToLower(selector)
-	for _, p := range pagePatterns {
-		if strings.Contains(selectorLower, p) {
-			return true
+	fmt.Println("✅ 导出成功，可在 Chrome DevTools Recorder 中导入回放")
+	fmt.Printf("   输入: %s\n", input)
+	fmt.Printf("   输出: %s\n", output)
+	fmt.Printf("   步骤数: %d\n", len(recording.Steps))
+	if len(warnings) > 0 {
+		fmt.Println("\n⚠️  以下步骤无法完整导出:")
+		for _, warning := range warnings {
+			fmt.Printf("   %s\n", warning)
 		}
 	}
-	// 检测数字结尾的选择器，如 "li:nth-of-type(2)"
-	matched, _ := regexp.MatchString(`.*\((\d+|last)\)$`, selector)
-	return matched
-}
-
-func isSearchButton(selector string) bool {
-	return strings.Contains(selector, "button") &&
-		(strings.Contains(selector, "primary") ||
-			strings.Contains(selector, "search") ||
-			strings.Contains(selector, "查询"))
+	return nil
 }
 
-func isCaptchaInput(selector string, value string) bool {
-	return strings.Contains(selector, "验证码") || (len(value) == 4 && !strings.Contains(value, " "))
+func printUsage() {
+	fmt.Println("用法:")
+	fmt.Println("  go run ./cmd/convert-trace <输入文件> <类型:list|detail> <输出文件>")
+	fmt.Println("  go run ./cmd/convert-trace lint <轨迹文件>...")
+	fmt.Println("  go run ./cmd/convert-trace export <轨迹文件> <输出文件> [关键词]")
+	fmt.Println("\n示例:")
+	fmt.Println("  go run ./cmd/convert-trace recording.json list traces/shandong_list.json")
+	fmt.Println("  go run ./cmd/convert-trace search.side list traces/shandong_list.json")
+	fmt.Println("  go run ./cmd/convert-trace search.spec.ts list traces/shandong_list.json")
+	fmt.Println("  go run ./cmd/convert-trace lint traces/*.json")
+	fmt.Println("  go run ./cmd/convert-trace export traces/shandong_list.json recording.json 电梯")
 }
 
-// parseListClickSelectors 从列表行点击的选择器中解析字段选择器
-func parseListClickSelectors(selectors [][]string) ListFieldInfo {
-	info := ListFieldInfo{
-		TitleSelector: "td:nth-child(1) span",
-		URLSelector:   "td:nth-child(1) a",
-		DateSelector:  "td:nth-child(3)",
-		HasLink:       false,
-	}
-
-	for _, selectorGroup := range selectors {
-		for _, s := range selectorGroup {
-			// 从 xpath 中解析结构
-			if strings.HasPrefix(s, "xpath//") {
-				info = parseXPathForFields(s)
-				break
-			}
-			// 从 CSS 选择器中解析
-			if strings.Contains(s, "td.el-table") || strings.Contains(s, "tr:nth-of-type") {
-				info = parseCSSForFields(s)
-			}
+func main() {
+	if len(os.Args) >= 2 && os.Args[1] == "lint" {
+		if len(os.Args) < 3 {
+			printUsage()
+			os.Exit(1)
 		}
-	}
-
-	return info
-}
-
-// parseXPathForFields 从 xpath 解析字段选择器
-func parseXPathForFields(xpath string) ListFieldInfo {
-	info := ListFieldInfo{
-		TitleSelector: "td:nth-child(1) span",
-		URLSelector:   "@click:td:nth-child(1) span",
-		DateSelector:  "td:nth-child(3)",
-		HasLink:       false,
-	}
-
-	// 示例: xpath///*[@id="app"]/.../tbody/tr[2]/td[1]/div/span
-	// 解析 td[n] 获取列索引
-	tdIdx := 1
-	if idx := strings.Index(xpath, "/td["); idx != -1 {
-		rest := xpath[idx+4:]
-		if end := strings.Index(rest, "]"); end != -1 {
-			fmt.Sscanf(rest[:end], "%d", &tdIdx)
+		if !lintFiles(os.Args[2:]) {
+			os.Exit(1)
 		}
+		return
 	}
-
-	// 解析 td 后的子元素结构
-	if idx := strings.Index(xpath, "/td["); idx != -1 {
-		rest := xpath[idx:]
-		if end := strings.Index(rest, "/a"); end != -1 {
-			info.HasLink = true
+	if len(os.Args) >= 2 && os.Args[1] == "export" {
+		if len(os.Args) < 4 {
+			printUsage()
+			os.Exit(1)
 		}
-	}
-
-	// 生成选择器
-	info.TitleSelector = fmt.Sprintf("td:nth-child(%d) span", tdIdx)
-	if info.HasLink {
-		info.URLSelector = fmt.Sprintf("td:nth-child(%d) a", tdIdx)
-	} else {
-		// Vue SPA 没有 a 标签，通过点击元素获取跳转URL
-		info.URLSelector = fmt.Sprintf("@click:td:nth-child(%d) span", tdIdx)
-	}
-
-	return info
-}
-
-// parseCSSForFields 从 CSS 选择器解析字段选择器
-func parseCSSForFields(css string) ListFieldInfo {
-	info := ListFieldInfo{
-		TitleSelector: "td:nth-child(1) span",
-		URLSelector:   "@click:td:nth-child(1) span",
-		DateSelector:  "td:nth-child(3)",
-		HasLink:       false,
-	}
-
-	colNum := 1
-	// 示例: tr:nth-of-type(2) > td.el-table_1_column_1 span
-	// 解析 td.el-table_n_column_m
-	if strings.Contains(css, "td.el-table") {
-		if idx := strings.Index(css, "el-table_"); idx != -1 {
-			rest := css[idx+9:]
-			if n, err := fmt.Sscanf(rest, "%d", &colNum); n == 1 && err == nil {
-				info.TitleSelector = fmt.Sprintf("td:nth-child(%d) span", colNum)
-				info.URLSelector = fmt.Sprintf("@click:td:nth-child(%d) span", colNum)
-			}
+		keyword := ""
+		if len(os.Args) >= 5 {
+			keyword = os.Args[4]
 		}
+		if err := exportFile(os.Args[2], os.Args[3], keyword); err != nil {
+			fmt.Printf("❌ 导出失败: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	// 检查是否包含 a 标签
-	if strings.Contains(css, " a") || strings.Contains(css, ">a") {
-		info.HasLink = true
-		info.URLSelector = fmt.Sprintf("td:nth-child(%d) a", colNum)
-	}
-
-	return info
-}
-
-func backupFile(filePath string) error {
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return nil
-	}
-
-	timestamp := time.Now().Format("20060102_150405")
-	ext := filepath.Ext(filePath)
-	base := filePath[:len(filePath)-len(ext)]
-	backupPath := fmt.Sprintf("%s_%s%s", base, timestamp, ext)
-
-	if err := os.Rename(filePath, backupPath); err != nil {
-		return err
-	}
-
-	fmt.Printf("📦 备份: %s\n", backupPath)
-	return nil
-}
-
-func main() {
 	if len(os.Args) < 4 {
-		fmt.Println("用法: go run convert_trace.go <输入文件> <类型:list|detail> <输出文件>")
-		fmt.Println("\n示例:")
-		fmt.Println("  go run convert_trace.go recording.json list traces/shandong_list.json")
+		printUsage()
 		os.Exit(1)
 	}
 
@@ -611,17 +171,21 @@ func main() {
 		os.Exit(1)
 	}
 
-	trace, err := convertChromeRecording(inputFile, traceType)
+	trace, format, err := convertRecording(inputFile, traceType)
 	if err != nil {
 		fmt.Printf("❌ 转换失败: %v\n", err)
 		os.Exit(1)
 	}
 
-	output, err := json.MarshalIndent(trace, "", "  ")
-	if err != nil {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(trace); err != nil {
 		fmt.Printf("❌ 生成JSON失败: %v\n", err)
 		os.Exit(1)
 	}
+	output := buf.Bytes()
 
 	os.MkdirAll(filepath.Dir(outputFile), 0755)
 
@@ -636,13 +200,22 @@ func main() {
 	}
 
 	fmt.Println("✅ 转换成功")
-	fmt.Printf("   输入: %s\n", inputFile)
+	fmt.Printf("   输入: %s (%s)\n", inputFile, format)
 	fmt.Printf("   输出: %s\n", outputFile)
 	fmt.Printf("   类型: %s\n", traceType)
 	fmt.Printf("   步骤数: %d\n", len(trace.Steps))
+	if issues := traceconv.Lint(trace); len(issues) > 0 {
+		fmt.Println("\n🔍 校验结果:")
+		for _, issue := range issues {
+			fmt.Printf("   %s\n", issue)
+		}
+	}
 	fmt.Println("\n⚠️  请手动检查并调整以下内容:")
 	fmt.Println("   1. 验证码图片选择器 (image_selector)")
 	fmt.Println("   2. 列表行选择器 (selector)")
 	fmt.Println("   3. 字段提取选择器 (fields)")
 	fmt.Println("   4. 等待时间和条件")
+	if n := len(trace.Steps); n > 0 && trace.Steps[n-1].Pagination != nil {
+		fmt.Println("   5. 翻页按钮 (pagination.next_button)")
+	}
 }
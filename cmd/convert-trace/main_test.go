@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestConvertMatchesGolden 命令行转换结果与 traceconv 的 golden 文件一致；
+// 服务端上传路径（parseTraceFile）由根目录的测试对照同一组 golden 文件，两条路径因此保持一致
+func TestConvertMatchesGolden(t *testing.T) {
+	testdata := filepath.Join("..", "..", "traceconv", "testdata")
+	files, err := filepath.Glob(filepath.Join(testdata, "*.recording.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("未找到录制文件: %v", err)
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".recording.json")
+		t.Run(name, func(t *testing.T) {
+			trace, _, err := convertRecording(file, "")
+			if err != nil {
+				t.Fatalf("命令行转换失败: %v", err)
+			}
+			got, err := json.MarshalIndent(trace, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want, err := os.ReadFile(filepath.Join(testdata, name+".golden.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got)+"\n" != string(want) {
+				t.Errorf("命令行转换结果与 golden 不一致:\n%s", got)
+			}
+		})
+	}
+}
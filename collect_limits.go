@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"time"
+)
+
+// ==================== 采集限制 ====================
+
+// CollectLimits 生效的采集数量与礼貌延迟限制（数量为 0 表示不限制）
+type CollectLimits struct {
+	MaxItems      int `json:"max_items"`       // 每个关键词最多提取的列表条数
+	MaxDetails    int `json:"max_details"`     // 每个任务最多抓取的详情页数
+	DelayMs       int `json:"delay_ms"`        // 两次详情抓取之间的间隔（毫秒）
+	DelayJitterMs int `json:"delay_jitter_ms"` // 间隔的随机抖动上限（毫秒）
+
+	// 增量采集：跳过已入库的招标信息，连续遇到已知条目时停止当前关键词
+	Incremental    bool `json:"incremental"`
+	RecheckHours   int  `json:"recheck_hours"`    // 已知条目超过该时长未检查则重新抓取详情（0=不重新抓取）
+	StopAfterKnown int  `json:"stop_after_known"` // 连续遇到多少条已知条目后停止（0=不停止）
+}
+
+// defaultCollectLimits 未配置时使用的默认限制
+var defaultCollectLimits = CollectLimits{
+	MaxItems:      10,
+	MaxDetails:    0, // 不限制
+	DelayMs:       2000,
+	DelayJitterMs: 1000,
+
+	StopAfterKnown: 5,
+}
+
+// LimitOverrides 覆盖采集限制的配置，nil 表示未设置，显式的 0/false 也会覆盖
+type LimitOverrides struct {
+	MaxItems       *int  `json:"max_items,omitempty"`
+	MaxDetails     *int  `json:"max_details,omitempty"`
+	DelayMs        *int  `json:"delay_ms,omitempty"`
+	DelayJitterMs  *int  `json:"delay_jitter_ms,omitempty"`
+	Incremental    *bool `json:"incremental,omitempty"`
+	RecheckHours   *int  `json:"recheck_hours,omitempty"`
+	StopAfterKnown *int  `json:"stop_after_known,omitempty"`
+}
+
+// merge 用 override 中已设置的值覆盖当前限制
+func (l CollectLimits) merge(override LimitOverrides) CollectLimits {
+	setInt := func(dst *int, v *int) {
+		if v != nil && *v >= 0 {
+			*dst = *v
+		}
+	}
+	setInt(&l.MaxItems, override.MaxItems)
+	setInt(&l.MaxDetails, override.MaxDetails)
+	setInt(&l.DelayMs, override.DelayMs)
+	setInt(&l.DelayJitterMs, override.DelayJitterMs)
+	if override.Incremental != nil {
+		l.Incremental = *override.Incremental
+	}
+	setInt(&l.RecheckHours, override.RecheckHours)
+	setInt(&l.StopAfterKnown, override.StopAfterKnown)
+	return l
+}
+
+// JSON 序列化，用于记录到任务
+func (o LimitOverrides) JSON() string {
+	data, _ := json.Marshal(o)
+	return string(data)
+}
+
+// positiveInt 采集源配置中 0 表示使用默认值，只有正数才作为覆盖值
+func positiveInt(v int) *int {
+	if v <= 0 {
+		return nil
+	}
+	return &v
+}
+
+// JSON 序列化，用于记录到任务
+func (l CollectLimits) JSON() string {
+	data, _ := json.Marshal(l)
+	return string(data)
+}
+
+// resolveCollectLimits 计算生效的限制：默认值 < 采集源 < 列表轨迹 < 任务请求
+func resolveCollectLimits(source *Source, listTrace *TraceFile, override LimitOverrides) CollectLimits {
+	limits := defaultCollectLimits
+	if source != nil {
+		limits = limits.merge(source.Limits())
+	}
+	if listTrace != nil {
+		for _, step := range listTrace.Steps {
+			if step.Action == "extract" && step.MaxItems > 0 {
+				limits.MaxItems = step.MaxItems
+			}
+		}
+	}
+	return limits.merge(override)
+}
+
+// withListLimit 返回设置了条数上限的轨迹副本，不修改原轨迹
+func withListLimit(trace *TraceFile, maxItems int) *TraceFile {
+	if trace == nil {
+		return nil
+	}
+	copied := *trace
+	copied.Steps = make([]TraceStep, len(trace.Steps))
+	copy(copied.Steps, trace.Steps)
+	for i := range copied.Steps {
+		if copied.Steps[i].Action == "extract" && copied.Steps[i].Type == "list" {
+			copied.Steps[i].MaxItems = maxItems
+		}
+	}
+	return &copied
+}
+
+// politeDelay 按配置等待一段带抖动的时间，任务取消时提前返回
+func politeDelay(ctx context.Context, limits CollectLimits) error {
+	delay := time.Duration(limits.DelayMs) * time.Millisecond
+	if limits.DelayJitterMs > 0 {
+		delay += time.Duration(rand.Intn(limits.DelayJitterMs+1)) * time.Millisecond
+	}
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// ==================== 统一采集流程 ====================
+
+// collectSource 采集单个采集源：适用于所有适配器，统一处理进度、取消、关键词匹配、增量判断和入库。
+// taskID 为空时不更新任务进度（批量模式、命令行模式）。
+func collectSource(ctx context.Context, taskID string, sourceID int, keywords []string, override LimitOverrides) error {
+	report := func(updates map[string]interface{}) {
+		if taskID != "" {
+			updateCollectTask(taskID, updates)
+		}
+	}
+
+	source, err := getSourceByID(sourceID)
+	if err != nil {
+		return fmt.Errorf("获取采集源失败: %v", err)
+	}
+
+	log.Printf("🚀 开始采集任务：采集源=%s, 关键词=%v", source.Name, keywords)
+	ctx = sourceContext(ctx, source)
+	probe := &extractProbe{}
+	ctx = withExtractProbe(ctx, probe)
+	report(map[string]interface{}{
+		"progress": 10,
+		"message":  fmt.Sprintf("正在准备采集 %s", source.Name),
+	})
+
+	env := &adapterEnv{
+		Source: source,
+		Solver: NewCaptchaSolver(captchaService),
+		ctx:    ctx,
+	}
+	defer env.Close()
+
+	// 记录本次使用的轨迹版本，便于排查轨迹更新引起的问题
+	traceVersions := map[string]int{}
+	var version int
+	if sourceKind(source) == SourceKindTrace {
+		if env.ListTrace, version = loadSourceTrace(source, "list"); env.ListTrace != nil {
+			traceVersions["list"] = version
+		}
+	}
+	if env.DetailTrace, version = loadSourceTrace(source, "detail"); env.DetailTrace != nil {
+		traceVersions["detail"] = version
+	} else {
+		log.Printf("⚠️ 未找到详情轨迹，仅采集列表信息")
+	}
+	if env.LoginTrace, version = loadSourceTrace(source, "login"); env.LoginTrace != nil {
+		traceVersions["login"] = version
+		// 录制格式转换时类型按页面推断，这里统一标记为登录轨迹
+		env.LoginTrace.Type = "login"
+	}
+	if len(traceVersions) > 0 {
+		data, _ := json.Marshal(traceVersions)
+		report(map[string]interface{}{"trace_versions": string(data)})
+	}
+	if env.credential, err = getSourceCredential(sourceID); err != nil {
+		return fmt.Errorf("读取登录凭据失败: %v", err)
+	}
+
+	env.Limits = resolveCollectLimits(source, env.ListTrace, override)
+	limits := env.Limits
+	log.Printf("📏 采集限制: %s", limits.JSON())
+	report(map[string]interface{}{"limits": limits.JSON()})
+
+	adapter, err := newSourceAdapter(env)
+	if err != nil {
+		return err
+	}
+
+	report(map[string]interface{}{
+		"progress": 20,
+		"message":  "开始采集列表",
+	})
+
+	// 创建关键词匹配器（性能优化：在循环外创建一次，循环内重用）
+	keywordMatcher := NewKeywordMatcher(keywords, MatchModeAny)
+
+	totalFound := 0
+	totalSaved := 0
+	detailCount := 0
+	tracker := newIncrementalTracker(sourceID, limits)
+	stats := collectStats{}
+
+	for kwIdx, keyword := range keywords {
+		// 检查是否被取消
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		log.Printf("\n--- 关键词 [%d/%d]: %s ---", kwIdx+1, len(keywords), keyword)
+
+		// 更新进度：20 + (kwIdx / len(keywords)) * 70
+		report(map[string]interface{}{
+			"progress": 20 + (kwIdx*70)/len(keywords),
+			"message":  fmt.Sprintf("正在采集关键词: %s", keyword),
+		})
+
+		stats.searches++
+		iter, err := adapter.Search(ctx, keyword)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, errRateLimited) {
+				return err
+			}
+			stats.searchErrors++
+			stats.lastError = err.Error()
+			log.Printf("❌ 列表采集失败: %v", err)
+			report(map[string]interface{}{
+				"message": fmt.Sprintf("关键词 %s 采集失败: %v", keyword, err),
+			})
+			continue
+		}
+		tracker.reset()
+		// 每个关键词结束（或提前返回）时关闭迭代器，停止该关键词的列表翻页
+		if err := func() error {
+			defer closeListIterator(iter)
+			for i := 1; limits.MaxItems <= 0 || i <= limits.MaxItems; i++ {
+				// 检查是否被取消
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+
+				item, err := iter.Next(ctx)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					if errors.Is(err, errRateLimited) {
+						return err
+					}
+					log.Printf("❌ 列表采集中断: %v", err)
+					break
+				}
+				totalFound++
+				stats.observe(item)
+
+				title := item["title"]
+				if title != "" && !keywordMatcher.Match(title+" "+item["content"]) {
+					log.Printf("  [%d] 跳过（关键词不匹配）: %s", i, title)
+					continue
+				}
+
+				skip, stop := tracker.check(item["url"])
+				if skip {
+					log.Printf("  [%d] 跳过（已入库）: %s", i, title)
+					if stop {
+						break
+					}
+					continue
+				}
+
+				log.Printf("\n[%d] 准备保存: %s", i, title)
+
+				var detail map[string]string
+				if limits.MaxDetails <= 0 || detailCount < limits.MaxDetails {
+					if detailCount > 0 {
+						if err := politeDelay(ctx, limits); err != nil {
+							return err
+						}
+					}
+					detail, err = adapter.Detail(ctx, item)
+					if detail != nil || err != nil {
+						detailCount++
+					}
+					if errors.Is(err, errRateLimited) {
+						return err
+					}
+					if err != nil {
+						log.Printf("❌ 详情采集失败: %v", err)
+						continue
+					}
+				} else if detailCount > 0 {
+					log.Printf("⏭️  已达到详情抓取上限 %d，仅保存列表信息", limits.MaxDetails)
+				}
+
+				// sitemap 等没有标题的条目，标题取自详情页
+				if title == "" {
+					title = detail["title"]
+					if title == "" || !keywordMatcher.Match(title+" "+detail["content"]) {
+						log.Printf("  [%d] 跳过（无标题或关键词不匹配）: %s", i, item["url"])
+						continue
+					}
+				}
+
+				tender := &Tender{
+					SourceID:    sourceID,
+					Title:       title,
+					PublishDate: item["date"],
+					URL:         item["url"],
+					Keywords:    keyword,
+					Status:      "active",
+				}
+				fillTenderFields(tender, item)
+				fillTenderFields(tender, detail)
+
+				result, err := saveTender(tender)
+				if err != nil {
+					log.Printf("❌ 保存失败: %v", err)
+				} else {
+					switch result.Action {
+					case "created":
+						log.Printf("✅ 新增到数据库")
+						totalSaved++
+					case "updated":
+						log.Printf("🔄 更新已有记录")
+						totalSaved++
+					case "skipped":
+						log.Printf("⏭️  已存在且无变化，跳过")
+					}
+					report(map[string]interface{}{
+						"found": totalFound,
+						"saved": totalSaved,
+					})
+				}
+
+				if stop {
+					break
+				}
+			}
+			return nil
+		}(); err != nil {
+			return err
+		}
+	}
+
+	message := fmt.Sprintf("采集完成，共发现 %d 条，保存 %d 条", totalFound, totalSaved)
+	if limits.Incremental {
+		message += fmt.Sprintf("，跳过已入库 %d 条", tracker.skipped)
+	}
+	stats.found = totalFound
+	if health := checkTraceHealth(source, taskID, stats, probe); health != nil && health.Status != TraceHealthHealthy {
+		message += "；⚠️ " + health.Summary()
+	}
+	log.Printf("✅ %s: %s", source.Name, message)
+	report(map[string]interface{}{
+		"progress": 90,
+		"message":  message,
+		"found":    totalFound,
+		"saved":    totalSaved,
+	})
+
+	return nil
+}
+
+// sourceContext 将采集源的限速策略、代理、反检测和未登录标志附加到 context
+func sourceContext(ctx context.Context, source *Source) context.Context {
+	ctx = withRatePolicy(ctx, source.RatePolicy())
+	ctx = withProxy(ctx, source.Proxy)
+	ctx = withStealth(ctx, source.StealthOptions())
+	return withLoggedOutSelector(ctx, source.LoggedOutSelector)
+}
+
+// loadSourceTrace 获取采集源的轨迹：优先使用上传轨迹的生效版本，其次使用轨迹目录中的 <代码>_<类型>.json（版本号为 0）
+func loadSourceTrace(source *Source, traceType string) (*TraceFile, int) {
+	if trace, version := getTraceBySourceAndType(source.ID, traceType); trace != nil {
+		return trace, version
+	}
+	path := filepath.Join(tracesDir, source.Code+"_"+traceType+".json")
+	trace, err := loadTrace(path)
+	if err != nil {
+		return nil, 0
+	}
+	log.Printf("✅ 使用轨迹文件: %s", path)
+	return trace, 0
+}
+
+// fillTenderFields 用列表或详情中提取到的非空字段补充招标信息
+func fillTenderFields(tender *Tender, fields map[string]string) {
+	for key, target := range map[string]*string{
+		"amount": &tender.Amount, "deadline": &tender.Deadline, "contact": &tender.Contact,
+		"phone": &tender.Phone, "content": &tender.Content, "attachments": &tender.Attachments,
+	} {
+		if v := fields[key]; v != "" {
+			*target = v
+		}
+	}
+}
+
+// runCollectCommand 命令行采集：tender-monitor collect <采集源代码> <关键词>...
+func runCollectCommand(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("用法: tender-monitor collect <采集源代码> <关键词>...")
+	}
+
+	code := args[0]
+	sourceID := getSourceIDByCode(code)
+	if sourceID == 0 {
+		return fmt.Errorf("采集源不存在: %s", code)
+	}
+
+	var keywords []string
+	for _, kw := range args[1:] {
+		if kw = strings.TrimSpace(kw); kw != "" {
+			keywords = append(keywords, kw)
+		}
+	}
+
+	// Ctrl+C 取消采集
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return collectSource(ctx, "", sourceID, keywords, LimitOverrides{})
+}
@@ -81,7 +81,7 @@ install_python_deps() {
 # 构建 Go 程序
 build_go() {
     echo -e "\n${YELLOW}🔨 编译 Go 程序...${NC}"
-    go build -o tender-monitor main.go
+    go build -o tender-monitor .
     chmod +x tender-monitor
     echo -e "${GREEN}✅ 编译完成: ./tender-monitor${NC}"
 }
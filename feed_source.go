@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/charset"
+)
+
+// ==================== RSS/Atom 与 Sitemap 采集源 ====================
+//
+// kind=rss 的采集源轮询 RSS 2.0 / RSS 1.0 / Atom 订阅，kind=sitemap 的采集源读取 XML 站点地图
+// （支持 sitemapindex 与 .xml.gz）。config 示例：
+//
+//	{"url": "http://example.gov.cn/rss/zbgg.xml", "max_age_days": 7}
+//	{"url": "http://example.gov.cn/sitemap.xml", "url_pattern": "/zbgg/\\d+\\.html"}
+//
+// 条目映射为 title/url/date/content（摘要），列表阶段按关键词过滤标题和摘要；
+// 配置了详情轨迹时继续抓取详情补充字段。sitemap 通常没有标题（news:title 除外），
+// 此时标题取自详情轨迹的 title 字段，关键词过滤在抓取详情后进行。
+
+const (
+	SourceKindRSS     = "rss"
+	SourceKindSitemap = "sitemap"
+)
+
+// maxChildSitemaps sitemapindex 中最多展开的子站点地图数（按 lastmod 取最新）
+const maxChildSitemaps = 5
+
+// FeedSourceConfig RSS/Atom/Sitemap 采集源配置
+type FeedSourceConfig struct {
+	URL        string            `json:"url"`          // 订阅地址，可包含 {{.Keyword}}
+	Headers    map[string]string `json:"headers"`      // 额外请求头
+	URLPattern string            `json:"url_pattern"`  // 只保留链接匹配该正则的条目
+	MaxAgeDays int               `json:"max_age_days"` // 只保留最近 N 天发布的条目（0=不限）
+
+	urlRegexp *regexp.Regexp
+}
+
+// parseFeedSourceConfig 解析并校验订阅配置
+func parseFeedSourceConfig(raw string) (*FeedSourceConfig, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, fmt.Errorf("订阅采集源缺少配置")
+	}
+	var cfg FeedSourceConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return nil, fmt.Errorf("解析订阅配置失败: %v", err)
+	}
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("订阅配置缺少 url")
+	}
+	if cfg.URLPattern != "" {
+		re, err := regexp.Compile(cfg.URLPattern)
+		if err != nil {
+			return nil, fmt.Errorf("url_pattern 无效: %v", err)
+		}
+		cfg.urlRegexp = re
+	}
+	return &cfg, nil
+}
+
+// feedDocument 兼容 RSS 2.0、RSS 1.0(RDF)、Atom 与 Sitemap 的解析结构
+type feedDocument struct {
+	XMLName xml.Name
+	Channel struct {
+		Items []feedItem `xml:"item"`
+	} `xml:"channel"`
+	Items    []feedItem    `xml:"item"`
+	Entries  []feedItem    `xml:"entry"`
+	URLs     []sitemapURL  `xml:"url"`
+	Sitemaps []sitemapLink `xml:"sitemap"`
+}
+
+// feedItem RSS item 或 Atom entry
+type feedItem struct {
+	Title string `xml:"title"`
+	Link  []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+		Text string `xml:",chardata"`
+	} `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"date"` // dc:date
+	Published   string `xml:"published"`
+	Updated     string `xml:"updated"`
+	Description string `xml:"description"`
+	Summary     string `xml:"summary"`
+	Content     string `xml:"content"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+	News    struct {
+		Title           string `xml:"title"`
+		PublicationDate string `xml:"publication_date"`
+	} `xml:"news"`
+}
+
+type sitemapLink struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+func init() {
+	registerKindAdapter(SourceKindRSS, newFeedAdapter)
+	registerKindAdapter(SourceKindSitemap, newFeedAdapter)
+}
+
+// feedAdapter 读取订阅或站点地图的适配器
+type feedAdapter struct {
+	env     *adapterEnv
+	cfg     *FeedSourceConfig
+	entries []map[string]string // 地址不含关键词时，同一任务内只请求一次
+}
+
+func newFeedAdapter(env *adapterEnv) (SourceAdapter, error) {
+	cfg, err := parseFeedSourceConfig(env.Source.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &feedAdapter{env: env, cfg: cfg}, nil
+}
+
+// Search 获取订阅条目并按关键词过滤
+func (a *feedAdapter) Search(ctx context.Context, keyword string) (ListIterator, error) {
+	entries := a.entries
+	if entries == nil {
+		feedURL := replaceParams(a.cfg.URL, map[string]string{"Keyword": url.QueryEscape(keyword)})
+		var err error
+		if a.env.Source.Kind == SourceKindSitemap {
+			entries, err = fetchSitemapEntries(ctx, a.cfg, feedURL, 0)
+		} else {
+			entries, err = fetchFeedEntries(ctx, a.cfg, feedURL)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !strings.Contains(a.cfg.URL, "{{.Keyword}}") {
+			a.entries = entries
+		}
+		log.Printf("📰 订阅返回 %d 条记录", len(entries))
+	}
+
+	matcher := NewKeywordMatcher([]string{keyword}, MatchModeAny)
+	var cutoff string
+	if a.cfg.MaxAgeDays > 0 {
+		cutoff = time.Now().AddDate(0, 0, -a.cfg.MaxAgeDays).Format("2006-01-02")
+	}
+
+	var results []map[string]string
+	for _, entry := range entries {
+		if a.cfg.urlRegexp != nil && !a.cfg.urlRegexp.MatchString(entry["url"]) {
+			continue
+		}
+		if cutoff != "" && entry["date"] != "" && entry["date"] < cutoff {
+			continue
+		}
+		// 没有标题的 sitemap 条目在抓取详情后再按关键词过滤
+		if entry["title"] != "" && !matcher.Match(entry["title"]+" "+entry["content"]) {
+			continue
+		}
+		results = append(results, entry)
+	}
+	return newSliceIterator(results), nil
+}
+
+func (a *feedAdapter) Detail(ctx context.Context, item map[string]string) (map[string]string, error) {
+	return a.env.runDetailTrace(ctx, item)
+}
+
+// fetchFeedEntries 请求并解析 RSS/Atom 订阅
+func fetchFeedEntries(ctx context.Context, cfg *FeedSourceConfig, feedURL string) ([]map[string]string, error) {
+	doc, err := fetchFeedDocument(ctx, cfg, feedURL)
+	if err != nil {
+		return nil, err
+	}
+
+	items := append(append(doc.Channel.Items, doc.Items...), doc.Entries...)
+	var entries []map[string]string
+	for _, it := range items {
+		link := it.link()
+		if link == "" {
+			continue
+		}
+		summary := it.Description
+		if summary == "" {
+			summary = it.Summary
+		}
+		if summary == "" {
+			summary = it.Content
+		}
+		entries = append(entries, map[string]string{
+			"title":   collapseSpace(htmlToText(it.Title)),
+			"url":     normalizeURL(link, feedURL, nil),
+			"date":    parseFeedDate(firstNonEmpty(it.PubDate, it.Published, it.Date, it.Updated)),
+			"content": htmlToText(summary),
+		})
+	}
+	return entries, nil
+}
+
+// fetchSitemapEntries 请求并解析站点地图，depth 用于限制 sitemapindex 的嵌套层数
+func fetchSitemapEntries(ctx context.Context, cfg *FeedSourceConfig, sitemapURL string, depth int) ([]map[string]string, error) {
+	doc, err := fetchFeedDocument(ctx, cfg, sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(doc.Sitemaps) > 0 && depth == 0 {
+		children := doc.Sitemaps
+		// lastmod 为 ISO 日期，字符串倒序即时间倒序
+		sort.SliceStable(children, func(i, j int) bool {
+			return children[i].LastMod > children[j].LastMod
+		})
+		if len(children) > maxChildSitemaps {
+			children = children[:maxChildSitemaps]
+		}
+
+		var entries []map[string]string
+		for _, child := range children {
+			childEntries, err := fetchSitemapEntries(ctx, cfg, resolveURL(sitemapURL, strings.TrimSpace(child.Loc)), depth+1)
+			if err != nil {
+				log.Printf("⚠️ 子站点地图获取失败 %s: %v", child.Loc, err)
+				continue
+			}
+			entries = append(entries, childEntries...)
+		}
+		return entries, nil
+	}
+
+	var entries []map[string]string
+	for _, u := range doc.URLs {
+		loc := strings.TrimSpace(u.Loc)
+		if loc == "" {
+			continue
+		}
+		entries = append(entries, map[string]string{
+			"title": collapseSpace(u.News.Title),
+			"url":   normalizeURL(loc, sitemapURL, nil),
+			"date":  parseFeedDate(firstNonEmpty(u.News.PublicationDate, u.LastMod)),
+		})
+	}
+	// 按日期倒序排列，与列表页顺序一致，增量模式才能尽早停止
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i]["date"] > entries[j]["date"]
+	})
+	return entries, nil
+}
+
+// fetchFeedDocument 请求 XML 文档，自动处理 gzip 和非 UTF-8 编码
+func fetchFeedDocument(ctx context.Context, cfg *FeedSourceConfig, feedURL string) (*feedDocument, error) {
+	release, err := beginRequest(ctx, feedURL)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("User-Agent", userAgentFrom(ctx))
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml, text/xml, */*")
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := doProxiedRequest(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("请求失败: HTTP %d", resp.StatusCode)
+	}
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+	if len(raw) > 2 && raw[0] == 0x1f && raw[1] == 0x8b {
+		gz, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("解压失败: %v", err)
+		}
+		if raw, err = io.ReadAll(gz); err != nil {
+			return nil, fmt.Errorf("解压失败: %v", err)
+		}
+	}
+
+	var doc feedDocument
+	decoder := xml.NewDecoder(bytes.NewReader(raw))
+	decoder.CharsetReader = charset.NewReaderLabel
+	decoder.Strict = false
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("解析XML失败: %v", err)
+	}
+	return &doc, nil
+}
+
+// link 取条目链接：Atom 优先 rel=alternate，RSS 取 link 文本，最后尝试 guid
+func (it feedItem) link() string {
+	var fallback string
+	for _, l := range it.Link {
+		if l.Href != "" && (l.Rel == "" || l.Rel == "alternate") {
+			return strings.TrimSpace(l.Href)
+		}
+		if text := strings.TrimSpace(l.Text); text != "" && fallback == "" {
+			fallback = text
+		}
+	}
+	if fallback == "" && strings.HasPrefix(strings.TrimSpace(it.GUID), "http") {
+		fallback = strings.TrimSpace(it.GUID)
+	}
+	return fallback
+}
+
+// feedDateLayouts RSS/Atom 常见的日期格式
+var feedDateLayouts = []string{
+	time.RFC1123Z, time.RFC1123, time.RFC3339, time.RFC822Z, time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST",
+	"2006-01-02T15:04:05", "2006-01-02 15:04:05",
+}
+
+// parseFeedDate 将订阅中的日期统一为 2006-01-02
+func parseFeedDate(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	for _, layout := range feedDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("2006-01-02") // 保留原时区的日期
+		}
+	}
+	return normalizeDate(value)
+}
+
+// htmlToText 去掉摘要中的 HTML 标签
+func htmlToText(s string) string {
+	if !strings.Contains(s, "<") && !strings.Contains(s, "&") {
+		return strings.TrimSpace(s)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(s))
+	if err != nil {
+		return strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(doc.Text())
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
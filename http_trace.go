@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/htmlquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/transform"
+)
+
+// ==================== HTTP 轨迹执行 ====================
+//
+// 轨迹设置 "mode": "http" 后，不启动浏览器，直接用 net/http 请求页面并解析 HTML。
+// 适用于服务端渲染的静态页面（多数详情页），支持 navigate、wait、extract 和流程控制步骤，
+// 字段选择器与浏览器模式使用相同的表达式语法。
+
+const defaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+var (
+	httpTraceClient     *http.Client
+	httpTraceClientOnce sync.Once
+)
+
+// getHTTPTraceClient 获取共享的 HTTP 客户端（带 Cookie 管理）
+func getHTTPTraceClient() *http.Client {
+	httpTraceClientOnce.Do(func() {
+		jar, _ := cookiejar.New(nil)
+		httpTraceClient = &http.Client{
+			Timeout: 30 * time.Second,
+			Jar:     jar,
+		}
+	})
+	return httpTraceClient
+}
+
+// htmlPage 已抓取并解码为 UTF-8 的页面
+type htmlPage struct {
+	URL  string
+	Doc  *goquery.Document
+	Root *html.Node
+}
+
+// fetchHTMLPage 请求页面并转换为 UTF-8
+func fetchHTMLPage(ctx context.Context, pageURL string, headers map[string]string, forceEncoding string) (*htmlPage, error) {
+	release, err := beginRequest(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("User-Agent", userAgentFrom(ctx))
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := doProxiedRequest(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("请求失败: HTTP %d", resp.StatusCode)
+	}
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+
+	body, encName, err := decodeHTML(raw, resp.Header.Get("Content-Type"), forceEncoding)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("🌐 HTTP 获取页面: %s (%d 字节, 编码=%s)", pageURL, len(raw), encName)
+
+	root, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("解析HTML失败: %v", err)
+	}
+
+	return &htmlPage{
+		URL:  resp.Request.URL.String(),
+		Doc:  goquery.NewDocumentFromNode(root),
+		Root: root,
+	}, nil
+}
+
+// decodeHTML 检测页面编码并转换为 UTF-8（GBK/GB2312 统一按 GB18030 解码）
+func decodeHTML(raw []byte, contentType, forceEncoding string) ([]byte, string, error) {
+	var enc encoding.Encoding
+	name := strings.ToLower(strings.TrimSpace(forceEncoding))
+
+	if name == "" {
+		var certain bool
+		_, name, certain = charset.DetermineEncoding(raw, contentType)
+		// 未声明编码时 DetermineEncoding 默认返回 windows-1252，中文站点按内容判断
+		if !certain && name == "windows-1252" {
+			if utf8.Valid(raw) {
+				name = "utf-8"
+			} else {
+				name = "gb18030"
+			}
+		}
+	}
+
+	switch name {
+	case "utf-8", "utf8":
+		return raw, "utf-8", nil
+	case "gbk", "gb2312", "gb18030", "hz-gb-2312":
+		enc = simplifiedchinese.GB18030
+	default:
+		enc, _ = charset.Lookup(name)
+		if enc == nil {
+			return raw, name, nil
+		}
+	}
+
+	body, _, err := transform.Bytes(enc.NewDecoder(), raw)
+	if err != nil {
+		return nil, name, fmt.Errorf("编码转换失败(%s): %v", name, err)
+	}
+	return body, name, nil
+}
+
+// traceNeedsBrowser 判断轨迹是否需要浏览器执行
+func traceNeedsBrowser(trace *TraceFile) bool {
+	return trace != nil && trace.Mode != "http"
+}
+
+// executeHTTPTrace 以 HTTP 模式执行轨迹
+func executeHTTPTrace(ctx context.Context, trace *TraceFile, params map[string]string) (interface{}, error) {
+	run := &httpRun{ctx: ctx, trace: trace, vars: copyParams(params)}
+	if err := run.runSteps(trace.Steps); err != nil {
+		return nil, err
+	}
+	return run.data, nil
+}
+
+// httpRun HTTP 模式轨迹的一次执行状态
+type httpRun struct {
+	ctx   context.Context
+	trace *TraceFile
+	vars  map[string]string
+	page  *htmlPage
+	item  *goquery.Selection // foreach 遍历元素时的当前元素
+	data  interface{}
+}
+
+func (r *httpRun) runSteps(steps []TraceStep) error {
+	for i, step := range steps {
+		log.Printf("执行步骤 %d/%d: %s (http)", i+1, len(steps), step.Action)
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+
+		switch step.Action {
+		case "navigate":
+			url := replaceParams(step.URL, r.vars)
+			p, err := fetchHTMLPage(r.ctx, url, r.trace.Headers, r.trace.Encoding)
+			if err != nil {
+				return fmt.Errorf("导航失败: %w", err)
+			}
+			r.page = p
+		case "wait":
+			// 静态页面无需等待渲染
+		case "extract":
+			if r.page == nil {
+				return fmt.Errorf("extract 之前没有 navigate 步骤")
+			}
+			if step.Type == "list" {
+				r.data = mergeExtracted(r.data, extractListHTML(r.page, step, extractProbeFrom(r.ctx)))
+			} else if step.Type == "detail" {
+				r.data = extractDetailHTML(r.page, step)
+			}
+		default:
+			if handled, err := runFlowStep(step, r, r.runSteps); handled {
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			return fmt.Errorf("HTTP 模式不支持 %s 步骤，请改用浏览器模式", step.Action)
+		}
+	}
+	return nil
+}
+
+func (r *httpRun) variables() map[string]string {
+	return r.vars
+}
+
+func (r *httpRun) currentURL() string {
+	if r.page == nil {
+		return ""
+	}
+	return r.page.URL
+}
+
+// scope 选择器的查询范围，支持 @item
+func (r *httpRun) scope(selector string) (*goquery.Selection, string) {
+	if isItem, sub := splitItemSelector(selector); isItem {
+		return r.item, sub
+	}
+	if r.page == nil {
+		return nil, selector
+	}
+	return r.page.Doc.Selection, selector
+}
+
+func (r *httpRun) exists(selector string) bool {
+	scope, sub := r.scope(selector)
+	if scope == nil {
+		return false
+	}
+	return sub == "" || queryHTML(scope, parseFieldSelector(sub)).Length() > 0
+}
+
+func (r *httpRun) fieldValue(expr string) (string, bool) {
+	scope, sub := r.scope(expr)
+	if scope == nil {
+		return "", false
+	}
+	if sub == "" {
+		return collapseSpace(scope.Text()), true
+	}
+	return extractFieldHTML(scope, sub, r.currentURL())
+}
+
+func (r *httpRun) forEachElement(selector string, limit int, fn func(i int, text string) error) error {
+	if r.page == nil {
+		return fmt.Errorf("foreach 之前没有 navigate 步骤")
+	}
+	found := queryHTML(r.page.Doc.Selection, parseFieldSelector(selector))
+	count := found.Length()
+	if limit > 0 && count > limit {
+		count = limit
+	}
+
+	prev := r.item
+	defer func() { r.item = prev }()
+	for i := 0; i < count; i++ {
+		r.item = found.Eq(i)
+		if err := fn(i, collapseSpace(r.item.Text())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractListHTML 从静态页面提取列表，probe 用法同 extractList
+func extractListHTML(page *htmlPage, step TraceStep, probe *extractProbe) []map[string]string {
+	var results []map[string]string
+
+	var rows []*html.Node
+	if step.XPath != "" {
+		rows = htmlquery.Find(page.Root, step.XPath)
+	} else {
+		rows = queryHTML(page.Doc.Selection, parseFieldSelector(step.Selector)).Nodes
+	}
+	log.Printf("找到 %d 条记录", len(rows))
+	defer func() { probe.record(step, page.URL, len(rows), len(results), page.Doc.Text) }()
+
+	for _, node := range rows {
+		row := goquery.NewDocumentFromNode(node).Selection
+		item := make(map[string]string)
+		hasValidData := false
+
+		for field, selector := range step.Fields {
+			if strings.HasPrefix(selector, "@click") {
+				continue // 点击取链接需要浏览器
+			}
+			if text, ok := extractFieldHTML(row, selector, page.URL); ok {
+				item[field] = text
+				if text != "" {
+					hasValidData = true
+				}
+			}
+		}
+
+		if item["url"] != "" {
+			item["url"] = normalizeURL(item["url"], page.URL, nil)
+		}
+
+		if hasValidData && item["url"] != "" {
+			results = append(results, item)
+			log.Printf("  提取数据: title=%s, date=%s, url=%s", item["title"], item["date"], item["url"])
+		} else {
+			log.Printf("  跳过无效数据: hasValidData=%v, url=%s", hasValidData, item["url"])
+		}
+
+		if step.MaxItems > 0 && len(results) >= step.MaxItems {
+			log.Printf("已达到采集上限 %d 条", step.MaxItems)
+			break
+		}
+	}
+
+	return results
+}
+
+// extractDetailHTML 从静态页面提取详情字段
+func extractDetailHTML(page *htmlPage, step TraceStep) map[string]string {
+	result := make(map[string]string)
+
+	for field, selector := range step.Fields {
+		if text, ok := extractFieldHTML(page.Doc.Selection, selector, page.URL); ok {
+			result[field] = text
+		}
+	}
+
+	for field, selector := range step.MultiFields {
+		var links []map[string]string
+		queryHTML(page.Doc.Selection, parseFieldSelector(selector)).Each(func(_ int, s *goquery.Selection) {
+			if href, ok := s.Attr("href"); ok && href != "" {
+				links = append(links, map[string]string{"url": resolveURL(page.URL, href), "name": strings.TrimSpace(s.Text())})
+			}
+		})
+		if len(links) > 0 {
+			jsonData, _ := json.Marshal(links)
+			result[field] = string(jsonData)
+		}
+	}
+
+	return result
+}
+
+// extractFieldHTML 在静态页面中按选择器表达式提取字段值
+func extractFieldHTML(scope *goquery.Selection, expr string, baseURL string) (string, bool) {
+	sel := parseFieldSelector(expr)
+	found := queryHTML(scope, sel)
+	if found.Length() == 0 {
+		return "", false
+	}
+	first := found.First()
+
+	var value string
+	switch sel.Attr {
+	case "", "text":
+		value = first.Text()
+	case "html":
+		value, _ = first.Html()
+	default:
+		value, _ = first.Attr(sel.Attr)
+	}
+	return applySelectorFilters(value, sel.Filters, baseURL), true
+}
+
+// queryHTML 在静态页面中执行选择器查询
+func queryHTML(scope *goquery.Selection, sel FieldSelector) *goquery.Selection {
+	empty := scope.Slice(0, 0)
+
+	switch sel.Kind {
+	case "xpath":
+		var nodes []*html.Node
+		for _, n := range scope.Nodes {
+			found, err := htmlquery.QueryAll(n, sel.Query)
+			if err != nil {
+				log.Printf("⚠️ XPath 无效 '%s': %v", sel.Query, err)
+				return empty
+			}
+			nodes = append(nodes, found...)
+		}
+		return empty.AddNodes(nodes...)
+	case "text":
+		return innermostHTML(scope.Find("*").FilterFunction(func(_ int, s *goquery.Selection) bool {
+			return strings.Contains(collapseSpace(s.Text()), sel.Query)
+		}))
+	case "regex":
+		re, err := regexp.Compile(sel.Pattern)
+		if err != nil {
+			log.Printf("⚠️ 正则无效 '%s': %v", sel.Pattern, err)
+			return empty
+		}
+		query := sel.Query
+		if query == "" {
+			query = "*"
+		}
+		matched := scope.Find(query).FilterFunction(func(_ int, s *goquery.Selection) bool {
+			return re.MatchString(collapseSpace(s.Text()))
+		})
+		if sel.Query == "" {
+			return innermostHTML(matched)
+		}
+		return matched
+	default:
+		// cascadia 原生支持 :contains()，:text() 按包含文本处理
+		query := strings.ReplaceAll(sel.Query, ":text(", ":contains(")
+		return scope.Find(query)
+	}
+}
+
+// innermostHTML 只保留不包含其他匹配元素的最内层元素
+func innermostHTML(s *goquery.Selection) *goquery.Selection {
+	return s.FilterFunction(func(_ int, el *goquery.Selection) bool {
+		return el.Find("*").FilterNodes(s.Nodes...).Length() == 0
+	})
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
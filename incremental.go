@@ -0,0 +1,92 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// ==================== 增量采集 ====================
+
+// incrementalTracker 跟踪单个关键词采集过程中遇到的已知条目
+type incrementalTracker struct {
+	sourceID         int
+	limits           CollectLimits
+	stripParams      []string
+	consecutiveKnown int
+	skipped          int
+}
+
+func newIncrementalTracker(sourceID int, limits CollectLimits) *incrementalTracker {
+	return &incrementalTracker{
+		sourceID:    sourceID,
+		limits:      limits,
+		stripParams: sourceStripParams(sourceID),
+	}
+}
+
+// reset 开始新的关键词时重置连续计数
+func (t *incrementalTracker) reset() {
+	t.consecutiveKnown = 0
+}
+
+// check 判断列表条目是否跳过详情抓取，以及是否应停止当前关键词的采集
+func (t *incrementalTracker) check(rawURL string) (skip bool, stop bool) {
+	if !t.limits.Incremental || rawURL == "" {
+		return false, false
+	}
+
+	known, checkedAt := lookupKnownTender(canonicalURLKey(rawURL, t.stripParams), rawURL)
+	if !known {
+		t.consecutiveKnown = 0
+		return false, false
+	}
+
+	t.consecutiveKnown++
+	if t.limits.StopAfterKnown > 0 && t.consecutiveKnown >= t.limits.StopAfterKnown {
+		log.Printf("⏹️  连续 %d 条已入库，停止当前关键词", t.consecutiveKnown)
+		stop = true
+	}
+
+	recheck := time.Duration(t.limits.RecheckHours) * time.Hour
+	if recheck > 0 && (checkedAt.IsZero() || time.Since(checkedAt) >= recheck) {
+		return false, stop
+	}
+
+	t.skipped++
+	return true, stop
+}
+
+// lookupKnownTender 按URL去重键查询已入库的招标信息及其最近检查时间
+func lookupKnownTender(urlKey, rawURL string) (bool, time.Time) {
+	if urlKey == "" {
+		return false, time.Time{}
+	}
+	var checkedAt, createdAt sql.NullString
+	err := db.QueryRow(`SELECT checked_at, created_at FROM tenders WHERE url_key = ? OR url = ? LIMIT 1`, urlKey, rawURL).
+		Scan(&checkedAt, &createdAt)
+	if err != nil {
+		return false, time.Time{}
+	}
+
+	// checked_at 按本地时间写入，created_at 由 SQLite 以 UTC 写入
+	if t, ok := parseDBTime(checkedAt, time.Local); ok {
+		return true, t
+	}
+	if t, ok := parseDBTime(createdAt, time.UTC); ok {
+		return true, t
+	}
+	return true, time.Time{}
+}
+
+func parseDBTime(v sql.NullString, loc *time.Location) (time.Time, bool) {
+	if !v.Valid || v.String == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", time.RFC3339Nano} {
+		if t, err := time.ParseInLocation(layout, v.String, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// ==================== 站点登录 ====================
+//
+// 需要账号的采集源（如央企采购平台）配置：
+//   - 登录轨迹（type=login），步骤中用 {{.Username}}/{{.Password}} 引用凭据
+//   - 凭据：通过 /api/credentials 保存，账号、密码和会话 Cookie 加密存储在 source_credentials 表
+//   - logged_out_selector：页面出现该元素时视为未登录
+//
+// 任务租用浏览器后先恢复保存的会话；没有会话时执行登录轨迹。轨迹执行中检测到未登录时
+// 重新登录并重试一次，登录成功后保存新的 Cookie。
+
+// errLoggedOut 轨迹执行中检测到登录失效
+var errLoggedOut = errors.New("登录已失效")
+
+// SourceCredential 采集源的登录凭据（密码不返回给前端）
+type SourceCredential struct {
+	SourceID       int    `json:"source_id"`
+	Username       string `json:"username"`
+	Password       string `json:"password,omitempty"`
+	HasPassword    bool   `json:"has_password"`
+	HasSession     bool   `json:"has_session"`
+	SessionSavedAt string `json:"session_saved_at"`
+	UpdatedAt      string `json:"updated_at"`
+}
+
+var (
+	vaultKeyOnce sync.Once
+	vaultKey     []byte
+	vaultKeyErr  error
+)
+
+// getVaultKey 凭据加密密钥：优先使用 CREDENTIAL_KEY，否则使用数据目录中自动生成的密钥文件
+func getVaultKey() ([]byte, error) {
+	vaultKeyOnce.Do(func() {
+		if secret := os.Getenv("CREDENTIAL_KEY"); secret != "" {
+			sum := sha256.Sum256([]byte(secret))
+			vaultKey = sum[:]
+			return
+		}
+
+		keyPath := filepath.Join(dataDir, "credential.key")
+		if data, err := os.ReadFile(keyPath); err == nil {
+			vaultKey, vaultKeyErr = base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+			if vaultKeyErr == nil && len(vaultKey) != 32 {
+				vaultKeyErr = fmt.Errorf("密钥文件长度无效: %s", keyPath)
+			}
+			return
+		}
+
+		key := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			vaultKeyErr = fmt.Errorf("生成凭据密钥失败: %v", err)
+			return
+		}
+		os.MkdirAll(dataDir, 0755)
+		if err := os.WriteFile(keyPath, []byte(base64.StdEncoding.EncodeToString(key)), 0600); err != nil {
+			vaultKeyErr = fmt.Errorf("保存凭据密钥失败: %v", err)
+			return
+		}
+		log.Printf("🔑 已生成凭据加密密钥: %s（请妥善备份，或设置 CREDENTIAL_KEY）", keyPath)
+		vaultKey = key
+	})
+	return vaultKey, vaultKeyErr
+}
+
+// encryptSecret AES-GCM 加密，返回 base64(nonce+密文)
+func encryptSecret(plain string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+	key, err := getVaultKey()
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plain), nil)), nil
+}
+
+// decryptSecret 解密 encryptSecret 的结果
+func decryptSecret(encoded string) (string, error) {
+	if encoded == "" {
+		return "", nil
+	}
+	key, err := getVaultKey()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("凭据格式无效: %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("凭据格式无效")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("凭据解密失败（密钥是否变更？）: %v", err)
+	}
+	return string(plain), nil
+}
+
+// ==================== 凭据存储 ====================
+
+// getSourceCredential 读取并解密采集源的凭据，未配置时返回 nil；查询或解密失败时返回错误，不当作未配置
+func getSourceCredential(sourceID int) (*SourceCredential, error) {
+	var username, password, cookies, sessionAt, updatedAt string
+	err := db.QueryRow(`SELECT COALESCE(username, ''), COALESCE(password, ''), COALESCE(cookies, ''),
+		COALESCE(session_saved_at, ''), COALESCE(updated_at, '') FROM source_credentials WHERE source_id = ?`, sourceID).
+		Scan(&username, &password, &cookies, &sessionAt, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cred := &SourceCredential{
+		SourceID: sourceID, HasPassword: password != "", HasSession: cookies != "",
+		SessionSavedAt: sessionAt, UpdatedAt: updatedAt,
+	}
+	if cred.Username, err = decryptSecret(username); err != nil {
+		return nil, err
+	}
+	if cred.Password, err = decryptSecret(password); err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// saveSourceCredential 加密保存账号密码，密码为空时保留原密码；账号变更时清除已保存的会话
+func saveSourceCredential(sourceID int, username, password string) error {
+	encUser, err := encryptSecret(username)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Format("2006-01-02 15:04:05")
+
+	existing, err := getSourceCredential(sourceID)
+	if err != nil {
+		return fmt.Errorf("读取已保存的凭据失败（可删除后重新配置）: %v", err)
+	}
+	if existing == nil {
+		encPass, err := encryptSecret(password)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec("INSERT INTO source_credentials (source_id, username, password, updated_at) VALUES (?, ?, ?, ?)",
+			sourceID, encUser, encPass, now)
+		return err
+	}
+
+	if password == "" {
+		password = existing.Password
+	}
+	encPass, err := encryptSecret(password)
+	if err != nil {
+		return err
+	}
+	if existing.Username != username {
+		_, err = db.Exec("UPDATE source_credentials SET username=?, password=?, cookies='', session_saved_at='', updated_at=? WHERE source_id=?",
+			encUser, encPass, now, sourceID)
+		return err
+	}
+	_, err = db.Exec("UPDATE source_credentials SET username=?, password=?, updated_at=? WHERE source_id=?",
+		encUser, encPass, now, sourceID)
+	return err
+}
+
+// deleteSourceCredential 删除凭据和会话
+func deleteSourceCredential(sourceID int) error {
+	_, err := db.Exec("DELETE FROM source_credentials WHERE source_id = ?", sourceID)
+	return err
+}
+
+// loadSourceSession 读取保存的会话 Cookie
+func loadSourceSession(sourceID int) ([]*proto.NetworkCookieParam, error) {
+	var encoded string
+	if err := db.QueryRow("SELECT COALESCE(cookies, '') FROM source_credentials WHERE source_id = ?", sourceID).Scan(&encoded); err != nil || encoded == "" {
+		return nil, nil
+	}
+	raw, err := decryptSecret(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var cookies []*proto.NetworkCookieParam
+	if err := json.Unmarshal([]byte(raw), &cookies); err != nil {
+		return nil, fmt.Errorf("会话格式无效: %v", err)
+	}
+	return cookies, nil
+}
+
+// saveSourceSession 加密保存会话 Cookie
+func saveSourceSession(sourceID int, cookies []*proto.NetworkCookie) error {
+	raw, err := json.Marshal(proto.CookiesToParams(cookies))
+	if err != nil {
+		return err
+	}
+	encoded, err := encryptSecret(string(raw))
+	if err != nil {
+		return err
+	}
+	now := time.Now().Format("2006-01-02 15:04:05")
+	result, err := db.Exec("UPDATE source_credentials SET cookies=?, session_saved_at=? WHERE source_id=?", encoded, now, sourceID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		_, err = db.Exec("INSERT INTO source_credentials (source_id, cookies, session_saved_at, updated_at) VALUES (?, ?, ?, ?)",
+			sourceID, encoded, now, now)
+	}
+	return err
+}
+
+// ==================== 登录流程 ====================
+
+type loggedOutKey struct{}
+
+// withLoggedOutSelector 将采集源的未登录标志附加到 context
+func withLoggedOutSelector(ctx context.Context, selector string) context.Context {
+	return context.WithValue(ctx, loggedOutKey{}, strings.TrimSpace(selector))
+}
+
+// checkLoggedOut 页面出现未登录标志时返回 errLoggedOut
+func checkLoggedOut(ctx context.Context, page *rod.Page) error {
+	selector, _ := ctx.Value(loggedOutKey{}).(string)
+	if selector == "" {
+		return nil
+	}
+	if elems, err := findElements(page, selector); err == nil && len(elems) > 0 {
+		return fmt.Errorf("%w: 页面出现 %s", errLoggedOut, selector)
+	}
+	return nil
+}
+
+// loginParams 合并凭据参数，供登录轨迹步骤中的 {{.Username}}/{{.Password}} 使用；列表和详情轨迹不传入凭据
+func (env *adapterEnv) loginParams(params map[string]string) map[string]string {
+	if env.credential == nil {
+		return params
+	}
+	merged := make(map[string]string, len(params)+2)
+	for k, v := range params {
+		merged[k] = v
+	}
+	merged["Username"] = env.credential.Username
+	merged["Password"] = env.credential.Password
+	return merged
+}
+
+// prepareSession 浏览器租用后恢复会话，没有会话且配置了登录轨迹时先登录；
+// 恢复或登录成功后才标记会话就绪，失败时下次执行轨迹会重试
+func (env *adapterEnv) prepareSession(ctx context.Context, browser *rod.Browser) error {
+	if env.sessionReady || env.LoginTrace == nil {
+		return nil
+	}
+
+	cookies, err := loadSourceSession(env.Source.ID)
+	if err != nil {
+		log.Printf("⚠️ 读取会话失败: %v", err)
+	}
+	if len(cookies) > 0 {
+		if err := browser.SetCookies(cookies); err != nil {
+			log.Printf("⚠️ 恢复会话失败: %v", err)
+		} else {
+			log.Printf("🍪 已恢复 %s 的登录会话（%d 个 Cookie）", env.Source.Name, len(cookies))
+			env.sessionReady = true
+			return nil
+		}
+	}
+	return env.login(ctx, browser)
+}
+
+// login 执行登录轨迹并保存会话
+func (env *adapterEnv) login(ctx context.Context, browser *rod.Browser) error {
+	if env.LoginTrace == nil {
+		return fmt.Errorf("%w，且未配置登录轨迹", errLoggedOut)
+	}
+	if env.credential == nil {
+		return fmt.Errorf("采集源 %s 未配置登录凭据", env.Source.Name)
+	}
+	log.Printf("🔐 正在登录 %s", env.Source.Name)
+
+	if _, err := executeTrace(ctx, browser, env.LoginTrace, env.loginParams(nil), env.Solver); err != nil {
+		if errors.Is(err, errLoggedOut) {
+			return fmt.Errorf("登录失败，登录后仍显示未登录: %v", err)
+		}
+		return fmt.Errorf("登录失败: %v", err)
+	}
+	env.sessionReady = true
+
+	cookies, err := browser.GetCookies()
+	if err != nil {
+		log.Printf("⚠️ 读取登录 Cookie 失败: %v", err)
+		return nil
+	}
+	if err := saveSourceSession(env.Source.ID, cookies); err != nil {
+		log.Printf("⚠️ 保存登录会话失败: %v", err)
+	} else {
+		log.Printf("✅ 登录成功，已保存会话（%d 个 Cookie）", len(cookies))
+	}
+	return nil
+}
+
+// ==================== 凭据接口 ====================
+
+func handleCredentials(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		sourceID, err := parseInt(r.URL.Query().Get("source_id"))
+		if err != nil {
+			http.Error(w, "缺少 source_id", http.StatusBadRequest)
+			return
+		}
+		cred, err := getSourceCredential(sourceID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if cred != nil {
+			cred.Password = ""
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": cred})
+	case "POST":
+		var req SourceCredential
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.SourceID <= 0 || strings.TrimSpace(req.Username) == "" {
+			http.Error(w, "source_id 和 username 不能为空", http.StatusBadRequest)
+			return
+		}
+		if err := saveSourceCredential(req.SourceID, strings.TrimSpace(req.Username), req.Password); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+	case "DELETE":
+		if id, err := parseInt(r.URL.Query().Get("source_id")); err == nil {
+			if r.URL.Query().Get("session_only") == "true" {
+				db.Exec("UPDATE source_credentials SET cookies='', session_saved_at='' WHERE source_id = ?", id)
+			} else {
+				deleteSourceCredential(id)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
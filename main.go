@@ -7,6 +7,7 @@ import (
 	"embed"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -17,6 +18,8 @@ import (
 	"sync"
 	"time"
 
+	"tender-monitor/traceconv"
+
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/launcher"
 	"github.com/go-rod/rod/lib/proto"
@@ -30,14 +33,64 @@ var staticFiles embed.FS
 
 // Source 采集源
 type Source struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Code        string `json:"code"`
-	Category    string `json:"category"`
-	BaseURL     string `json:"base_url"`
-	Description string `json:"description"`
-	IsActive    int    `json:"is_active"`
-	CreatedAt   string `json:"created_at"`
+	ID                int    `json:"id"`
+	Name              string `json:"name"`
+	Code              string `json:"code"`
+	Category          string `json:"category"`
+	BaseURL           string `json:"base_url"`
+	Description       string `json:"description"`
+	Kind              string `json:"kind"`         // 采集源类型：trace（轨迹回放，默认）/api（JSON接口）/rss/sitemap
+	Config            string `json:"config"`       // 类型相关配置（JSON），如 api 类型的请求与字段映射
+	StripParams       string `json:"strip_params"` // 去重时剔除的URL参数（逗号分隔，支持 utm_* 前缀匹配）
+	MaxItems          int    `json:"max_items"`    // 每个关键词最多提取条数（0=默认）
+	MaxDetails        int    `json:"max_details"`  // 每个任务最多抓取详情数（0=默认）
+	DelayMs           int    `json:"delay_ms"`     // 详情抓取间隔毫秒（0=默认）
+	DelayJitter       int    `json:"delay_jitter_ms"`
+	Incremental       int    `json:"incremental"`          // 默认启用增量采集（1=是）
+	RecheckHrs        int    `json:"recheck_hours"`        // 已知条目重新检查间隔（小时）
+	StopAfter         int    `json:"stop_after_known"`     // 连续已知条目停止阈值
+	MinInterval       int    `json:"min_interval_ms"`      // 同一主机请求最小间隔（毫秒，0=不限）
+	MaxPages          int    `json:"max_concurrent_pages"` // 同一主机最大并发页面数（0=不限）
+	DailyBudget       int    `json:"daily_request_budget"` // 同一主机每日请求上限（0=不限）
+	QuietHours        string `json:"quiet_hours"`          // 禁止采集的时段，如 22:00-06:00
+	Proxy             string `json:"proxy"`                // 代理：空=直连，http(s)/socks5 地址，pool=代理池轮换
+	BrowserMode       string `json:"browser_mode"`         // 浏览器隔离：incognito（默认，每个任务一个隐身上下文）/profile（采集源独占持久化目录）
+	Stealth           string `json:"stealth"`              // 反检测配置（JSON）：UA、视口、语言时区、模拟人工操作
+	LoggedOutSelector string `json:"logged_out_selector"`  // 页面出现该元素时视为登录失效（配合 login 轨迹）
+	IsActive          int    `json:"is_active"`
+	CreatedAt         string `json:"created_at"`
+}
+
+// Limits 采集源配置的采集限制
+func (s *Source) Limits() LimitOverrides {
+	o := LimitOverrides{
+		MaxItems: positiveInt(s.MaxItems), MaxDetails: positiveInt(s.MaxDetails),
+		DelayMs: positiveInt(s.DelayMs), DelayJitterMs: positiveInt(s.DelayJitter),
+		RecheckHours: positiveInt(s.RecheckHrs), StopAfterKnown: positiveInt(s.StopAfter),
+	}
+	if s.Incremental == 1 {
+		incremental := true
+		o.Incremental = &incremental
+	}
+	return o
+}
+
+// StealthOptions 采集源配置的反检测选项，配置无效时使用默认值
+func (s *Source) StealthOptions() StealthOptions {
+	opts, err := parseStealthOptions(s.Stealth)
+	if err != nil {
+		log.Printf("⚠️ %s: %v，使用默认配置", s.Name, err)
+		return StealthOptions{}
+	}
+	return opts
+}
+
+// RatePolicy 采集源配置的访问频率策略
+func (s *Source) RatePolicy() RatePolicy {
+	return RatePolicy{
+		MinIntervalMs: s.MinInterval, MaxConcurrent: s.MaxPages, DailyBudget: s.DailyBudget, QuietHours: s.QuietHours,
+		sourceID: s.ID,
+	}
 }
 
 // TraceRecord 轨迹记录
@@ -62,18 +115,20 @@ type TagDefinition struct {
 
 // CollectTask 采集任务
 type CollectTask struct {
-	ID         string    `json:"id"`
-	SourceID   int       `json:"source_id"`
-	SourceName string    `json:"source_name"`
-	Keywords   string    `json:"keywords"` // JSON数组字符串
-	Status     string    `json:"status"`   // pending/running/completed/failed/cancelled
-	Progress   int       `json:"progress"` // 0-100
-	Found      int       `json:"found"`    // 发现的条数
-	Saved      int       `json:"saved"`    // 保存的条数
-	Message    string    `json:"message"`  // 状态消息或错误信息
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
-	CompletedAt string   `json:"completed_at,omitempty"`
+	ID            string    `json:"id"`
+	SourceID      int       `json:"source_id"`
+	SourceName    string    `json:"source_name"`
+	Keywords      string    `json:"keywords"`       // JSON数组字符串
+	Status        string    `json:"status"`         // pending/running/completed/failed/cancelled
+	Progress      int       `json:"progress"`       // 0-100
+	Found         int       `json:"found"`          // 发现的条数
+	Saved         int       `json:"saved"`          // 保存的条数
+	Message       string    `json:"message"`        // 状态消息或错误信息
+	Limits        string    `json:"limits"`         // 生效的采集限制（JSON）
+	TraceVersions string    `json:"trace_versions"` // 使用的轨迹版本（JSON，如 {"list":3,"detail":1}，0 表示轨迹文件）
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	CompletedAt   string    `json:"completed_at,omitempty"`
 }
 
 // Tender 招标信息
@@ -122,44 +177,13 @@ type TenderQueryResult struct {
 	TotalPages int      `json:"total_pages"` // 总页数
 }
 
-// TraceFile 标准轨迹格式
-type TraceFile struct {
-	Name  string      `json:"name"`
-	Type  string      `json:"type"`
-	URL   string      `json:"url"`
-	Steps []TraceStep `json:"steps"`
-}
-
-// TraceStep 轨迹步骤
-type TraceStep struct {
-	Action         string            `json:"action"`
-	URL            string            `json:"url,omitempty"`
-	Selector       string            `json:"selector,omitempty"`
-	XPath          string            `json:"xpath,omitempty"`
-	Value          string            `json:"value,omitempty"`
-	ImageSelector  string            `json:"image_selector,omitempty"`
-	InputSelector  string            `json:"input_selector,omitempty"`
-	Type           string            `json:"type,omitempty"`
-	Fields         map[string]string `json:"fields,omitempty"`
-	MultiFields    map[string]string `json:"multi_fields,omitempty"`
-	WaitTime       int               `json:"wait_time,omitempty"`
-	WaitForVisible string            `json:"wait_for_visible,omitempty"`
-}
-
-// ChromeDevToolsStep Chrome DevTools 录制格式
-type ChromeDevToolsStep struct {
-	Type      string     `json:"type"`
-	URL       string     `json:"url"`
-	Selectors [][]string `json:"selectors"`
-	Value     string     `json:"value"` // change事件的输入值
-}
-
-// ChromeDevToolsRecording Chrome DevTools 录制
-type ChromeDevToolsRecording struct {
-	Title string               `json:"title"`
-	URL   string               `json:"url"`
-	Steps []ChromeDevToolsStep `json:"steps"`
-}
+// 轨迹格式定义在 traceconv 包中，与 convert-trace 工具共用
+type (
+	TraceFile               = traceconv.TraceFile
+	TraceStep               = traceconv.TraceStep
+	ChromeDevToolsRecording = traceconv.ChromeRecording
+	ChromeDevToolsStep      = traceconv.ChromeStep
+)
 
 // CaptchaResponse 验证码服务响应
 type CaptchaResponse struct {
@@ -310,6 +334,24 @@ func initDB() error {
 		category TEXT NOT NULL,
 		base_url TEXT,
 		description TEXT,
+		kind TEXT DEFAULT 'trace',
+		config TEXT,
+		strip_params TEXT,
+		max_items INTEGER DEFAULT 0,
+		max_details INTEGER DEFAULT 0,
+		delay_ms INTEGER DEFAULT 0,
+		delay_jitter_ms INTEGER DEFAULT 0,
+		incremental INTEGER DEFAULT 0,
+		recheck_hours INTEGER DEFAULT 0,
+		stop_after_known INTEGER DEFAULT 0,
+		min_interval_ms INTEGER DEFAULT 0,
+		max_concurrent_pages INTEGER DEFAULT 0,
+		daily_request_budget INTEGER DEFAULT 0,
+		quiet_hours TEXT,
+		proxy TEXT,
+		browser_mode TEXT,
+		stealth TEXT,
+		logged_out_selector TEXT,
 		is_active INTEGER DEFAULT 1,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	)`)
@@ -322,10 +364,25 @@ func initDB() error {
 		raw_content TEXT,
 		parsed_url TEXT,
 		status TEXT DEFAULT 'draft',
+		active_version INTEGER DEFAULT 0,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (source_id) REFERENCES sources(id)
 	)`)
 
+	db.Exec(`CREATE TABLE IF NOT EXISTS trace_versions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		trace_id INTEGER NOT NULL,
+		version INTEGER NOT NULL,
+		name TEXT,
+		raw_content TEXT,
+		parsed_url TEXT,
+		author TEXT,
+		comment TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (trace_id, version),
+		FOREIGN KEY (trace_id) REFERENCES traces(id)
+	)`)
+
 	db.Exec(`CREATE TABLE IF NOT EXISTS tag_definitions (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		name TEXT NOT NULL UNIQUE,
@@ -343,12 +400,48 @@ func initDB() error {
 		found INTEGER DEFAULT 0,
 		saved INTEGER DEFAULT 0,
 		message TEXT,
+		limits TEXT,
+		trace_versions TEXT,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		completed_at TIMESTAMP,
 		FOREIGN KEY (source_id) REFERENCES sources(id)
 	)`)
 
+	db.Exec(`CREATE TABLE IF NOT EXISTS proxies (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL UNIQUE,
+		is_active INTEGER DEFAULT 1,
+		fail_count INTEGER DEFAULT 0,
+		last_error TEXT,
+		checked_at TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+
+	db.Exec(`CREATE TABLE IF NOT EXISTS source_credentials (
+		source_id INTEGER PRIMARY KEY,
+		username TEXT,
+		password TEXT,
+		cookies TEXT,
+		session_saved_at TEXT,
+		updated_at TEXT,
+		FOREIGN KEY (source_id) REFERENCES sources(id)
+	)`)
+
+	db.Exec(`CREATE TABLE IF NOT EXISTS trace_health (
+		source_id INTEGER PRIMARY KEY,
+		status TEXT NOT NULL,
+		issues TEXT,
+		list_selector TEXT,
+		page_url TEXT,
+		last_task_id TEXT,
+		checked_at TEXT,
+		last_healthy_at TEXT,
+		broken_since TEXT,
+		alerted_at TEXT,
+		FOREIGN KEY (source_id) REFERENCES sources(id)
+	)`)
+
 	db.Exec(`CREATE INDEX IF NOT EXISTS idx_task_status ON collect_tasks(status)`)
 	db.Exec(`CREATE INDEX IF NOT EXISTS idx_task_created ON collect_tasks(created_at)`)
 
@@ -362,6 +455,7 @@ func initDB() error {
 		contact TEXT,
 		phone TEXT,
 		url TEXT UNIQUE,
+		url_key TEXT,
 		keywords TEXT,
 		content TEXT,
 		attachments TEXT,
@@ -370,6 +464,7 @@ func initDB() error {
 		note TEXT,
 		reviewed_at TEXT,
 		reviewed_by TEXT,
+		checked_at TEXT,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	)`)
 
@@ -377,7 +472,14 @@ func initDB() error {
 	db.Exec(`CREATE INDEX IF NOT EXISTS idx_publish_date ON tenders(publish_date)`)
 	db.Exec(`CREATE INDEX IF NOT EXISTS idx_status ON tenders(status)`)
 
+	migrateSourcesTable()
 	migrateTendersTable()
+	migrateCollectTasksTable()
+	migrateTracesTable()
+	backfillTraceVersions()
+	db.Exec(`CREATE INDEX IF NOT EXISTS idx_url_key ON tenders(url_key)`)
+	backfillTenderURLKeys()
+	ensureUniqueTenderURLKeys()
 	initDefaultSources()
 	initDefaultTags()
 
@@ -385,24 +487,110 @@ func initDB() error {
 	return nil
 }
 
-func migrateTendersTable() {
-	migrations := []struct {
-		colName string
-		colType string
-	}{
-		{"source_id", "INTEGER"}, {"deadline", "TEXT"}, {"status", "TEXT DEFAULT 'active'"},
-		{"tags", "TEXT"}, {"note", "TEXT"}, {"reviewed_at", "TEXT"}, {"reviewed_by", "TEXT"}, {"attachments", "TEXT"},
-	}
+// columnMigration 表字段迁移定义
+type columnMigration struct {
+	colName string
+	colType string
+}
+
+// ensureColumns 为已有表补充缺失的字段
+func ensureColumns(table string, migrations []columnMigration) {
 	for _, m := range migrations {
 		var count int
-		row := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('tenders') WHERE name=?", m.colName)
+		row := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM pragma_table_info('%s') WHERE name=?", table), m.colName)
 		row.Scan(&count)
 		if count == 0 {
-			db.Exec(fmt.Sprintf("ALTER TABLE tenders ADD COLUMN %s %s", m.colName, m.colType))
+			db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, m.colName, m.colType))
+		}
+	}
+}
+
+func migrateTendersTable() {
+	ensureColumns("tenders", []columnMigration{
+		{"source_id", "INTEGER"}, {"deadline", "TEXT"}, {"status", "TEXT DEFAULT 'active'"},
+		{"tags", "TEXT"}, {"note", "TEXT"}, {"reviewed_at", "TEXT"}, {"reviewed_by", "TEXT"}, {"attachments", "TEXT"},
+		{"url_key", "TEXT"}, {"checked_at", "TEXT"},
+	})
+}
+
+func migrateSourcesTable() {
+	ensureColumns("sources", []columnMigration{
+		{"kind", "TEXT DEFAULT 'trace'"}, {"config", "TEXT"},
+		{"strip_params", "TEXT"},
+		{"max_items", "INTEGER DEFAULT 0"}, {"max_details", "INTEGER DEFAULT 0"},
+		{"delay_ms", "INTEGER DEFAULT 0"}, {"delay_jitter_ms", "INTEGER DEFAULT 0"},
+		{"incremental", "INTEGER DEFAULT 0"}, {"recheck_hours", "INTEGER DEFAULT 0"}, {"stop_after_known", "INTEGER DEFAULT 0"},
+		{"min_interval_ms", "INTEGER DEFAULT 0"}, {"max_concurrent_pages", "INTEGER DEFAULT 0"},
+		{"daily_request_budget", "INTEGER DEFAULT 0"}, {"quiet_hours", "TEXT"},
+		{"proxy", "TEXT"}, {"browser_mode", "TEXT"}, {"stealth", "TEXT"},
+		{"logged_out_selector", "TEXT"},
+	})
+}
+
+func migrateCollectTasksTable() {
+	ensureColumns("collect_tasks", []columnMigration{
+		{"limits", "TEXT"}, {"trace_versions", "TEXT"},
+	})
+}
+
+// backfillTenderURLKeys 为历史记录补充URL去重键。
+// URL 为空或无法解析的记录写入按 id 区分的占位键，不参与去重，也不会在每次启动时重复扫描
+func backfillTenderURLKeys() {
+	rows, err := db.Query("SELECT id, source_id, url FROM tenders WHERE url_key IS NULL OR url_key = ''")
+	if err != nil {
+		return
+	}
+	type pending struct {
+		id       int
+		sourceID sql.NullInt64
+		url      sql.NullString
+	}
+	var list []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.sourceID, &p.url); err == nil {
+			list = append(list, p)
+		}
+	}
+	rows.Close()
+
+	skipped := 0
+	for _, p := range list {
+		key := canonicalURLKey(p.url.String, sourceStripParams(int(p.sourceID.Int64)))
+		if key == "" {
+			key = fmt.Sprintf("nokey:%d", p.id)
+			skipped++
 		}
+		db.Exec("UPDATE tenders SET url_key = ? WHERE id = ?", key, p.id)
+	}
+	if len(list) > 0 {
+		log.Printf("🔑 已为 %d 条历史记录生成URL去重键（%d 条URL无效，已跳过）", len(list)-skipped, skipped)
+	}
+}
+
+// ensureUniqueTenderURLKeys 为去重键建立唯一索引，并发保存同一条目时由数据库拒绝重复插入。
+// 占位键（nokey:）不参与唯一约束；建索引前已存在的重复记录保留，后出现的改为占位键
+func ensureUniqueTenderURLKeys() {
+	res, err := db.Exec(`UPDATE tenders SET url_key = 'nokey:' || id
+		WHERE url_key NOT LIKE 'nokey:%' AND EXISTS (
+			SELECT 1 FROM tenders t WHERE t.url_key = tenders.url_key AND t.id < tenders.id)`)
+	if err != nil {
+		log.Printf("⚠️ 处理重复的URL去重键失败: %v", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("🔑 %d 条历史记录与已有记录的URL重复，已保留但不再参与去重", n)
+	}
+	if _, err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_url_key_unique ON tenders(url_key) WHERE url_key NOT LIKE 'nokey:%'`); err != nil {
+		log.Printf("⚠️ 创建URL去重唯一索引失败: %v", err)
 	}
 }
 
+// isUniqueConstraintError 是否为唯一约束冲突
+func isUniqueConstraintError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
 func initDefaultSources() {
 	sources := []struct {
 		name, code, category, baseURL, desc string
@@ -441,33 +629,55 @@ type SaveTenderResult struct {
 }
 
 func saveTender(tender *Tender) (*SaveTenderResult, error) {
+	// 规范化URL并计算去重键（剔除跟踪/会话参数，忽略scheme和参数顺序）
+	stripParams := sourceStripParams(tender.SourceID)
+	if normalized := normalizeURL(tender.URL, "", stripParams); normalized != "" {
+		tender.URL = normalized
+	}
+	urlKey := canonicalURLKey(tender.URL, stripParams)
+	if urlKey == "" {
+		// 空去重键会与其他无URL的记录合并
+		return nil, fmt.Errorf("招标信息URL无效: %q", tender.URL)
+	}
+	checkedAt := time.Now().Format("2006-01-02 15:04:05")
+
 	// 查询是否已存在
 	var existingID int
 	var existingAmount, existingDeadline, existingContact, existingPhone, existingContent, existingAttachments sql.NullString
+	lookup := func() error {
+		return db.QueryRow(`
+			SELECT id, amount, deadline, contact, phone, content, attachments
+			FROM tenders WHERE url_key = ? OR url = ? LIMIT 1
+		`, urlKey, tender.URL).Scan(&existingID, &existingAmount, &existingDeadline, &existingContact, &existingPhone, &existingContent, &existingAttachments)
+	}
 
-	err := db.QueryRow(`
-		SELECT id, amount, deadline, contact, phone, content, attachments
-		FROM tenders WHERE url = ?
-	`, tender.URL).Scan(&existingID, &existingAmount, &existingDeadline, &existingContact, &existingPhone, &existingContent, &existingAttachments)
-
+	err := lookup()
 	if err == sql.ErrNoRows {
 		// 不存在，插入新记录
 		_, err = db.Exec(`
-			INSERT INTO tenders (source_id, title, amount, publish_date, deadline, contact, phone, url, keywords, content, attachments, status, tags, note)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		`, tender.SourceID, tender.Title, tender.Amount, tender.PublishDate, tender.Deadline, tender.Contact, tender.Phone, tender.URL, tender.Keywords, tender.Content, tender.Attachments, tender.Status, tender.Tags, tender.Note)
-
-		if err != nil {
+			INSERT INTO tenders (source_id, title, amount, publish_date, deadline, contact, phone, url, url_key, keywords, content, attachments, status, tags, note, checked_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, tender.SourceID, tender.Title, tender.Amount, tender.PublishDate, tender.Deadline, tender.Contact, tender.Phone, tender.URL, urlKey, tender.Keywords, tender.Content, tender.Attachments, tender.Status, tender.Tags, tender.Note, checkedAt)
+
+		switch {
+		case isUniqueConstraintError(err):
+			// 其他任务在查询之后插入了同一条目，按已存在的记录处理
+			log.Printf("🔑 并发保存了同一条目，合并到已有记录: %s", tender.URL)
+			err = lookup()
+		case err != nil:
 			return nil, fmt.Errorf("插入失败: %v", err)
+		default:
+			return &SaveTenderResult{IsNew: true, Updated: false, Action: "created"}, nil
 		}
-
-		return &SaveTenderResult{IsNew: true, Updated: false, Action: "created"}, nil
 	}
 
 	if err != nil {
 		return nil, fmt.Errorf("查询失败: %v", err)
 	}
 
+	// 记录最近一次检查时间（增量采集据此决定是否重新抓取详情）
+	db.Exec("UPDATE tenders SET checked_at = ? WHERE id = ?", checkedAt, existingID)
+
 	// 记录已存在，检查是否需要更新
 	needsUpdate := false
 
@@ -712,13 +922,47 @@ func getSourceIDByCode(code string) int {
 	return id
 }
 
+// sourceColumns 读取采集源时使用的字段列表，与 scanSource 对应
+const sourceColumns = `id, name, code, category, COALESCE(base_url, ''), COALESCE(description, ''),
+	COALESCE(NULLIF(kind, ''), 'trace'), COALESCE(config, ''), COALESCE(strip_params, ''),
+	COALESCE(max_items, 0), COALESCE(max_details, 0), COALESCE(delay_ms, 0), COALESCE(delay_jitter_ms, 0),
+	COALESCE(incremental, 0), COALESCE(recheck_hours, 0), COALESCE(stop_after_known, 0),
+	COALESCE(min_interval_ms, 0), COALESCE(max_concurrent_pages, 0), COALESCE(daily_request_budget, 0), COALESCE(quiet_hours, ''),
+	COALESCE(proxy, ''), COALESCE(browser_mode, ''), COALESCE(stealth, ''), COALESCE(logged_out_selector, ''), is_active, created_at`
+
+// rowScanner 兼容 *sql.Row 和 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSource(row rowScanner) (Source, error) {
+	var s Source
+	err := row.Scan(&s.ID, &s.Name, &s.Code, &s.Category, &s.BaseURL, &s.Description,
+		&s.Kind, &s.Config, &s.StripParams,
+		&s.MaxItems, &s.MaxDetails, &s.DelayMs, &s.DelayJitter,
+		&s.Incremental, &s.RecheckHrs, &s.StopAfter,
+		&s.MinInterval, &s.MaxPages, &s.DailyBudget, &s.QuietHours,
+		&s.Proxy, &s.BrowserMode, &s.Stealth, &s.LoggedOutSelector, &s.IsActive, &s.CreatedAt)
+	return s, err
+}
+
+func getSourceByID(id int) (*Source, error) {
+	s, err := scanSource(db.QueryRow("SELECT "+sourceColumns+" FROM sources WHERE id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
 func getSourcesMap() map[int]Source {
 	sources := make(map[int]Source)
-	rows, _ := db.Query("SELECT id, name, code, category, base_url, description, is_active FROM sources")
+	rows, err := db.Query("SELECT " + sourceColumns + " FROM sources")
+	if err != nil {
+		return sources
+	}
 	defer rows.Close()
 	for rows.Next() {
-		var s Source
-		if err := rows.Scan(&s.ID, &s.Name, &s.Code, &s.Category, &s.BaseURL, &s.Description, &s.IsActive); err == nil {
+		if s, err := scanSource(rows); err == nil {
 			sources[s.ID] = s
 		}
 	}
@@ -792,15 +1036,14 @@ func exportTendersToCSV(w http.ResponseWriter, params TenderQueryParams) error {
 }
 
 func getAllSources() ([]Source, error) {
-	rows, err := db.Query("SELECT id, name, code, category, base_url, description, is_active, created_at FROM sources ORDER BY category, name")
+	rows, err := db.Query("SELECT " + sourceColumns + " FROM sources ORDER BY category, name")
 	if err != nil {
 		return []Source{}, err
 	}
 	defer rows.Close()
 	sources := []Source{}
 	for rows.Next() {
-		var s Source
-		if err := rows.Scan(&s.ID, &s.Name, &s.Code, &s.Category, &s.BaseURL, &s.Description, &s.IsActive, &s.CreatedAt); err == nil {
+		if s, err := scanSource(rows); err == nil {
 			sources = append(sources, s)
 		}
 	}
@@ -809,7 +1052,7 @@ func getAllSources() ([]Source, error) {
 
 // ==================== 采集任务管理 ====================
 
-func createCollectTask(sourceID int, keywords []string) (*CollectTask, error) {
+func createCollectTask(sourceID int, keywords []string, limits LimitOverrides) (*CollectTask, error) {
 	// 生成任务ID
 	taskID := fmt.Sprintf("task_%d_%d", sourceID, time.Now().Unix())
 
@@ -830,14 +1073,15 @@ func createCollectTask(sourceID int, keywords []string) (*CollectTask, error) {
 		Found:      0,
 		Saved:      0,
 		Message:    "任务已创建，等待执行",
+		Limits:     limits.JSON(),
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 	}
 
 	_, err := db.Exec(`
-		INSERT INTO collect_tasks (id, source_id, source_name, keywords, status, progress, found, saved, message, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, task.ID, task.SourceID, task.SourceName, task.Keywords, task.Status, task.Progress, task.Found, task.Saved, task.Message, task.CreatedAt, task.UpdatedAt)
+		INSERT INTO collect_tasks (id, source_id, source_name, keywords, status, progress, found, saved, message, limits, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, task.ID, task.SourceID, task.SourceName, task.Keywords, task.Status, task.Progress, task.Found, task.Saved, task.Message, task.Limits, task.CreatedAt, task.UpdatedAt)
 
 	if err != nil {
 		return nil, err
@@ -852,7 +1096,7 @@ func updateCollectTask(taskID string, updates map[string]interface{}) error {
 
 	allowedFields := map[string]bool{
 		"status": true, "progress": true, "found": true, "saved": true,
-		"message": true, "completed_at": true,
+		"message": true, "completed_at": true, "limits": true, "trace_versions": true,
 	}
 
 	for key, value := range updates {
@@ -873,10 +1117,10 @@ func getCollectTask(taskID string) (*CollectTask, error) {
 	var completedAt sql.NullString
 
 	err := db.QueryRow(`
-		SELECT id, source_id, source_name, keywords, status, progress, found, saved, message, created_at, updated_at, completed_at
+		SELECT id, source_id, source_name, keywords, status, progress, found, saved, message, COALESCE(limits, ''), COALESCE(trace_versions, ''), created_at, updated_at, completed_at
 		FROM collect_tasks WHERE id = ?
 	`, taskID).Scan(&task.ID, &task.SourceID, &task.SourceName, &task.Keywords, &task.Status,
-		&task.Progress, &task.Found, &task.Saved, &task.Message, &task.CreatedAt, &task.UpdatedAt, &completedAt)
+		&task.Progress, &task.Found, &task.Saved, &task.Message, &task.Limits, &task.TraceVersions, &task.CreatedAt, &task.UpdatedAt, &completedAt)
 
 	if err != nil {
 		return nil, err
@@ -895,7 +1139,7 @@ func getAllCollectTasks(limit int) ([]CollectTask, error) {
 	}
 
 	rows, err := db.Query(`
-		SELECT id, source_id, source_name, keywords, status, progress, found, saved, message, created_at, updated_at, completed_at
+		SELECT id, source_id, source_name, keywords, status, progress, found, saved, message, COALESCE(limits, ''), COALESCE(trace_versions, ''), created_at, updated_at, completed_at
 		FROM collect_tasks ORDER BY created_at DESC LIMIT ?
 	`, limit)
 
@@ -910,7 +1154,7 @@ func getAllCollectTasks(limit int) ([]CollectTask, error) {
 		var completedAt sql.NullString
 
 		if err := rows.Scan(&task.ID, &task.SourceID, &task.SourceName, &task.Keywords, &task.Status,
-			&task.Progress, &task.Found, &task.Saved, &task.Message, &task.CreatedAt, &task.UpdatedAt, &completedAt); err == nil {
+			&task.Progress, &task.Found, &task.Saved, &task.Message, &task.Limits, &task.TraceVersions, &task.CreatedAt, &task.UpdatedAt, &completedAt); err == nil {
 
 			if completedAt.Valid {
 				task.CompletedAt = completedAt.String
@@ -924,12 +1168,24 @@ func getAllCollectTasks(limit int) ([]CollectTask, error) {
 
 func saveSource(s *Source) error {
 	if s.ID > 0 {
-		_, err := db.Exec(`UPDATE sources SET name=?, code=?, category=?, base_url=?, description=?, is_active=? WHERE id=?`,
-			s.Name, s.Code, s.Category, s.BaseURL, s.Description, s.IsActive, s.ID)
+		_, err := db.Exec(`UPDATE sources SET name=?, code=?, category=?, base_url=?, description=?, kind=?, config=?, strip_params=?,
+			max_items=?, max_details=?, delay_ms=?, delay_jitter_ms=?, incremental=?, recheck_hours=?, stop_after_known=?,
+			min_interval_ms=?, max_concurrent_pages=?, daily_request_budget=?, quiet_hours=?,
+			proxy=?, browser_mode=?, stealth=?, logged_out_selector=?, is_active=? WHERE id=?`,
+			s.Name, s.Code, s.Category, s.BaseURL, s.Description, s.Kind, s.Config, s.StripParams,
+			s.MaxItems, s.MaxDetails, s.DelayMs, s.DelayJitter, s.Incremental, s.RecheckHrs, s.StopAfter,
+			s.MinInterval, s.MaxPages, s.DailyBudget, s.QuietHours,
+			s.Proxy, s.BrowserMode, s.Stealth, s.LoggedOutSelector, s.IsActive, s.ID)
 		return err
 	}
-	result, err := db.Exec(`INSERT INTO sources (name, code, category, base_url, description, is_active) VALUES (?, ?, ?, ?, ?, ?)`,
-		s.Name, s.Code, s.Category, s.BaseURL, s.Description, s.IsActive)
+	result, err := db.Exec(`INSERT INTO sources (name, code, category, base_url, description, kind, config, strip_params,
+		max_items, max_details, delay_ms, delay_jitter_ms, incremental, recheck_hours, stop_after_known,
+		min_interval_ms, max_concurrent_pages, daily_request_budget, quiet_hours,
+		proxy, browser_mode, stealth, logged_out_selector, is_active) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.Name, s.Code, s.Category, s.BaseURL, s.Description, s.Kind, s.Config, s.StripParams,
+		s.MaxItems, s.MaxDetails, s.DelayMs, s.DelayJitter, s.Incremental, s.RecheckHrs, s.StopAfter,
+		s.MinInterval, s.MaxPages, s.DailyBudget, s.QuietHours,
+		s.Proxy, s.BrowserMode, s.Stealth, s.LoggedOutSelector, s.IsActive)
 	if err != nil {
 		return err
 	}
@@ -999,680 +1255,294 @@ func parseTraceFile(content string) (*TraceFile, error) {
 		}
 	}
 
-	var chrome ChromeDevToolsRecording
-	if err := json.Unmarshal([]byte(content), &chrome); err != nil {
-		return nil, fmt.Errorf("无法解析JSON: %v", err)
-	}
-
-	trace.Name = chrome.Title
-	trace.URL = chrome.URL
-
-	// 智能推断轨迹类型
-	if strings.Contains(chrome.URL, "detail") || strings.Contains(chrome.Title, "详情") {
-		trace.Type = "detail"
-	} else {
-		trace.Type = "list"
+	// 自动识别 Chrome DevTools、Selenium IDE、Playwright、Puppeteer 录制
+	recording, format, err := traceconv.ParseAnyRecording([]byte(content))
+	if err != nil {
+		return nil, err
 	}
 
-	// 使用高级转换逻辑（与convert-trace工具一致）
-	trace.Steps = convertChromeStepsAdvanced(chrome.Steps, trace.Type, chrome.URL)
+	// 与 convert-trace 工具使用同一转换逻辑，轨迹类型根据标题和 URL 推断
+	converted := traceconv.Convert(recording, "")
 
-	log.Printf("📝 Chrome DevTools 格式已转换: %d 步骤 → %d 步骤", len(chrome.Steps), len(trace.Steps))
-	return &trace, nil
+	log.Printf("📝 %s 录制已转换: %d 步骤 → %d 步骤", format, len(recording.Steps), len(converted.Steps))
+	return converted, nil
 }
 
-// convertChromeStepsAdvanced 高级轨迹转换（整合convert-trace工具逻辑）
-func convertChromeStepsAdvanced(chromeSteps []ChromeDevToolsStep, traceType string, baseURL string) []TraceStep {
-	// 中间步骤结构
-	type intermediateStep struct {
-		Type     string
-		Selector string
-		Value    string
-		URL      string
-	}
-
-	var intermediate []intermediateStep
-	pendingChanges := make(map[string]string) // 合并同一输入框的多次change事件
-	var listSelector string
-	var listFieldInfo struct {
-		titleSelector string
-		dateSelector  string
-		urlSelector   string
-	}
-
-	// 刷新待处理的输入事件
-	flushPendingChanges := func() {
-		for selector, value := range pendingChanges {
-			if value != "" {
-				intermediate = append(intermediate, intermediateStep{
-					Type:     "input",
-					Selector: selector,
-					Value:    value,
-				})
-			}
-		}
-		pendingChanges = make(map[string]string)
+// ==================== 浏览器自动化 ====================
+
+// setupBrowser 启动浏览器进程：userDataDir 为空时使用临时用户目录，proxyURL 非空时通过代理访问
+func setupBrowser(proxyURL, userDataDir string) (*rod.Browser, *launcher.Launcher, error) {
+	l := launcher.New().Headless(browserHeadless)
+	applyLaunchStealth(l)
+	if userDataDir != "" {
+		os.MkdirAll(userDataDir, 0755)
+		l = l.UserDataDir(userDataDir)
 	}
+	username, password := applyBrowserProxy(l, proxyURL)
 
-	// 第一遍：分析步骤，检测列表结构
-	for _, step := range chromeSteps {
-		if step.Type == "click" {
-			selector := extractBestSelector(step.Selectors)
-			// 检测列表行点击（无论是否导致页面跳转）
-			if isListRowClick(selector) {
-				listSelector = inferListSelector(selector)
-				listFieldInfo = inferListFields(step.Selectors)
-				log.Printf("🔍 检测到列表行点击: selector=%s", selector)
-			}
-		}
+	url, err := l.Launch()
+	if err != nil {
+		return nil, nil, fmt.Errorf("启动浏览器失败: %v", err)
+	}
+	browser := rod.New().ControlURL(url)
+	if err := browser.Connect(); err != nil {
+		l.Kill()
+		return nil, nil, fmt.Errorf("连接浏览器失败: %v", err)
 	}
 
-	// 第二遍：转换步骤（保守策略：保留为主，删除为辅）
-	for i, step := range chromeSteps {
-		// 只跳过明确无用的步骤
-		if shouldSkipStep(step.Type) {
-			continue
+	if username != "" {
+		if err := handleProxyAuth(browser, username, password); err != nil {
+			browser.Close()
+			return nil, nil, err
 		}
+	}
 
-		switch step.Type {
-		case "navigate":
-			flushPendingChanges()
-			intermediate = append(intermediate, intermediateStep{
-				Type: "navigate",
-				URL:  step.URL,
-			})
+	log.Println("✅ 浏览器启动成功")
+	return browser, l, nil
+}
 
-		case "click":
-			selector := extractBestSelector(step.Selectors)
-			if selector == "" {
-				continue
-			}
+func executeTrace(ctx context.Context, browser *rod.Browser, trace *TraceFile, params map[string]string, solver *CaptchaSolver) (interface{}, error) {
+	if trace.Mode == "http" {
+		return executeHTTPTrace(ctx, trace, params)
+	}
+	if browser == nil {
+		return nil, fmt.Errorf("浏览器未启动")
+	}
 
-			// 只跳过会导致页面跳转的列表行点击（后面紧跟navigate）
-			if i < len(chromeSteps)-1 && chromeSteps[i+1].Type == "navigate" {
-				if isListRowClick(selector) {
-					continue
-				}
-			}
+	run, done := newBrowserRun(ctx, browser, trace, params, solver)
+	defer done()
 
-			// 检查后续是否有同一元素的change事件
-			// 如果有，则跳过click（change会被转为input）
-			// 需要跳过中间被过滤的步骤（keyDown/keyUp等）
-			skipClick := false
-			for j := i + 1; j < len(chromeSteps); j++ {
-				futureStep := chromeSteps[j]
-				// 跳过会被过滤的步骤
-				if shouldSkipStep(futureStep.Type) {
-					continue
-				}
-				// 找到下一个有效步骤
-				if futureStep.Type == "change" {
-					nextSelector := extractBestSelector(futureStep.Selectors)
-					if nextSelector == selector {
-						skipClick = true
-					}
-				}
-				// 只检查紧接着的有效步骤
-				break
-			}
-
-			if !skipClick {
-				flushPendingChanges()
-				intermediate = append(intermediate, intermediateStep{
-					Type:     "click",
-					Selector: selector,
-				})
-			}
+	if err := run.runSteps(trace.Steps); err != nil {
+		return nil, err
+	}
 
-		case "change":
-			selector := extractBestSelector(step.Selectors)
-			if selector != "" {
-				pendingChanges[selector] = step.Value
-			}
+	// 登录轨迹执行完后页面仍显示未登录，说明登录失败
+	if trace.Type == "login" {
+		if err := checkLoggedOut(ctx, run.page); err != nil {
+			return nil, err
 		}
 	}
 
-	flushPendingChanges()
-
-	// 第三遍：构建最终步骤并优化
-	var result []TraceStep
-
-	for i, step := range intermediate {
-		switch step.Type {
-		case "navigate":
-			result = append(result, TraceStep{
-				Action: "navigate",
-				URL:    step.URL,
-			})
-			result = append(result, TraceStep{
-				Action:   "wait",
-				WaitTime: 2000,
-			})
-
-		case "click":
-			// 修正查询按钮选择器（去掉 > span）
-			selector := fixSearchButtonSelector(step.Selector)
-			result = append(result, TraceStep{
-				Action:   "click",
-				Selector: selector,
-			})
-			// 点击后的等待时间
-			waitTime := 2000 // 默认2秒，足够动画和元素加载
-			if isSearchButton(selector) {
-				waitTime = 3000 // 查询按钮等待3秒
-			}
-			result = append(result, TraceStep{
-				Action:   "wait",
-				WaitTime: waitTime,
-			})
-
-		case "input":
-			value := step.Value
-
-			// 智能识别关键词输入框
-			if isKeywordInputField(step.Selector) {
-				value = "{{.Keyword}}"
-			}
-
-			// 智能识别验证码输入
-			if isCaptchaInput(step.Selector, step.Value) {
-				// 尝试智能查找验证码图片选择器
-				imgSelector := ""
-
-				// 方法1：查找包含 img/captcha/验证码 的选择器
-				for j := i - 1; j >= 0; j-- {
-					if intermediate[j].Type == "click" {
-						sel := intermediate[j].Selector
-						if strings.Contains(sel, "img") ||
-						   strings.Contains(sel, "captcha") ||
-						   strings.Contains(sel, "验证码") {
-							imgSelector = sel
-							// 移除这个点击步骤
-							if len(result) > 0 && result[len(result)-1].Action == "click" {
-								result = result[:len(result)-1]
-								if len(result) > 0 && result[len(result)-1].Action == "wait" {
-									result = result[:len(result)-1]
-								}
-							}
-							break
-						}
-					}
-				}
+	return run.data, nil
+}
 
-				// 方法2：使用通用选择器
-				if imgSelector == "" {
-					imgSelector = "img[src*='captcha'], img[alt*='验证码'], img[title*='验证码']"
-					log.Printf("⚠️ 未找到验证码图片选择器，使用通用选择器")
-				}
+// newBrowserRun 打开新标签页准备执行轨迹，返回的函数用于结束执行：释放主机并发名额、关闭打开过的标签页
+func newBrowserRun(ctx context.Context, browser *rod.Browser, trace *TraceFile, params map[string]string, solver *CaptchaSolver) (*browserRun, func()) {
+	page := browser.MustPage()
 
-				result = append(result, TraceStep{
-					Action:        "captcha",
-					ImageSelector: imgSelector,
-					InputSelector: step.Selector,
-				})
-				// 验证码输入后添加等待，让页面响应
-				result = append(result, TraceStep{
-					Action:   "wait",
-					WaitTime: 2000, // 2秒
-				})
-			} else {
-				result = append(result, TraceStep{
-					Action:   "input",
-					Selector: step.Selector,
-					Value:    value,
-				})
-			}
-		}
+	stealth := stealthFrom(ctx)
+	if err := applyPageStealth(page, stealth); err != nil {
+		log.Printf("⚠️ 反检测设置失败: %v", err)
 	}
 
-	// 自动添加数据提取步骤
-	if traceType == "list" {
-		// 使用分析出的列表结构
-		if listSelector == "" {
-			listSelector = "tbody tr" // 默认值
+	// 设置全局超时时间为30秒
+	run := &browserRun{
+		ctx:     ctx,
+		page:    page.Timeout(30 * time.Second),
+		trace:   trace,
+		vars:    copyParams(params),
+		solver:  solver,
+		stealth: stealth,
+		browser: browser,
+		root:    page,
+	}
+	run.tab = run.page
+	return run, func() {
+		// 页面占用的主机并发名额，首次导航时获取
+		if run.releasePage != nil {
+			run.releasePage()
+		}
+		run.closeExtraTabs()
+		page.Close()
+	}
+}
+
+// browserRun 浏览器轨迹的一次执行状态
+type browserRun struct {
+	ctx         context.Context
+	page        *rod.Page
+	trace       *TraceFile
+	vars        map[string]string // 轨迹参数和 set 步骤设置的变量
+	solver      *CaptchaSolver
+	stealth     StealthOptions
+	releasePage func()
+	item        *rod.Element // foreach 遍历元素时的当前元素，步骤中用 @item 引用
+	data        interface{}
+	browser     *rod.Browser
+	root        *rod.Page   // 轨迹开始时的标签页
+	tab         *rod.Page   // 当前标签页，switch_frame 进入 iframe 时 page 指向 iframe
+	extraTabs   []*rod.Page // switch_tab 切换过的标签页，执行结束后关闭
+}
+
+// runSteps 依次执行步骤，if/foreach 的子步骤递归执行
+func (r *browserRun) runSteps(steps []TraceStep) error {
+	for i, step := range steps {
+		log.Printf("执行步骤 %d/%d: %s", i+1, len(steps), step.Action)
+
+		if err := r.runStep(step); err != nil {
+			return err
 		}
-		fields := map[string]string{
-			"title": listFieldInfo.titleSelector,
-			"date":  listFieldInfo.dateSelector,
-			"url":   listFieldInfo.urlSelector,
+		if isFlowAction(step.Action) {
+			continue
 		}
-		if fields["title"] == "" {
-			fields = map[string]string{
-				"title": "td:nth-child(1) span",
-				"date":  "td:nth-child(3)",
-				"url":   "td:nth-child(1) span",
+		if r.stealth.Human {
+			if err := humanPause(r.ctx, 400, 1500); err != nil {
+				return err
 			}
-		}
-
-		result = append(result, TraceStep{
-			Action:   "extract",
-			Type:     "list",
-			Selector: listSelector,
-			Fields:   fields,
-		})
-		log.Printf("📊 生成 extract 步骤: selector=%s, fields=%+v", listSelector, fields)
-	} else if traceType == "detail" {
-		result = append(result, TraceStep{
-			Action: "extract",
-			Type:   "detail",
-			Fields: map[string]string{
-				"amount":  "span:contains('预算金额')",
-				"contact": "span:contains('联系人')",
-				"phone":   "span:contains('联系电话')",
-			},
-		})
-	}
-
-	return result
-}
-
-// shouldSkipStep 判断是否应跳过该步骤
-func shouldSkipStep(stepType string) bool {
-	skipTypes := []string{"setViewport", "keyDown", "keyUp", "scroll"}
-	for _, t := range skipTypes {
-		if stepType == t {
-			return true
+		} else {
+			time.Sleep(300 * time.Millisecond)
 		}
 	}
-	return false
+	return nil
 }
 
-// extractBestSelector 智能选择最佳选择器
-func extractBestSelector(selectors [][]string) string {
-	if len(selectors) == 0 {
-		return ""
+func (r *browserRun) runStep(step TraceStep) error {
+	ctx, page, params := r.ctx, r.page, r.vars
+	if ctx.Err() != nil {
+		return ctx.Err()
 	}
 
-	var selectedSelector string
-	var fallbackSelector string // 降级选择器（即使是动态的）
-	var ariaPlaceholder string   // 从 aria 选择器提取的 placeholder
-	var priority int             // 优先级：3=ID, 2=CSS, 1=XPath, 0=其他
-
-	for _, selectorGroup := range selectors {
-		if len(selectorGroup) == 0 {
-			continue
-		}
-		sel := selectorGroup[0]
-
-		// 提取 aria 选择器中的 placeholder 信息
-		if strings.HasPrefix(sel, "aria/") {
-			ariaText := strings.TrimPrefix(sel, "aria/")
-			if strings.Contains(ariaText, "请输入") {
-				ariaPlaceholder = ariaText
+	switch step.Action {
+	case "navigate":
+		url := replaceParams(step.URL, params)
+		if r.releasePage == nil {
+			release, err := acquirePage(ctx, url)
+			if err != nil {
+				return err
 			}
-			continue
+			r.releasePage = release
 		}
-
-		// 跳过 text 选择器
-		if strings.HasPrefix(sel, "text/") {
-			continue
+		if err := acquireRequest(ctx, url); err != nil {
+			return err
 		}
-
-		// 处理 Pierce 选择器
-		if strings.HasPrefix(sel, "pierce/") {
-			sel = strings.TrimPrefix(sel, "pierce/")
+		if err := page.Navigate(url); err != nil {
+			return fmt.Errorf("导航失败: %v", err)
 		}
-
-		// 保存第一个可用选择器作为降级选项
-		if fallbackSelector == "" {
-			fallbackSelector = sel
+		page.MustWaitLoad()
+		if status := navigationStatus(page); isProxyBlocked(status) {
+			return fmt.Errorf("导航失败: %w（HTTP %d）", errProxyBlocked, status)
 		}
-
-		// XPath 选择器（需排除基于动态 ID 的 XPath）
-		if strings.HasPrefix(sel, "xpath") {
-			// 检查 XPath 中是否包含动态 ID
-			isDynamic := false
-			dynamicPrefixes := []string{"el-id-", "mui-", "rc-", "headlessui-"}
-			for _, prefix := range dynamicPrefixes {
-				if strings.Contains(sel, prefix) {
-					isDynamic = true
-					break
-				}
+		if r.trace.Type != "login" {
+			if err := checkLoggedOut(ctx, page); err != nil {
+				return err
 			}
-			// 只使用稳定的 XPath
-			if !isDynamic && priority < 1 {
-				selectedSelector = sel
-				priority = 1
-			}
-			continue
 		}
-
-		// ID 选择器（需排除动态生成的 ID）
-		if strings.Contains(sel, "#") && !strings.Contains(sel, "xpath") {
-			// 检查是否是动态生成的 ID（Element UI、Material UI、React 等）
-			isDynamic := false
-			dynamicPrefixes := []string{"el-id-", "mui-", "rc-", "headlessui-"}
-			for _, prefix := range dynamicPrefixes {
-				if strings.Contains(sel, prefix) {
-					isDynamic = true
-					break
-				}
-			}
-			// 只有稳定的 ID 才使用最高优先级
-			if !isDynamic {
-				selectedSelector = sel
-				priority = 3
-				break
-			}
+	case "click":
+		selector := replaceParams(step.Selector, params)
+		log.Printf("🔍 查找元素: %s", selector)
+		elem, err := r.findTarget(selector, step.Candidates)
+		if err != nil {
+			return fmt.Errorf("找不到点击元素 '%s': %v", selector, err)
 		}
 
-		// 标准 CSS 选择器（也要排除包含动态ID的）
-		if priority < 2 {
-			// 检查是否包含动态 ID
-			containsDynamicId := false
-			dynamicPrefixes := []string{"el-id-", "mui-", "rc-", "headlessui-"}
-			for _, prefix := range dynamicPrefixes {
-				if strings.Contains(sel, prefix) {
-					containsDynamicId = true
-					break
-				}
-			}
-			// 只使用不包含动态ID的CSS选择器
-			if !containsDynamicId {
-				selectedSelector = sel
-				priority = 2
-			}
+		// 等待元素可见和稳定
+		if err := elem.WaitVisible(); err != nil {
+			log.Printf("⚠️ 元素不可见: %v", err)
 		}
-	}
-
-	// 如果没有找到稳定的选择器，尝试从 aria 生成
-	if selectedSelector == "" && ariaPlaceholder != "" {
-		// 从 aria 文本生成基于 placeholder 的选择器
-		placeholderText := strings.TrimPrefix(ariaPlaceholder, "请输入")
-		if placeholderText != "" {
-			generatedSelector := fmt.Sprintf("input[placeholder*=\"%s\"]", placeholderText)
-			log.Printf("✨ 从 aria 生成稳定选择器: %s", generatedSelector)
-			return generatedSelector
+		if err := elem.WaitStable(500 * time.Millisecond); err != nil {
+			log.Printf("⚠️ 元素不稳定: %v", err)
 		}
-	}
-
-	// 如果没有找到稳定的选择器，使用降级选择器
-	if selectedSelector == "" && fallbackSelector != "" {
-		log.Printf("⚠️ 未找到稳定选择器，使用降级选择器: %s (可能包含动态ID，需手动验证)", fallbackSelector)
-		return fallbackSelector
-	}
-
-	return selectedSelector
-}
 
-// isListRowClick 判断是否是列表行点击
-func isListRowClick(selector string) bool {
-	patterns := []string{
-		"tr:nth-of-type", "tbody tr", "td:nth-of-type",
-		"li:nth-of-type", ".list-item", ".item",
-	}
-	for _, p := range patterns {
-		if strings.Contains(selector, p) {
-			return true
+		// 尝试滚动到元素可见位置
+		if err := elem.ScrollIntoView(); err != nil {
+			log.Printf("⚠️ 滚动失败: %v", err)
 		}
-	}
-	return false
-}
 
-// inferListSelector 从行选择器推断列表容器选择器
-func inferListSelector(rowSelector string) string {
-	if strings.Contains(rowSelector, "tr:nth-of-type") || strings.Contains(rowSelector, "tbody") {
-		return "tbody tr"
-	}
-	if strings.Contains(rowSelector, "li:nth-of-type") {
-		return "ul li"
-	}
-	return "tbody tr"
-}
-
-// inferListFields 从选择器推断列表字段映射
-func inferListFields(selectors [][]string) struct {
-	titleSelector string
-	dateSelector  string
-	urlSelector   string
-} {
-	result := struct {
-		titleSelector string
-		dateSelector  string
-		urlSelector   string
-	}{
-		titleSelector: "td:nth-child(1) span",
-		dateSelector:  "td:nth-child(3)",
-		urlSelector:   "td:nth-child(1) span",
-	}
-
-	// 首先尝试从 CSS 选择器中解析（更可靠）
-	for _, selectorGroup := range selectors {
-		for _, sel := range selectorGroup {
-			// CSS选择器: tr:nth-of-type(1) > td:nth-of-type(3) > span
-			if strings.Contains(sel, "td:nth-of-type(") {
-				// 提取列号
-				if idx := strings.Index(sel, "td:nth-of-type("); idx != -1 {
-					rest := sel[idx+15:] // 跳过 "td:nth-of-type("
-					if end := strings.Index(rest, ")"); end != -1 {
-						var colNum int
-						if _, err := fmt.Sscanf(rest[:end], "%d", &colNum); err == nil {
-							result.titleSelector = fmt.Sprintf("td:nth-child(%d) span", colNum)
-							result.urlSelector = fmt.Sprintf("td:nth-child(%d) span", colNum)
-							log.Printf("🔍 从CSS解析列字段: col=%d, selector=%s", colNum, sel)
-							return result
-						}
-					}
-				}
+		if r.stealth.Human {
+			if err := humanMoveTo(page, elem); err != nil {
+				log.Printf("⚠️ 鼠标移动失败: %v", err)
+			}
+			if err := humanPause(ctx, 150, 600); err != nil {
+				return err
 			}
 		}
-	}
 
-	// 回退：从 xpath 中解析列索引
-	for _, selectorGroup := range selectors {
-		for _, sel := range selectorGroup {
-			if strings.HasPrefix(sel, "xpath") && strings.Contains(sel, "/td[") {
-				// 解析: xpath///.../td[3]/...
-				if idx := strings.Index(sel, "/td["); idx != -1 {
-					rest := sel[idx+4:]
-					if end := strings.Index(rest, "]"); end != -1 {
-						var colNum int
-						if _, err := fmt.Sscanf(rest[:end], "%d", &colNum); err == nil {
-							result.titleSelector = fmt.Sprintf("td:nth-child(%d) span", colNum)
-							result.urlSelector = fmt.Sprintf("td:nth-child(%d) span", colNum)
-							log.Printf("🔍 从XPath解析列字段: col=%d, xpath=%s", colNum, sel)
-							return result
-						}
-					}
-				}
+		if err := elem.Click(proto.InputMouseButtonLeft, 1); err != nil {
+			return fmt.Errorf("点击失败: %v", err)
+		}
+		time.Sleep(500 * time.Millisecond)
+		if r.trace.Type != "login" {
+			if err := checkLoggedOut(ctx, page); err != nil {
+				return err
 			}
 		}
-	}
-
-	log.Printf("⚠️ 列字段解析失败，使用默认值")
-	return result
-}
-
-// isInputClick 判断是否是输入框点击
-func isInputClick(selector string) bool {
-	return strings.Contains(selector, "input") ||
-		strings.Contains(selector, "[role=\"textbox\"]")
-}
-
-// isSearchButton 判断是否是查询按钮
-func isSearchButton(selector string) bool {
-	return strings.Contains(selector, "button") &&
-		(strings.Contains(selector, "primary") ||
-			strings.Contains(selector, "search") ||
-			strings.Contains(selector, "查询"))
-}
-
-// fixSearchButtonSelector 修正查询按钮选择器
-// 如果选择器指向 button > span，改为指向 button 本身
-func fixSearchButtonSelector(selector string) string {
-	if !isSearchButton(selector) {
-		return selector
-	}
-
-	// 如果选择器以 > span 或 > * 结尾，去掉子元素选择器
-	if strings.HasSuffix(selector, "> span") {
-		return strings.TrimSuffix(selector, "> span")
-	}
-	if strings.HasSuffix(selector, " > span") {
-		return strings.TrimSuffix(selector, " > span")
-	}
-	if strings.Contains(selector, " > span:") {
-		// button.class > span:nth-of-type(1) -> button.class
-		if idx := strings.Index(selector, " > span"); idx > 0 {
-			return selector[:idx]
+	case "input":
+		selector := replaceParams(step.Selector, params)
+		value := replaceParams(step.Value, params)
+		log.Printf("🔍 查找输入框: %s", selector)
+		elem, err := r.findTarget(selector, step.Candidates)
+		if err != nil {
+			return fmt.Errorf("找不到输入元素 '%s': %v", selector, err)
 		}
-	}
-
-	return selector
-}
-
-// isKeywordInputField 判断是否是关键词输入框
-func isKeywordInputField(selector string) bool {
-	keywords := []string{"标题", "关键词", "keyword", "title", "搜索", "search"}
-	selectorLower := strings.ToLower(selector)
-	for _, kw := range keywords {
-		if strings.Contains(selectorLower, kw) {
-			return true
+		if err := elem.SelectAllText(); err != nil {
+			log.Printf("⚠️ SelectAllText 失败（可能是空输入框）: %v", err)
 		}
-	}
-	return false
-}
-
-// isCaptchaInput 判断是否是验证码输入
-func isCaptchaInput(selector string, value string) bool {
-	if strings.Contains(selector, "验证码") || strings.Contains(selector, "captcha") {
-		return true
-	}
-	// 4位数字/字母组合通常是验证码
-	if len(value) == 4 && !strings.Contains(value, " ") {
-		return true
-	}
-	return false
-}
-
-// ==================== 浏览器自动化 ====================
-
-func setupBrowser() (*rod.Browser, error) {
-	var l *launcher.Launcher
-	userDataDir := filepath.Join(dataDir, "browser-data")
-	os.MkdirAll(userDataDir, 0755)
-
-	if browserHeadless {
-		l = launcher.New().Headless(true).UserDataDir(userDataDir)
-	} else {
-		l = launcher.New().Headless(false).UserDataDir(userDataDir)
-	}
-
-	url := l.MustLaunch()
-	browser := rod.New().ControlURL(url).MustConnect()
-
-	log.Println("✅ 浏览器启动成功")
-	return browser, nil
-}
-
-func executeTrace(browser *rod.Browser, trace *TraceFile, params map[string]string, solver *CaptchaSolver) (interface{}, error) {
-	page := browser.MustPage()
-	defer page.Close()
-
-	// 设置全局超时时间为30秒
-	page = page.Timeout(30 * time.Second)
-
-	var extractedData interface{}
-
-	for i, step := range trace.Steps {
-		log.Printf("执行步骤 %d/%d: %s", i+1, len(trace.Steps), step.Action)
-
-		switch step.Action {
-		case "navigate":
-			url := replaceParams(step.URL, params)
-			if err := page.Navigate(url); err != nil {
-				return nil, fmt.Errorf("导航失败: %v", err)
-			}
-			page.MustWaitLoad()
-		case "click":
-			selector := replaceParams(step.Selector, params)
-			log.Printf("🔍 查找元素: %s", selector)
-			elem, err := page.Element(selector)
+		if r.stealth.Human {
+			err = humanType(ctx, elem, value)
+		} else {
+			err = elem.Input(value)
+		}
+		if err != nil {
+			return fmt.Errorf("输入失败: %v", err)
+		}
+	case "wait":
+		if step.WaitTime > 0 {
+			time.Sleep(time.Duration(step.WaitTime) * time.Millisecond)
+		}
+		if step.WaitForVisible != "" {
+			log.Printf("🔍 等待元素可见: %s", step.WaitForVisible)
+			elem, err := r.findTarget(step.WaitForVisible, step.Candidates)
 			if err != nil {
-				return nil, fmt.Errorf("找不到点击元素 '%s': %v", selector, err)
+				return fmt.Errorf("等待元素失败 '%s': %v", step.WaitForVisible, err)
 			}
-
-			// 等待元素可见和稳定
 			if err := elem.WaitVisible(); err != nil {
-				log.Printf("⚠️ 元素不可见: %v", err)
-			}
-			if err := elem.WaitStable(500 * time.Millisecond); err != nil {
-				log.Printf("⚠️ 元素不稳定: %v", err)
-			}
-
-			// 尝试滚动到元素可见位置
-			if err := elem.ScrollIntoView(); err != nil {
-				log.Printf("⚠️ 滚动失败: %v", err)
-			}
-
-			if err := elem.Click(proto.InputMouseButtonLeft, 1); err != nil {
-				return nil, fmt.Errorf("点击失败: %v", err)
-			}
-			time.Sleep(500 * time.Millisecond)
-		case "input":
-			selector := replaceParams(step.Selector, params)
-			value := replaceParams(step.Value, params)
-			log.Printf("🔍 查找输入框: %s", selector)
-			elem, err := page.Element(selector)
-			if err != nil {
-				return nil, fmt.Errorf("找不到输入元素 '%s': %v", selector, err)
-			}
-			if err := elem.SelectAllText(); err != nil {
-				log.Printf("⚠️ SelectAllText 失败（可能是空输入框）: %v", err)
-			}
-			if err := elem.Input(value); err != nil {
-				return nil, fmt.Errorf("输入失败: %v", err)
-			}
-		case "wait":
-			if step.WaitTime > 0 {
-				time.Sleep(time.Duration(step.WaitTime) * time.Millisecond)
-			}
-			if step.WaitForVisible != "" {
-				log.Printf("🔍 等待元素可见: %s", step.WaitForVisible)
-				elem, err := page.Element(step.WaitForVisible)
-				if err != nil {
-					return nil, fmt.Errorf("等待元素失败 '%s': %v", step.WaitForVisible, err)
-				}
-				if err := elem.WaitVisible(); err != nil {
-					return nil, fmt.Errorf("元素未变为可见: %v", err)
-				}
-			}
-		case "captcha":
-			if step.ImageSelector == "" || step.InputSelector == "" {
-				return nil, fmt.Errorf("captcha action 缺少必要参数: image_selector 或 input_selector")
-			}
-			captchaText, err := handleCaptcha(page, step.ImageSelector, solver)
-			if err != nil {
-				return nil, fmt.Errorf("验证码处理失败: %v", err)
-			}
-			// 输入验证码
-			elem, err := page.Element(step.InputSelector)
-			if err != nil {
-				return nil, fmt.Errorf("找不到验证码输入框 '%s': %v", step.InputSelector, err)
-			}
-			if err := elem.SelectAllText(); err != nil {
-				log.Printf("⚠️ SelectAllText 失败: %v", err)
+				return fmt.Errorf("元素未变为可见: %v", err)
 			}
-			if err := elem.Input(captchaText); err != nil {
-				return nil, fmt.Errorf("输入验证码失败: %v", err)
-			}
-			log.Printf("✅ 验证码已输入")
-		case "extract":
-			if step.Type == "list" {
-				extractedData = extractList(page, step)
-			} else if step.Type == "detail" {
-				extractedData = extractDetail(page, step)
+		}
+	case "captcha":
+		if step.ImageSelector == "" || step.InputSelector == "" {
+			return fmt.Errorf("captcha action 缺少必要参数: image_selector 或 input_selector")
+		}
+		captchaText, err := handleCaptcha(page, step.ImageSelector, r.solver)
+		if err != nil {
+			return fmt.Errorf("验证码处理失败: %v", err)
+		}
+		// 输入验证码
+		elem, err := findElement(page, step.InputSelector)
+		if err != nil {
+			return fmt.Errorf("找不到验证码输入框 '%s': %v", step.InputSelector, err)
+		}
+		if err := elem.SelectAllText(); err != nil {
+			log.Printf("⚠️ SelectAllText 失败: %v", err)
+		}
+		if err := elem.Input(captchaText); err != nil {
+			return fmt.Errorf("输入验证码失败: %v", err)
+		}
+		log.Printf("✅ 验证码已输入")
+	case "extract":
+		if step.Type == "list" {
+			r.data = mergeExtracted(r.data, extractList(page, step, extractProbeFrom(r.ctx)))
+			if step.Pagination != nil {
+				return r.paginate(step)
 			}
+		} else if step.Type == "detail" {
+			r.data = extractDetail(page, step)
+		}
+	default:
+		if isPageAction(step.Action) {
+			return r.runPageAction(step)
 		}
-		time.Sleep(300 * time.Millisecond)
+		if handled, err := runFlowStep(step, r, r.runSteps); handled {
+			return err
+		}
+		log.Printf("⚠️ 未知步骤类型: %s，已跳过", step.Action)
 	}
-
-	return extractedData, nil
+	return nil
 }
 
 func handleCaptcha(page *rod.Page, imageSelector string, solver *CaptchaSolver) (string, error) {
 	log.Printf("🔍 查找验证码图片: %s", imageSelector)
-	imgElem, err := page.Element(imageSelector)
+	imgElem, err := findElement(page, imageSelector)
 	if err != nil {
 		return "", fmt.Errorf("找不到验证码图片元素 '%s': %v", imageSelector, err)
 	}
@@ -1701,7 +1571,8 @@ func handleCaptcha(page *rod.Page, imageSelector string, solver *CaptchaSolver)
 	return "", fmt.Errorf("验证码服务不可用，无法继续采集 (验证码已保存至 %s)", captchaPath)
 }
 
-func extractList(page *rod.Page, step TraceStep) []map[string]string {
+// extractList 提取列表，probe 不为空时记录匹配行数和有效条目数用于轨迹健康检查
+func extractList(page *rod.Page, step TraceStep, probe *extractProbe) []map[string]string {
 	var results []map[string]string
 	time.Sleep(2 * time.Second)
 
@@ -1711,16 +1582,18 @@ func extractList(page *rod.Page, step TraceStep) []map[string]string {
 	if step.XPath != "" {
 		rows, err = page.ElementsX(step.XPath)
 	} else {
-		rows, err = page.Elements(step.Selector)
+		rows, err = findElements(page, step.Selector)
 	}
 
 	if err != nil {
 		log.Printf("提取失败: %v", err)
+		probe.record(step, page.MustInfo().URL, 0, 0, func() string { return pageText(page) })
 		return results
 	}
 
 	log.Printf("找到 %d 条记录", len(rows))
 	listURL := page.MustInfo().URL
+	defer func() { probe.record(step, listURL, len(rows), len(results), func() string { return pageText(page) }) }()
 
 	for _, row := range rows {
 		item := make(map[string]string)
@@ -1735,8 +1608,7 @@ func extractList(page *rod.Page, step TraceStep) []map[string]string {
 				}
 				continue
 			}
-			if elem, err := row.Element(selector); err == nil {
-				text := elem.MustText()
+			if text, ok := extractField(row, selector, listURL); ok {
 				item[field] = text
 				if text != "" {
 					hasValidData = true
@@ -1744,11 +1616,16 @@ func extractList(page *rod.Page, step TraceStep) []map[string]string {
 			}
 		}
 
+		// 相对地址、javascript: 链接等统一解析为绝对地址
+		if item["url"] != "" {
+			item["url"] = normalizeURL(item["url"], listURL, nil)
+		}
+
 		if clickSelector != "" && hasValidData {
-			if clickElem, err := row.Element(clickSelector); err == nil {
+			if clickElem, err := queryElement(row, parseFieldSelector(clickSelector)); err == nil {
 				url := extractURLByClick(page, clickElem, listURL)
-				if url != "" {
-					item["url"] = url
+				if url != "" && url != listURL {
+					item["url"] = normalizeURL(url, listURL, nil)
 				}
 			}
 		}
@@ -1760,8 +1637,8 @@ func extractList(page *rod.Page, step TraceStep) []map[string]string {
 			log.Printf("  跳过无效数据: hasValidData=%v, url=%s", hasValidData, item["url"])
 		}
 
-		if len(results) >= 10 {
-			log.Printf("已达到采集上限 10 条")
+		if step.MaxItems > 0 && len(results) >= step.MaxItems {
+			log.Printf("已达到采集上限 %d 条", step.MaxItems)
 			break
 		}
 	}
@@ -1802,19 +1679,21 @@ func extractURLByClick(page *rod.Page, elem *rod.Element, returnURL string) stri
 func extractDetail(page *rod.Page, step TraceStep) map[string]string {
 	result := make(map[string]string)
 	time.Sleep(2 * time.Second)
+	pageURL := page.MustInfo().URL
 
 	for field, selector := range step.Fields {
-		if elem, err := page.Element(selector); err == nil {
-			result[field] = elem.MustText()
+		if text, ok := extractField(page, selector, pageURL); ok {
+			result[field] = text
 		}
 	}
 
 	for field, selector := range step.MultiFields {
-		if elems, err := page.Elements(selector); err == nil {
+		if elems, err := findElements(page, selector); err == nil {
 			var links []map[string]string
 			for _, elem := range elems {
 				if href, _ := elem.Attribute("href"); href != nil && *href != "" {
-					links = append(links, map[string]string{"url": *href, "name": elem.MustText()})
+					name, _ := elem.Text()
+					links = append(links, map[string]string{"url": resolveURL(pageURL, *href), "name": strings.TrimSpace(name)})
 				}
 			}
 			if len(links) > 0 {
@@ -1827,7 +1706,15 @@ func extractDetail(page *rod.Page, step TraceStep) map[string]string {
 	return result
 }
 
+// replaceParams 渲染参数模板：支持完整的 text/template 语法和日期等辅助函数（见 traceTemplateFuncs），
+// 模板无法解析、执行或引用了不存在的参数时退回到 {{.Key}} 字面替换，未知的 {{.Key}} 原样保留
 func replaceParams(template string, params map[string]string) string {
+	if !strings.Contains(template, "{{") {
+		return template
+	}
+	if result, err := renderTemplate(template, params); err == nil {
+		return result
+	}
 	result := template
 	for key, value := range params {
 		result = strings.ReplaceAll(result, fmt.Sprintf("{{.%s}}", key), value)
@@ -1838,7 +1725,7 @@ func replaceParams(template string, params map[string]string) string {
 // ==================== 采集任务 ====================
 
 // runCollectTaskWithTracking 带任务状态跟踪的采集任务执行器
-func runCollectTaskWithTracking(taskID string, sourceID int, keywords []string) {
+func runCollectTaskWithTracking(taskID string, sourceID int, keywords []string, limits LimitOverrides) {
 	// 创建可取消的context
 	ctx, cancel := context.WithCancel(context.Background())
 	registerTaskCanceler(taskID, cancel)
@@ -1851,7 +1738,7 @@ func runCollectTaskWithTracking(taskID string, sourceID int, keywords []string)
 	})
 
 	// 执行采集
-	err := runCollectTask(ctx, taskID, sourceID, keywords)
+	err := runCollectTask(ctx, taskID, sourceID, keywords, limits)
 
 	// 更新完成状态
 	if err != nil {
@@ -1867,20 +1754,24 @@ func runCollectTaskWithTracking(taskID string, sourceID int, keywords []string)
 			log.Printf("❌ 任务 %s 失败: %v", taskID, err)
 		}
 	} else {
-		updateCollectTask(taskID, map[string]interface{}{
+		updates := map[string]interface{}{
 			"status":       "completed",
 			"progress":     100,
-			"message":      "采集完成",
 			"completed_at": time.Now().Format("2006-01-02 15:04:05"),
-		})
+		}
+		// 单个采集源的任务保留采集结果摘要（含轨迹健康告警），批量任务显示统一的完成提示
+		if sourceID == 0 {
+			updates["message"] = "采集完成"
+		}
+		updateCollectTask(taskID, updates)
 		log.Printf("✅ 任务 %s 完成", taskID)
 	}
 }
 
-func runCollectTask(ctx context.Context, taskID string, sourceID int, keywords []string) error {
+func runCollectTask(ctx context.Context, taskID string, sourceID int, keywords []string, limits LimitOverrides) error {
 	if sourceID > 0 {
 		// 采集指定的源
-		if err := collectBySourceWithProgress(ctx, taskID, sourceID, keywords); err != nil {
+		if err := collectSource(ctx, taskID, sourceID, keywords, limits); err != nil {
 			log.Printf("❌ 采集源 %d 采集失败: %v", sourceID, err)
 			return err
 		}
@@ -1918,23 +1809,25 @@ func runCollectTask(ctx context.Context, taskID string, sourceID int, keywords [
 	successCount := 0
 	failCount := 0
 
-	for _, source := range activeSources {
+	for idx, source := range activeSources {
 		// 检查是否被取消
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
 
 		log.Printf("\n========== 采集源: %s (%s) ==========", source.Name, source.Code)
+		updateCollectTask(taskID, map[string]interface{}{
+			"progress": idx * 90 / len(activeSources),
+			"message":  fmt.Sprintf("正在采集 %s (%d/%d)", source.Name, idx+1, len(activeSources)),
+		})
 
-		// 检查是否有对应的轨迹
-		listTrace := getTraceBySourceAndType(source.ID, "list")
-		if listTrace == nil {
+		// 批量模式不跟踪单个源的进度
+		err := collectSource(ctx, "", source.ID, keywords, limits)
+		if errors.Is(err, errNoListTrace) {
 			log.Printf("⚠️ 跳过 %s：未找到列表轨迹", source.Name)
 			continue
 		}
-
-		// 使用collectBySource（不带进度跟踪，因为是批量模式）
-		if err := collectBySource(source.ID, keywords); err != nil {
+		if err != nil {
 			log.Printf("❌ 采集源 %s 采集失败: %v", source.Name, err)
 			failCount++
 		} else {
@@ -1947,356 +1840,6 @@ func runCollectTask(ctx context.Context, taskID string, sourceID int, keywords [
 	return nil
 }
 
-// collectBySourceWithProgress 带进度跟踪的采集函数
-func collectBySourceWithProgress(ctx context.Context, taskID string, sourceID int, keywords []string) error {
-	var source Source
-	err := db.QueryRow("SELECT id, name, code, category, base_url FROM sources WHERE id = ?", sourceID).Scan(
-		&source.ID, &source.Name, &source.Code, &source.Category, &source.BaseURL,
-	)
-	if err != nil {
-		return fmt.Errorf("获取采集源失败: %v", err)
-	}
-
-	log.Printf("🚀 开始采集任务：采集源=%s, 关键词=%v", source.Name, keywords)
-	updateCollectTask(taskID, map[string]interface{}{
-		"progress": 10,
-		"message":  fmt.Sprintf("正在准备采集 %s", source.Name),
-	})
-
-	listTrace := getTraceBySourceAndType(sourceID, "list")
-	if listTrace == nil {
-		return fmt.Errorf("未找到列表轨迹，请先上传轨迹文件")
-	}
-
-	detailTrace := getTraceBySourceAndType(sourceID, "detail")
-	if detailTrace == nil {
-		log.Printf("⚠️ 未找到详情轨迹，将使用统一轨迹模式（仅采集列表页）")
-	}
-
-	browser, err := setupBrowser()
-	if err != nil {
-		return err
-	}
-	defer browser.Close()
-
-	updateCollectTask(taskID, map[string]interface{}{
-		"progress": 20,
-		"message":  "浏览器已启动，开始采集列表",
-	})
-
-	solver := NewCaptchaSolver(captchaService)
-
-	// 创建关键词匹配器（性能优化：在循环外创建一次，循环内重用）
-	keywordMatcher := NewKeywordMatcher(keywords, MatchModeAny)
-
-	totalFound := 0
-	totalSaved := 0
-
-	for kwIdx, keyword := range keywords {
-		// 检查是否被取消
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-
-		log.Printf("\n--- 关键词 [%d/%d]: %s ---", kwIdx+1, len(keywords), keyword)
-
-		// 更新进度：20 + (kwIdx / len(keywords)) * 70
-		progress := 20 + (kwIdx*70)/len(keywords)
-		updateCollectTask(taskID, map[string]interface{}{
-			"progress": progress,
-			"message":  fmt.Sprintf("正在采集关键词: %s", keyword),
-		})
-
-		params := map[string]string{"Keyword": keyword}
-		data, err := executeTrace(browser, listTrace, params, solver)
-		if err != nil {
-			log.Printf("❌ 列表采集失败: %v", err)
-			updateCollectTask(taskID, map[string]interface{}{
-				"message": fmt.Sprintf("关键词 %s 采集失败: %v", keyword, err),
-			})
-			continue
-		}
-
-		listItems := data.([]map[string]string)
-		log.Printf("📋 列表采集完成，共 %d 条", len(listItems))
-		totalFound += len(listItems)
-
-		for i, item := range listItems {
-			// 检查是否被取消
-			if ctx.Err() != nil {
-				return ctx.Err()
-			}
-
-			title := item["title"]
-			if !keywordMatcher.Match(title) {
-				log.Printf("  [%d/%d] 跳过（关键词不匹配）: %s", i+1, len(listItems), title)
-				continue
-			}
-
-			log.Printf("\n[%d/%d] 准备保存: %s", i+1, len(listItems), title)
-
-			var detail map[string]string
-			if detailTrace != nil {
-				detailParams := map[string]string{"URL": item["url"]}
-				detailData, err := executeTrace(browser, detailTrace, detailParams, solver)
-				if err != nil {
-					log.Printf("❌ 详情采集失败: %v", err)
-					continue
-				}
-				detail = detailData.(map[string]string)
-			}
-
-			tender := &Tender{
-				SourceID:    sourceID,
-				Title:       title,
-				PublishDate: item["date"],
-				URL:         item["url"],
-				Keywords:    keyword,
-				Status:      "active",
-			}
-
-			if detail != nil {
-				tender.Amount = detail["amount"]
-				tender.Deadline = detail["deadline"]
-				tender.Contact = detail["contact"]
-				tender.Phone = detail["phone"]
-				tender.Content = detail["content"]
-				tender.Attachments = detail["attachments"]
-			}
-
-			result, err := saveTender(tender)
-			if err != nil {
-				log.Printf("❌ 保存失败: %v", err)
-			} else {
-				switch result.Action {
-				case "created":
-					log.Printf("✅ 新增到数据库")
-					totalSaved++
-				case "updated":
-					log.Printf("🔄 更新已有记录")
-					totalSaved++
-				case "skipped":
-					log.Printf("⏭️  已存在且无变化，跳过")
-				}
-				updateCollectTask(taskID, map[string]interface{}{
-					"found": totalFound,
-					"saved": totalSaved,
-				})
-			}
-		}
-	}
-
-	updateCollectTask(taskID, map[string]interface{}{
-		"progress": 90,
-		"message":  fmt.Sprintf("采集完成，共发现 %d 条，保存 %d 条", totalFound, totalSaved),
-		"found":    totalFound,
-		"saved":    totalSaved,
-	})
-
-	return nil
-}
-
-func collectBySource(sourceID int, keywords []string) error {
-	var source Source
-	err := db.QueryRow("SELECT id, name, code, category, base_url FROM sources WHERE id = ?", sourceID).Scan(
-		&source.ID, &source.Name, &source.Code, &source.Category, &source.BaseURL,
-	)
-	if err != nil {
-		return fmt.Errorf("获取采集源失败: %v", err)
-	}
-
-	log.Printf("🚀 开始采集任务：采集源=%s, 关键词=%v", source.Name, keywords)
-
-	listTrace := getTraceBySourceAndType(sourceID, "list")
-	if listTrace == nil {
-		return fmt.Errorf("未找到列表轨迹，请先上传轨迹文件")
-	}
-
-	detailTrace := getTraceBySourceAndType(sourceID, "detail")
-	if detailTrace == nil {
-		log.Printf("⚠️ 未找到详情轨迹，将使用统一轨迹模式（仅采集列表页）")
-	}
-
-	browser, err := setupBrowser()
-	if err != nil {
-		return err
-	}
-	defer browser.Close()
-
-	solver := NewCaptchaSolver(captchaService)
-
-	// 创建关键词匹配器（性能优化）
-	keywordMatcher := NewKeywordMatcher(keywords, MatchModeAny)
-
-	for _, keyword := range keywords {
-		log.Printf("\n--- 关键词: %s ---", keyword)
-
-		params := map[string]string{"Keyword": keyword}
-		data, err := executeTrace(browser, listTrace, params, solver)
-		if err != nil {
-			log.Printf("❌ 列表采集失败: %v", err)
-			continue
-		}
-
-		listItems := data.([]map[string]string)
-		log.Printf("📋 列表采集完成，共 %d 条", len(listItems))
-
-		for i, item := range listItems {
-			title := item["title"]
-			if !keywordMatcher.Match(title) {
-				log.Printf("  [%d/%d] 跳过（关键词不匹配）: %s", i+1, len(listItems), title)
-				continue
-			}
-
-			log.Printf("\n[%d/%d] 准备保存: %s", i+1, len(listItems), title)
-
-			var detail map[string]string
-			if detailTrace != nil {
-				detailParams := map[string]string{"URL": item["url"]}
-				detailData, err := executeTrace(browser, detailTrace, detailParams, solver)
-				if err != nil {
-					log.Printf("❌ 详情采集失败: %v", err)
-					continue
-				}
-				detail = detailData.(map[string]string)
-			}
-
-			tender := &Tender{
-				SourceID:    sourceID,
-				Title:       title,
-				PublishDate: item["date"],
-				URL:         item["url"],
-				Keywords:    keyword,
-				Status:      "active",
-			}
-
-			if detail != nil {
-				tender.Amount = detail["amount"]
-				tender.Deadline = detail["deadline"]
-				tender.Contact = detail["contact"]
-				tender.Phone = detail["phone"]
-				tender.Content = detail["content"]
-				tender.Attachments = detail["attachments"]
-			}
-
-			result, err := saveTender(tender)
-			if err != nil {
-				log.Printf("❌ 保存失败: %v", err)
-			} else {
-				switch result.Action {
-				case "created":
-					log.Printf("✅ 新增到数据库")
-				case "updated":
-					log.Printf("🔄 更新已有记录")
-				case "skipped":
-					log.Printf("⏭️  已存在且无变化，跳过")
-				}
-			}
-
-			time.Sleep(2 * time.Second)
-		}
-	}
-
-	log.Println("\n✅ 采集任务完成")
-	return nil
-}
-
-func collectSingleProvince(province string, keywords []string) error {
-	log.Printf("🚀 开始采集任务：省份=%s, 关键词=%v", province, keywords)
-
-	listTracePath := filepath.Join(tracesDir, province+"_list.json")
-	detailTracePath := filepath.Join(tracesDir, province+"_detail.json")
-
-	listTrace, err := loadTrace(listTracePath)
-	if err != nil {
-		return fmt.Errorf("加载列表轨迹失败: %v", err)
-	}
-
-	detailTrace, err := loadTrace(detailTracePath)
-	if err != nil {
-		return fmt.Errorf("加载详情轨迹失败: %v", err)
-	}
-
-	browser, err := setupBrowser()
-	if err != nil {
-		return err
-	}
-	defer browser.Close()
-
-	solver := NewCaptchaSolver(captchaService)
-
-	// 创建关键词匹配器（性能优化）
-	keywordMatcher := NewKeywordMatcher(keywords, MatchModeAny)
-
-	for _, keyword := range keywords {
-		log.Printf("\n--- 关键词: %s ---", keyword)
-
-		params := map[string]string{"Keyword": keyword}
-		data, err := executeTrace(browser, listTrace, params, solver)
-		if err != nil {
-			log.Printf("❌ 列表采集失败: %v", err)
-			continue
-		}
-
-		listItems := data.([]map[string]string)
-		log.Printf("📋 列表采集完成，共 %d 条", len(listItems))
-
-		for i, item := range listItems {
-			title := item["title"]
-			if !keywordMatcher.Match(title) {
-				log.Printf("  [%d/%d] 跳过（关键词不匹配）: %s", i+1, len(listItems), title)
-				continue
-			}
-
-			log.Printf("\n[%d/%d] 准备保存: %s", i+1, len(listItems), title)
-
-			detailParams := map[string]string{"URL": item["url"]}
-			detailData, err := executeTrace(browser, detailTrace, detailParams, solver)
-			if err != nil {
-				log.Printf("❌ 详情采集失败: %v", err)
-				continue
-			}
-
-			detail := detailData.(map[string]string)
-
-			sourceID := getSourceIDByCode(province)
-			tender := &Tender{
-				SourceID:    sourceID,
-				Title:       title,
-				Amount:      detail["amount"],
-				PublishDate: item["date"],
-				Deadline:    detail["deadline"],
-				Contact:     detail["contact"],
-				Phone:       detail["phone"],
-				URL:         item["url"],
-				Keywords:    keyword,
-				Content:     detail["content"],
-				Attachments: detail["attachments"],
-				Status:      "active",
-			}
-
-			result, err := saveTender(tender)
-			if err != nil {
-				log.Printf("❌ 保存失败: %v", err)
-			} else {
-				switch result.Action {
-				case "created":
-					log.Printf("✅ 新增到数据库")
-				case "updated":
-					log.Printf("🔄 更新已有记录")
-				case "skipped":
-					log.Printf("⏭️  已存在且无变化，跳过")
-				}
-			}
-
-			time.Sleep(2 * time.Second)
-		}
-	}
-
-	log.Println("\n✅ 采集任务完成")
-	return nil
-}
-
 func loadTrace(path string) (*TraceFile, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -2311,9 +1854,11 @@ func loadTrace(path string) (*TraceFile, error) {
 	return &trace, nil
 }
 
-func getTraceBySourceAndType(sourceID int, traceType string) *TraceFile {
+// getTraceBySourceAndType 读取上传轨迹的生效版本，返回轨迹和版本号
+func getTraceBySourceAndType(sourceID int, traceType string) (*TraceFile, int) {
 	var rawContent string
-	err := db.QueryRow("SELECT raw_content FROM traces WHERE source_id = ? AND type = ? AND status = 'active' LIMIT 1", sourceID, traceType).Scan(&rawContent)
+	var version int
+	err := db.QueryRow("SELECT raw_content, COALESCE(active_version, 0) FROM traces WHERE source_id = ? AND type = ? AND status = 'active' LIMIT 1", sourceID, traceType).Scan(&rawContent, &version)
 	if err != nil {
 		log.Printf("❌ 查询轨迹失败: source_id=%d, type=%s, error=%v", sourceID, traceType, err)
 
@@ -2329,19 +1874,19 @@ func getTraceBySourceAndType(sourceID int, traceType string) *TraceFile {
 			}
 			rows.Close()
 		}
-		return nil
+		return nil, 0
 	}
 
-	log.Printf("✅ 找到轨迹: source_id=%d, type=%s, raw_content长度=%d", sourceID, traceType, len(rawContent))
+	log.Printf("✅ 找到轨迹: source_id=%d, type=%s, 版本=%d, raw_content长度=%d", sourceID, traceType, version, len(rawContent))
 
 	trace, err := parseTraceFile(rawContent)
 	if err != nil {
 		log.Printf("❌ 解析轨迹失败: %v", err)
-		return nil
+		return nil, 0
 	}
 
 	log.Printf("✅ 轨迹解析成功: %d 个步骤", len(trace.Steps))
-	return trace
+	return trace, version
 }
 
 // KeywordMatchMode 关键词匹配模式
@@ -2466,7 +2011,17 @@ func startAPIServer() {
 	http.HandleFunc("/api/health", handleHealth)
 	http.HandleFunc("/api/sources", handleSources)
 	http.HandleFunc("/api/traces", handleTraces)
+	http.HandleFunc("/api/traces/", handleTraceVersions)
+	http.HandleFunc("/api/traces/schema", handleTraceSchema)
+	http.HandleFunc("/api/traces/infer", handleTraceInfer)
+	http.HandleFunc("/api/trace-sessions/", handleTraceSession)
+	http.HandleFunc("/api/recordings", handleRecordings)
+	http.HandleFunc("/api/recordings/", handleRecording)
+	http.HandleFunc("/api/trace-health", handleTraceHealth)
+	http.HandleFunc("/api/trace-health/repair", handleTraceRepair)
 	http.HandleFunc("/api/tags", handleTags)
+	http.HandleFunc("/api/proxies", handleProxies)
+	http.HandleFunc("/api/credentials", handleCredentials)
 	http.HandleFunc("/api/tender/update", handleTenderUpdate)
 
 	log.Println("🌐 Web 服务启动: http://localhost:8080")
@@ -2630,8 +2185,9 @@ func handleCollect(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		SourceID int      `json:"source_id"`
-		Keywords []string `json:"keywords"`
+		SourceID int            `json:"source_id"`
+		Keywords []string       `json:"keywords"`
+		Limits   LimitOverrides `json:"limits"` // 可选，覆盖采集源/轨迹中的限制
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -2640,14 +2196,14 @@ func handleCollect(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 创建任务记录
-	task, err := createCollectTask(req.SourceID, req.Keywords)
+	task, err := createCollectTask(req.SourceID, req.Keywords, req.Limits)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("创建任务失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	// 异步执行采集任务
-	go runCollectTaskWithTracking(task.ID, req.SourceID, req.Keywords)
+	go runCollectTaskWithTracking(task.ID, req.SourceID, req.Keywords, req.Limits)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -2712,7 +2268,10 @@ func handleCollectTask(w http.ResponseWriter, r *http.Request) {
 
 func handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "service": "tender-monitor", "version": "1.0.0"})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "ok", "service": "tender-monitor", "version": "1.0.0",
+		"browser_pool": getBrowserPool().Stats(),
+	})
 }
 
 func handleSources(w http.ResponseWriter, r *http.Request) {
@@ -2723,6 +2282,9 @@ func handleSources(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		for i := range sources {
+			sources[i].Proxy = maskProxyURL(sources[i].Proxy)
+		}
 		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": sources})
 	case "POST":
 		var s Source
@@ -2730,10 +2292,35 @@ func handleSources(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if s.Kind != "" && s.Kind != SourceKindTrace {
+			if _, err := loadSourceKindConfig(&s); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		s.Proxy = strings.TrimSpace(s.Proxy)
+		if s.ID > 0 {
+			if saved, err := getSourceByID(s.ID); err == nil {
+				s.Proxy = restoreProxyPassword(s.Proxy, saved.Proxy)
+			}
+		}
+		if err := validateProxyURL(s.Proxy); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if s.BrowserMode != "" && s.BrowserMode != BrowserModeIncognito && s.BrowserMode != BrowserModeProfile {
+			http.Error(w, fmt.Sprintf("不支持的浏览器模式: %s", s.BrowserMode), http.StatusBadRequest)
+			return
+		}
+		if _, err := parseStealthOptions(s.Stealth); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		if err := saveSource(&s); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		s.Proxy = maskProxyURL(s.Proxy)
 		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": s})
 	case "DELETE":
 		if id, err := parseInt(r.URL.Query().Get("id")); err == nil {
@@ -2748,7 +2335,8 @@ func handleSources(w http.ResponseWriter, r *http.Request) {
 func handleTraces(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
-		rows, err := db.Query("SELECT id, source_id, name, type, parsed_url, status, created_at FROM traces ORDER BY id DESC")
+		rows, err := db.Query(`SELECT t.id, t.source_id, t.name, t.type, t.parsed_url, t.status, t.created_at, COALESCE(t.active_version, 0),
+			(SELECT COUNT(*) FROM trace_versions v WHERE v.trace_id = t.id) FROM traces t ORDER BY t.id DESC`)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -2757,8 +2345,10 @@ func handleTraces(w http.ResponseWriter, r *http.Request) {
 		var traces []map[string]interface{}
 		for rows.Next() {
 			var t TraceRecord
-			rows.Scan(&t.ID, &t.SourceID, &t.Name, &t.Type, &t.ParsedURL, &t.Status, &t.CreatedAt)
-			traces = append(traces, map[string]interface{}{"id": t.ID, "source_id": t.SourceID, "name": t.Name, "type": t.Type, "parsed_url": t.ParsedURL, "status": t.Status, "created_at": t.CreatedAt})
+			var activeVersion, versionCount int
+			rows.Scan(&t.ID, &t.SourceID, &t.Name, &t.Type, &t.ParsedURL, &t.Status, &t.CreatedAt, &activeVersion, &versionCount)
+			traces = append(traces, map[string]interface{}{"id": t.ID, "source_id": t.SourceID, "name": t.Name, "type": t.Type, "parsed_url": t.ParsedURL, "status": t.Status, "created_at": t.CreatedAt,
+				"active_version": activeVersion, "version_count": versionCount})
 		}
 		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": traces})
 	case "POST":
@@ -2768,6 +2358,8 @@ func handleTraces(w http.ResponseWriter, r *http.Request) {
 			Name       string `json:"name"`
 			Type       string `json:"type"`
 			Analyze    bool   `json:"analyze"`
+			Author     string `json:"author"`
+			Comment    string `json:"comment"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -2775,7 +2367,7 @@ func handleTraces(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if req.Analyze {
-			traceData, err := parseTraceFile(req.RawContent)
+			traceData, issues, err := traceconv.LintJSON([]byte(req.RawContent))
 			if err != nil {
 				http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
 				return
@@ -2787,7 +2379,8 @@ func handleTraces(w http.ResponseWriter, r *http.Request) {
 					break
 				}
 			}
-			json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": map[string]interface{}{"parsed_url": parsedURL, "type": traceData.Type, "name": traceData.Name, "step_count": len(traceData.Steps)}})
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": map[string]interface{}{"parsed_url": parsedURL, "type": traceData.Type, "name": traceData.Name, "step_count": len(traceData.Steps),
+				"format": traceconv.DetectFormat([]byte(req.RawContent)), "valid": !traceconv.HasErrors(issues), "issues": issues}})
 			return
 		}
 
@@ -2797,6 +2390,14 @@ func handleTraces(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "解析轨迹失败: "+err.Error(), http.StatusBadRequest)
 			return
 		}
+		// 与启用、回滚使用同一校验规则：存在错误级问题时不保存，警告随响应返回供界面提示
+		_, issues, _ := traceconv.LintJSON([]byte(req.RawContent))
+		if blocking := activationBlockers(traceData); blocking != nil {
+			log.Printf("⚠️ 轨迹存在错误，未保存: %v", blocking)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "轨迹校验未通过，未保存", "issues": blocking})
+			return
+		}
 
 		var parsedURL string
 		for _, step := range traceData.Steps {
@@ -2811,31 +2412,18 @@ func handleTraces(w http.ResponseWriter, r *http.Request) {
 			sourceID = 0
 		}
 
-		var existingID int
-		checkErr := db.QueryRow("SELECT id FROM traces WHERE source_id = ? AND type = ?", sourceID, req.Type).Scan(&existingID)
-		if checkErr == nil {
-			_, err = db.Exec(`UPDATE traces SET name=?, raw_content=?, parsed_url=?, status='active' WHERE id=?`,
-				req.Name, req.RawContent, parsedURL, existingID)
-			if err != nil {
-				log.Printf("更新轨迹失败: %v", err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			log.Printf("轨迹已更新: source_id=%d, type=%s", sourceID, req.Type)
-		} else {
-			_, err = db.Exec(`INSERT INTO traces (source_id, name, type, raw_content, parsed_url, status) VALUES (?, ?, ?, ?, ?, ?)`,
-				sourceID, req.Name, req.Type, req.RawContent, parsedURL, "active")
-			if err != nil {
-				log.Printf("保存轨迹失败: %v", err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
+		// 每次上传保存为新版本，旧版本保留可回滚
+		traceID, version, err := saveTraceVersion(sourceID, req.Type, req.Name, req.RawContent, parsedURL, req.Author, req.Comment)
+		if err != nil {
+			log.Printf("保存轨迹失败: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+		log.Printf("轨迹已保存: source_id=%d, type=%s, 版本=%d", sourceID, req.Type, version)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": map[string]interface{}{"trace_id": traceID, "version": version, "issues": issues}})
 	case "DELETE":
 		if delID, delErr := parseInt(r.URL.Query().Get("id")); delErr == nil {
-			_, delExecErr := db.Exec("DELETE FROM traces WHERE id = ?", delID)
-			if delExecErr != nil {
+			if delExecErr := deleteTrace(delID); delExecErr != nil {
 				log.Printf("删除轨迹失败: %v", delExecErr)
 			}
 		}
@@ -2845,6 +2433,12 @@ func handleTraces(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleTraceSchema 返回轨迹文件的 JSON Schema
+func handleTraceSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(traceconv.Schema)
+}
+
 func handleTags(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
@@ -2912,6 +2506,14 @@ func main() {
 	}
 	defer db.Close()
 
+	// 命令行采集模式：tender-monitor collect <采集源代码> <关键词>...
+	if len(os.Args) > 1 && os.Args[1] == "collect" {
+		if err := runCollectCommand(os.Args[2:]); err != nil {
+			log.Fatalf("采集失败: %v", err)
+		}
+		return
+	}
+
 	solver := NewCaptchaSolver(captchaService)
 	if solver.CheckAvailable() {
 		log.Println("✅ 验证码服务已连接")
@@ -2922,5 +2524,6 @@ func main() {
 	os.MkdirAll(dataDir, 0755)
 	os.MkdirAll(tracesDir, 0755)
 
+	startProxyHealthCheck()
 	startAPIServer()
 }
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseTraceFileMatchesGolden /api/traces 上传录制时的解析结果（parseTraceFile）与 traceconv 的 golden 文件一致；
+// convert-trace 命令行的转换结果由 cmd/convert-trace 的测试对照同一组 golden 文件，两条路径因此保持一致
+func TestParseTraceFileMatchesGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("traceconv", "testdata", "*.recording.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("未找到录制文件: %v", err)
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".recording.json")
+		t.Run(name, func(t *testing.T) {
+			raw, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			trace, err := parseTraceFile(string(raw))
+			if err != nil {
+				t.Fatalf("上传解析失败: %v", err)
+			}
+			got, err := json.MarshalIndent(trace, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want, err := os.ReadFile(filepath.Join("traceconv", "testdata", name+".golden.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got)+"\n" != string(want) {
+				t.Errorf("上传解析结果与 golden 不一致:\n%s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// TestIsProxyFailure 只有网络、连接错误和被拦截的响应计为代理失败
+func TestIsProxyFailure(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("导航失败: navigation failed: net::ERR_PROXY_CONNECTION_FAILED"), true},
+		{errors.New("请求失败: Get \"https://example.com\": proxyconnect tcp: dial tcp 10.0.0.1:8080: connection refused"), true},
+		{errors.New("请求失败: HTTP 403"), true},
+		{fmt.Errorf("导航失败: %w（HTTP 429）", errProxyBlocked), true},
+		{errors.New("找不到元素 'button.search': context deadline exceeded"), false},
+		{errors.New("提取列表失败: 未找到列表行 tbody tr"), false},
+		{errors.New("请求失败: HTTP 404"), false},
+	}
+	for _, c := range cases {
+		if got := isProxyFailure(c.err); got != c.want {
+			t.Errorf("isProxyFailure(%v) = %v, 期望 %v", c.err, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ==================== 访问频率限制 ====================
+//
+// 按主机维护令牌桶，所有任务共享：同一站点的两个任务不会叠加请求频率。
+// 多个采集源访问同一主机时取各自策略中最严格的一项，未配置策略的采集源不会放宽其他采集源的限制。
+// 浏览器轨迹的 navigate 步骤和 HTTP 请求（HTTP 模式轨迹、接口、订阅）都会经过这里。
+// 策略来自采集源配置，通过 context 传递到执行层。
+
+// RatePolicy 采集源的访问频率策略（0 或空表示不限制）
+type RatePolicy struct {
+	MinIntervalMs int    `json:"min_interval_ms"`      // 同一主机两次请求的最小间隔
+	MaxConcurrent int    `json:"max_concurrent_pages"` // 同一主机同时打开的页面/请求数
+	DailyBudget   int    `json:"daily_request_budget"` // 同一主机每日请求上限
+	QuietHours    string `json:"quiet_hours"`          // 禁止访问的时段，如 "22:00-06:00"，多个用逗号分隔
+
+	sourceID int // 策略所属的采集源，同一主机按采集源分别记录
+}
+
+// errRateLimited 因静默时段或每日配额拒绝请求，采集任务应停止而不是逐条重试
+var errRateLimited = errors.New("访问受限")
+
+// hostLimiter 单个主机的限制状态
+type hostLimiter struct {
+	mu       sync.Mutex
+	limiter  *rate.Limiter
+	slots    chan struct{}
+	slotSize int
+	budget   int
+	policies map[int]RatePolicy // 访问过该主机的采集源的策略
+	day      string
+	used     int // 当日请求数（仅内存计数，重启后清零）
+}
+
+var (
+	hostLimitersMu sync.Mutex
+	hostLimiters   = make(map[string]*hostLimiter)
+)
+
+type ratePolicyKey struct{}
+
+// withRatePolicy 将采集源的频率策略附加到 context
+func withRatePolicy(ctx context.Context, policy RatePolicy) context.Context {
+	return context.WithValue(ctx, ratePolicyKey{}, policy)
+}
+
+// ratePolicyFrom 读取 context 中的频率策略
+func ratePolicyFrom(ctx context.Context) RatePolicy {
+	policy, _ := ctx.Value(ratePolicyKey{}).(RatePolicy)
+	return policy
+}
+
+// getHostLimiter 获取主机的限制器，记录调用方采集源的策略后按所有采集源中最严格的限制调整
+func getHostLimiter(host string, policy RatePolicy) *hostLimiter {
+	hostLimitersMu.Lock()
+	defer hostLimitersMu.Unlock()
+
+	hl := hostLimiters[host]
+	if hl == nil {
+		hl = &hostLimiter{limiter: rate.NewLimiter(rate.Inf, 1), policies: make(map[int]RatePolicy)}
+		hostLimiters[host] = hl
+	}
+
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+	hl.policies[policy.sourceID] = policy
+	strictest := strictestPolicy(hl.policies)
+
+	limit := rate.Inf
+	if strictest.MinIntervalMs > 0 {
+		limit = rate.Every(time.Duration(strictest.MinIntervalMs) * time.Millisecond)
+	}
+	if hl.limiter.Limit() != limit {
+		hl.limiter.SetLimit(limit)
+	}
+
+	if strictest.MaxConcurrent != hl.slotSize {
+		// 已占用的名额释放回旧通道，不影响新名额
+		hl.slotSize = strictest.MaxConcurrent
+		hl.slots = nil
+		if strictest.MaxConcurrent > 0 {
+			hl.slots = make(chan struct{}, strictest.MaxConcurrent)
+		}
+	}
+	hl.budget = strictest.DailyBudget
+	return hl
+}
+
+// strictestPolicy 合并多个策略：间隔取最大，并发数和每日上限取最小的非零值
+func strictestPolicy(policies map[int]RatePolicy) RatePolicy {
+	var merged RatePolicy
+	for _, p := range policies {
+		if p.MinIntervalMs > merged.MinIntervalMs {
+			merged.MinIntervalMs = p.MinIntervalMs
+		}
+		merged.MaxConcurrent = minPositive(merged.MaxConcurrent, p.MaxConcurrent)
+		merged.DailyBudget = minPositive(merged.DailyBudget, p.DailyBudget)
+	}
+	return merged
+}
+
+func minPositive(a, b int) int {
+	if a <= 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// hostOf 提取 URL 的主机名
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// acquireRequest 发起请求前检查静默时段和每日配额，并等待令牌。
+// 请求在拿到令牌后才计入当日配额；配额只在内存中计数，服务重启后清零
+func acquireRequest(ctx context.Context, rawURL string) error {
+	host := hostOf(rawURL)
+	if host == "" {
+		return nil
+	}
+	policy := ratePolicyFrom(ctx)
+
+	if inQuietHours(policy.QuietHours, time.Now()) {
+		return fmt.Errorf("%w: %s 处于静默时段 %s", errRateLimited, host, policy.QuietHours)
+	}
+
+	hl := getHostLimiter(host, policy)
+	if err := hl.checkBudget(host); err != nil {
+		return err
+	}
+	if err := hl.limiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	// 等待期间其他请求可能已用完配额，计数前再检查一次
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+	if err := hl.budgetExceeded(host); err != nil {
+		return err
+	}
+	hl.used++
+	return nil
+}
+
+// checkBudget 检查当日配额是否已用完
+func (hl *hostLimiter) checkBudget(host string) error {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+	return hl.budgetExceeded(host)
+}
+
+// budgetExceeded 跨天时重置计数，配额已用完时返回错误，调用方需持有 hl.mu
+func (hl *hostLimiter) budgetExceeded(host string) error {
+	today := time.Now().Format("2006-01-02")
+	if hl.day != today {
+		hl.day, hl.used = today, 0
+	}
+	if hl.budget > 0 && hl.used >= hl.budget {
+		return fmt.Errorf("%w: %s 今日请求已达上限 %d", errRateLimited, host, hl.budget)
+	}
+	return nil
+}
+
+// acquirePage 占用主机的一个并发名额，返回释放函数
+func acquirePage(ctx context.Context, rawURL string) (func(), error) {
+	host := hostOf(rawURL)
+	if host == "" {
+		return func() {}, nil
+	}
+
+	hl := getHostLimiter(host, ratePolicyFrom(ctx))
+	hl.mu.Lock()
+	slots := hl.slots
+	hl.mu.Unlock()
+	if slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case slots <- struct{}{}:
+	default:
+		log.Printf("⏳ %s 并发页面已满，等待空闲", host)
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	var once sync.Once
+	return func() { once.Do(func() { <-slots }) }, nil
+}
+
+// beginRequest 发起单次 HTTP 请求：占用并发名额并等待令牌
+func beginRequest(ctx context.Context, rawURL string) (func(), error) {
+	release, err := acquirePage(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := acquireRequest(ctx, rawURL); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// inQuietHours 判断当前是否处于静默时段，支持跨午夜的时段
+func inQuietHours(spec string, now time.Time) bool {
+	minutes := now.Hour()*60 + now.Minute()
+	for _, part := range strings.Split(spec, ",") {
+		bounds := strings.Split(strings.TrimSpace(part), "-")
+		if len(bounds) != 2 {
+			continue
+		}
+		start, ok1 := parseClock(bounds[0])
+		end, ok2 := parseClock(bounds[1])
+		if !ok1 || !ok2 {
+			continue
+		}
+		if start <= end {
+			if minutes >= start && minutes < end {
+				return true
+			}
+		} else if minutes >= start || minutes < end {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock 解析 HH:MM，返回当天的分钟数
+func parseClock(s string) (int, bool) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-rod/rod"
+)
+
+// ==================== 选择器解析 ====================
+//
+// 轨迹中的选择器表达式语法：
+//
+//	<定位器>[@属性][ | 处理器]...
+//
+// 定位器：
+//   - CSS 选择器，支持扩展伪类 :contains('文本')、:text('文本') 以及嵌套在 :has() 中使用
+//   - xpath:<表达式>，兼容 Chrome 录制的 xpath//<表达式> 写法
+//   - text=<文本> 或 text/<文本>：包含该文本的最内层元素
+//   - regex=[CSS]/<正则>/：CSS 匹配且文本匹配正则的元素（同 rod ElementR），省略 CSS 时取最内层元素
+//
+// 属性：@text（默认）、@html、@href、@title、@data-* 等任意属性
+//
+// 处理器：trim、regex:<正则>（取第一个捕获组，无捕获组取整个匹配）、abs（相对URL转绝对URL）、
+// date（时间戳或中文日期转为 2006-01-02）
+//
+// 例如：td:contains('预算金额') + td | trim
+//
+//	td:nth-child(3) a@href | abs
+//	div.articleContent | regex:联系电话[：:]\s*([\d-]+)
+
+// FieldSelector 解析后的选择器表达式
+type FieldSelector struct {
+	Raw     string
+	Kind    string // css / xpath / text / regex
+	Query   string
+	Pattern string // regex 定位器的正则
+	Attr    string
+	Filters []string
+}
+
+// elementScope 可执行选择器查询的范围（*rod.Page 或 *rod.Element）
+type elementScope interface {
+	ElementsByJS(opts *rod.EvalOptions) (rod.Elements, error)
+}
+
+// parseFieldSelector 解析选择器表达式
+func parseFieldSelector(expr string) FieldSelector {
+	sel := FieldSelector{Raw: expr, Kind: "css"}
+
+	parts := splitTopLevel(expr, '|')
+	locator := strings.TrimSpace(parts[0])
+	for _, f := range parts[1:] {
+		if f = strings.TrimSpace(f); f != "" {
+			sel.Filters = append(sel.Filters, f)
+		}
+	}
+
+	if idx := lastTopLevelAt(locator); idx >= 0 {
+		sel.Attr = strings.TrimSpace(locator[idx+1:])
+		locator = strings.TrimSpace(locator[:idx])
+	}
+
+	switch {
+	case strings.HasPrefix(locator, "xpath:"):
+		sel.Kind = "xpath"
+		sel.Query = strings.TrimPrefix(locator, "xpath:")
+	case strings.HasPrefix(locator, "xpath/"):
+		sel.Kind = "xpath"
+		sel.Query = strings.TrimPrefix(locator, "xpath/")
+	case strings.HasPrefix(locator, "text="):
+		sel.Kind = "text"
+		sel.Query = strings.TrimPrefix(locator, "text=")
+	case strings.HasPrefix(locator, "text/"):
+		sel.Kind = "text"
+		sel.Query = strings.TrimPrefix(locator, "text/")
+	case strings.HasPrefix(locator, "regex="):
+		sel.Kind = "regex"
+		rest := strings.TrimPrefix(locator, "regex=")
+		start := strings.Index(rest, "/")
+		end := strings.LastIndex(rest, "/")
+		if start >= 0 && end > start {
+			sel.Query = strings.TrimSpace(rest[:start])
+			sel.Pattern = rest[start+1 : end]
+		} else {
+			sel.Pattern = rest
+		}
+	case strings.HasPrefix(locator, "pierce/"):
+		sel.Query = strings.TrimPrefix(locator, "pierce/")
+	default:
+		sel.Query = locator
+	}
+
+	return sel
+}
+
+// splitTopLevel 按分隔符拆分，忽略引号、方括号、圆括号内的分隔符
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth := 0
+	var quote byte
+	last := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[last:i])
+			last = i + 1
+		}
+	}
+	return append(parts, s[last:])
+}
+
+// lastTopLevelAt 查找位于顶层、且后接属性名的最后一个 @ 位置
+// text= 的值整体是文本，其中的 @ 不是属性后缀；regex= 只在正则结束的 / 之后查找
+func lastTopLevelAt(s string) int {
+	start := 0
+	switch {
+	case strings.HasPrefix(s, "text=") || strings.HasPrefix(s, "text/"):
+		return -1
+	case strings.HasPrefix(s, "regex="):
+		first, last := strings.Index(s, "/"), strings.LastIndex(s, "/")
+		if last <= first {
+			return -1
+		}
+		start = last + 1
+	}
+
+	idx := -1
+	depth := 0
+	var quote byte
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+		case c == '@' && depth == 0 && i > 0:
+			idx = i
+		}
+	}
+	if idx < 0 || !attrNamePattern.MatchString(strings.TrimSpace(s[idx+1:])) {
+		return -1
+	}
+	return idx
+}
+
+var attrNamePattern = regexp.MustCompile(`^[A-Za-z_][\w\-:.]*$`)
+
+// selectorEngineJS 在页面内执行扩展选择器查询
+const selectorEngineJS = `function(kind, query, pattern, first) {
+	const root = this && this.nodeType ? this : document;
+	const textOf = (e) => (e.textContent || '').replace(/\s+/g, ' ').trim();
+	const innermost = (list) => list.filter((e) => !list.some((c) => c !== e && e.contains(c)));
+	const unquote = (s) => s.trim().replace(/^(['"])(.*)\1$/, '$2');
+
+	const split = (s, isSep) => {
+		const out = [];
+		let depth = 0, quote = '', cur = '';
+		for (let i = 0; i < s.length; i++) {
+			const c = s[i];
+			if (quote) { if (c === quote) quote = ''; cur += c; continue; }
+			if (c === '"' || c === "'") { quote = c; cur += c; continue; }
+			if (c === '(' || c === '[') depth++;
+			if (c === ')' || c === ']') depth--;
+			if (depth === 0 && isSep(c, s, i)) { out.push(cur); cur = ''; out.push(c); continue; }
+			cur += c;
+		}
+		out.push(cur);
+		return out;
+	};
+
+	// 拆分复合选择器与组合符
+	const tokenize = (sel) => {
+		const raw = split(sel.trim(), (c) => c === ' ' || c === '>' || c === '+' || c === '~');
+		const tokens = [];
+		let comb = ' ';
+		for (const t of raw) {
+			if (t === '' || t === ' ') continue;
+			if (t === '>' || t === '+' || t === '~') { comb = t; continue; }
+			tokens.push({ comb, compound: t });
+			comb = ' ';
+		}
+		return tokens;
+	};
+
+	// 从复合选择器中提取扩展伪类
+	const customRe = /:(contains|text|has)\(/g;
+	const parseCompound = (compound) => {
+		const filters = [];
+		let base = '', i = 0, m;
+		customRe.lastIndex = 0;
+		while ((m = customRe.exec(compound)) !== null) {
+			let depth = 1, j = m.index + m[0].length, quote = '';
+			for (; j < compound.length && depth > 0; j++) {
+				const c = compound[j];
+				if (quote) { if (c === quote) quote = ''; continue; }
+				if (c === '"' || c === "'") quote = c;
+				else if (c === '(') depth++;
+				else if (c === ')') depth--;
+			}
+			const arg = compound.slice(m.index + m[0].length, j - 1);
+			if (m[1] === 'has' && !/:(contains|text)\(/.test(arg)) continue;
+			base += compound.slice(i, m.index);
+			filters.push({ name: m[1], arg });
+			i = j;
+			customRe.lastIndex = j;
+		}
+		base += compound.slice(i);
+		return { base: base || '*', filters };
+	};
+
+	const passes = (e, filters) => filters.every((f) => {
+		if (f.name === 'contains') return textOf(e).includes(unquote(f.arg));
+		if (f.name === 'text') return textOf(e).toLowerCase().includes(unquote(f.arg).toLowerCase());
+		return queryExt(e, f.arg).length > 0;
+	});
+
+	const queryExt = (scope, sel) => {
+		if (!/:(contains|text)\(/.test(sel)) {
+			return Array.from(scope.querySelectorAll(sel));
+		}
+		let current = null;
+		for (const { comb, compound } of tokenize(sel)) {
+			const { base, filters } = parseCompound(compound);
+			const next = new Set();
+			const sources = current === null ? [scope] : current;
+			for (const src of sources) {
+				let cands = [];
+				if (comb === ' ') {
+					cands = Array.from(src.querySelectorAll(base));
+				} else if (comb === '>') {
+					cands = Array.from(src.children).filter((c) => c.matches(base));
+				} else if (comb === '+') {
+					const n = src.nextElementSibling;
+					if (n && n.matches(base)) cands = [n];
+				} else if (comb === '~') {
+					for (let n = src.nextElementSibling; n; n = n.nextElementSibling) {
+						if (n.matches(base)) cands.push(n);
+					}
+				}
+				for (const c of cands) if (passes(c, filters)) next.add(c);
+			}
+			current = Array.from(next);
+		}
+		return current || [];
+	};
+
+	let list = [];
+	if (kind === 'xpath') {
+		const r = document.evaluate(query, root, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null);
+		for (let i = 0; i < r.snapshotLength; i++) list.push(r.snapshotItem(i));
+	} else if (kind === 'text') {
+		list = innermost(Array.from(root.querySelectorAll('*')).filter((e) => textOf(e).includes(query)));
+	} else if (kind === 'regex') {
+		const re = new RegExp(pattern);
+		const all = Array.from(root.querySelectorAll(query || '*')).filter((e) => re.test(textOf(e)));
+		list = query ? all : innermost(all);
+	} else {
+		list = queryExt(root, query);
+	}
+	list = list.filter((e) => e && e.nodeType === 1);
+	if (first) return list.length > 0 ? list[0] : null;
+	return list;
+}`
+
+// queryElements 在给定范围内查询所有匹配元素（不重试）
+func queryElements(scope elementScope, sel FieldSelector) (rod.Elements, error) {
+	if sel.Query == "" && sel.Pattern == "" {
+		return nil, fmt.Errorf("选择器为空")
+	}
+	return scope.ElementsByJS(rod.Eval(selectorEngineJS, sel.Kind, sel.Query, sel.Pattern, false))
+}
+
+// queryElement 在给定范围内查询第一个匹配元素（不重试）
+func queryElement(scope elementScope, sel FieldSelector) (*rod.Element, error) {
+	elems, err := queryElements(scope, sel)
+	if err != nil {
+		return nil, err
+	}
+	if len(elems) == 0 {
+		return nil, fmt.Errorf("未找到元素: %s", sel.Raw)
+	}
+	return elems.First(), nil
+}
+
+// findElement 在页面中查找元素，未出现时按页面超时重试（用于点击、输入等动作）
+func findElement(page *rod.Page, expr string) (*rod.Element, error) {
+	sel := parseFieldSelector(expr)
+	if sel.Query == "" && sel.Pattern == "" {
+		return nil, fmt.Errorf("选择器为空")
+	}
+	return page.ElementByJS(rod.Eval(selectorEngineJS, sel.Kind, sel.Query, sel.Pattern, true))
+}
+
+// primarySelectorWait 主选择器单独等待的时间，超过后才开始尝试候选选择器
+const primarySelectorWait = 5 * time.Second
+
+// findFirstMatch 按顺序尝试多个选择器，exprs[0] 为主选择器，其余为按评分从高到低排列的候选：
+// 先单独等待主选择器 primarySelectorWait，未出现时每轮按顺序依次查询（主选择器始终最先），
+// 返回第一个匹配的元素及其下标，全部未出现时按页面超时返回错误
+func findFirstMatch(page *rod.Page, exprs []string) (*rod.Element, int, error) {
+	var opts []*rod.EvalOptions
+	var index []int
+	for i, expr := range exprs {
+		sel := parseFieldSelector(expr)
+		if sel.Query == "" && sel.Pattern == "" {
+			continue
+		}
+		opts = append(opts, rod.Eval(selectorEngineJS, sel.Kind, sel.Query, sel.Pattern, true))
+		index = append(index, i)
+	}
+	if len(opts) == 0 {
+		return nil, -1, fmt.Errorf("选择器为空")
+	}
+
+	if index[0] == 0 {
+		primary := page.Timeout(primarySelectorWait)
+		elem, err := primary.ElementByJS(opts[0])
+		primary.CancelTimeout()
+		if err == nil {
+			return elem.Context(page.GetContext()), 0, nil
+		}
+		if page.GetContext().Err() != nil {
+			return nil, -1, err
+		}
+	}
+
+	race := page.Race()
+	matched := -1
+	for k := range opts {
+		i, opt := index[k], opts[k]
+		race.ElementFunc(func(p *rod.Page) (*rod.Element, error) {
+			elem, err := p.ElementByJS(opt)
+			if err != nil && !errors.Is(err, &rod.ErrElementNotFound{}) && p.GetContext().Err() == nil {
+				// 浏览器无法执行的选择器（如语法不支持）视为未匹配，继续尝试其他候选
+				return nil, &rod.ErrElementNotFound{}
+			}
+			return elem, err
+		}).Handle(func(*rod.Element) error {
+			matched = i
+			return nil
+		})
+	}
+	elem, err := race.Do()
+	return elem, matched, err
+}
+
+// findElements 在页面中查找所有匹配元素
+func findElements(page *rod.Page, expr string) (rod.Elements, error) {
+	return queryElements(page, parseFieldSelector(expr))
+}
+
+// elementValue 按属性读取元素的值
+func elementValue(elem *rod.Element, attr string) (string, error) {
+	switch attr {
+	case "", "text":
+		return elem.Text()
+	case "html":
+		return elem.HTML()
+	default:
+		v, err := elem.Attribute(attr)
+		if err != nil || v == nil {
+			return "", err
+		}
+		return *v, nil
+	}
+}
+
+// extractField 在给定范围内按选择器表达式提取字段值
+func extractField(scope elementScope, expr string, baseURL string) (string, bool) {
+	sel := parseFieldSelector(expr)
+	elem, err := queryElement(scope, sel)
+	if err != nil {
+		return "", false
+	}
+	value, err := elementValue(elem, sel.Attr)
+	if err != nil {
+		return "", false
+	}
+	return applySelectorFilters(value, sel.Filters, baseURL), true
+}
+
+// datePattern 匹配 2024-01-02、2024/1/2、2024年1月2日 等日期写法
+var datePattern = regexp.MustCompile(`(\d{4})\s*[-/.年]\s*(\d{1,2})\s*[-/.月]\s*(\d{1,2})`)
+
+// normalizeDate 将秒/毫秒时间戳或各种日期写法统一为 2006-01-02，无法识别时原样返回
+func normalizeDate(value string) string {
+	value = strings.TrimSpace(value)
+	if ts, err := strconv.ParseInt(value, 10, 64); err == nil && len(value) >= 10 {
+		if len(value) >= 13 {
+			return time.UnixMilli(ts).Format("2006-01-02")
+		}
+		return time.Unix(ts, 0).Format("2006-01-02")
+	}
+	if m := datePattern.FindStringSubmatch(value); m != nil {
+		month, _ := strconv.Atoi(m[2])
+		day, _ := strconv.Atoi(m[3])
+		return fmt.Sprintf("%s-%02d-%02d", m[1], month, day)
+	}
+	return value
+}
+
+// applySelectorFilters 依次执行后处理器
+func applySelectorFilters(value string, filters []string, baseURL string) string {
+	for _, f := range filters {
+		name, arg := f, ""
+		if idx := strings.Index(f, ":"); idx > 0 {
+			name, arg = f[:idx], f[idx+1:]
+		}
+		switch strings.TrimSpace(name) {
+		case "trim":
+			value = strings.TrimSpace(value)
+		case "regex":
+			re, err := regexp.Compile(strings.TrimSpace(arg))
+			if err != nil {
+				continue
+			}
+			m := re.FindStringSubmatch(value)
+			switch {
+			case m == nil:
+				value = ""
+			case len(m) > 1:
+				value = m[1]
+			default:
+				value = m[0]
+			}
+		case "abs":
+			value = resolveURL(baseURL, value)
+		case "date":
+			value = normalizeDate(value)
+		}
+	}
+	return value
+}
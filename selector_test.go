@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+// TestParseFieldSelectorAttr 只有顶层的 @ 才解析为属性后缀，文本、引号和正则中的 @ 保持原样
+func TestParseFieldSelectorAttr(t *testing.T) {
+	cases := []struct {
+		expr, kind, query, pattern, attr string
+	}{
+		{"td:nth-child(3) a@href", "css", "td:nth-child(3) a", "", "href"},
+		{"a[title='x@y']@href", "css", "a[title='x@y']", "", "href"},
+		{`a[title="x@y"]`, "css", `a[title="x@y"]`, "", ""},
+		{"text=a@b.com", "text", "a@b.com", "", ""},
+		{"text/联系邮箱 a@b.com", "text", "联系邮箱 a@b.com", "", ""},
+		{"regex=td /\\w+@\\w+\\.com/", "regex", "td", "\\w+@\\w+\\.com", ""},
+		{"regex=a /下载@附件/@href", "regex", "a", "下载@附件", "href"},
+		{"xpath://a[@class='pdf']@href", "xpath", "//a[@class='pdf']", "", "href"},
+	}
+	for _, c := range cases {
+		sel := parseFieldSelector(c.expr)
+		if sel.Kind != c.kind || sel.Query != c.query || sel.Pattern != c.pattern || sel.Attr != c.attr {
+			t.Errorf("%s => kind=%q query=%q pattern=%q attr=%q, 期望 kind=%q query=%q pattern=%q attr=%q",
+				c.expr, sel.Kind, sel.Query, sel.Pattern, sel.Attr, c.kind, c.query, c.pattern, c.attr)
+		}
+	}
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/go-rod/rod"
+)
+
+// ==================== 采集源适配器 ====================
+//
+// 每种采集源通过 SourceAdapter 提供“按关键词搜索列表”和“抓取详情”两种能力，
+// 进度、取消、关键词匹配、增量判断和入库统一由 collectSource 处理。
+// 适配器按 Source.Code 注册（站点专用的内置实现），未注册时按 Source.Kind 选择通用实现。
+
+// SourceAdapter 采集源适配器
+type SourceAdapter interface {
+	// Search 按关键词搜索，返回列表条目迭代器（条目字段：title、url、date 及可选的 Tender 字段）
+	Search(ctx context.Context, keyword string) (ListIterator, error)
+	// Detail 抓取条目详情，不支持详情时返回 nil, nil
+	Detail(ctx context.Context, item map[string]string) (map[string]string, error)
+}
+
+// ListIterator 列表条目迭代器，可按需翻页
+type ListIterator interface {
+	// Next 返回下一条记录，没有更多记录时返回 io.EOF
+	Next(ctx context.Context) (map[string]string, error)
+}
+
+// SourceAdapterFactory 创建适配器；按代码注册的工厂返回 nil, nil 表示交给通用实现处理
+type SourceAdapterFactory func(env *adapterEnv) (SourceAdapter, error)
+
+var (
+	adapterMu    sync.RWMutex
+	codeAdapters = make(map[string]SourceAdapterFactory)
+	kindAdapters = make(map[string]SourceAdapterFactory)
+)
+
+// errNoListTrace 轨迹回放类采集源缺少列表轨迹
+var errNoListTrace = errors.New("未找到列表轨迹，请先上传轨迹文件")
+
+// RegisterSourceAdapter 为指定代码的采集源注册专用适配器
+func RegisterSourceAdapter(code string, factory SourceAdapterFactory) {
+	adapterMu.Lock()
+	defer adapterMu.Unlock()
+	codeAdapters[code] = factory
+}
+
+// registerKindAdapter 注册某一类型采集源的通用适配器
+func registerKindAdapter(kind string, factory SourceAdapterFactory) {
+	adapterMu.Lock()
+	defer adapterMu.Unlock()
+	kindAdapters[kind] = factory
+}
+
+func init() {
+	registerKindAdapter(SourceKindTrace, newTraceAdapter)
+}
+
+// newSourceAdapter 为采集源选择适配器：先按代码，再按类型
+func newSourceAdapter(env *adapterEnv) (SourceAdapter, error) {
+	adapterMu.RLock()
+	byCode := codeAdapters[env.Source.Code]
+	kind := sourceKind(env.Source)
+	byKind := kindAdapters[kind]
+	adapterMu.RUnlock()
+
+	if byCode != nil {
+		adapter, err := byCode(env)
+		if err != nil || adapter != nil {
+			return adapter, err
+		}
+	}
+	if byKind == nil {
+		return nil, fmt.Errorf("未知的采集源类型: %s", kind)
+	}
+	return byKind(env)
+}
+
+// sourceKind 采集源类型，未设置时为轨迹回放
+func sourceKind(source *Source) string {
+	if source.Kind == "" {
+		return SourceKindTrace
+	}
+	return source.Kind
+}
+
+// loadSourceKindConfig 解析非轨迹类采集源的配置，轨迹回放类返回 nil
+func loadSourceKindConfig(source *Source) (interface{}, error) {
+	switch sourceKind(source) {
+	case SourceKindAPI:
+		return parseAPISourceConfig(source.Config)
+	case SourceKindRSS, SourceKindSitemap:
+		return parseFeedSourceConfig(source.Config)
+	case SourceKindTrace:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("未知的采集源类型: %s", source.Kind)
+	}
+}
+
+// ==================== 适配器运行环境 ====================
+
+// adapterEnv 一次采集任务中适配器共享的环境
+type adapterEnv struct {
+	Source      *Source
+	ListTrace   *TraceFile // 轨迹回放类采集源的列表轨迹
+	DetailTrace *TraceFile // 可选的详情轨迹，所有类型的采集源都可用于补充详情
+	LoginTrace  *TraceFile // 可选的登录轨迹
+	Limits      CollectLimits
+	Solver      *CaptchaSolver
+
+	ctx          context.Context // 租用浏览器时等待的 context
+	lease        *browserLease
+	credential   *SourceCredential
+	sessionReady bool // 当前浏览器已恢复会话或完成登录
+}
+
+// Browser 按需从浏览器池租用浏览器，同一任务内共享
+func (env *adapterEnv) Browser() (*rod.Browser, error) {
+	if env.lease == nil {
+		proxyURL := env.Source.Proxy
+		if proxyURL == proxyPoolKeyword {
+			proxyURL = nextPoolProxy()
+		}
+		profile := ""
+		if env.Source.BrowserMode == BrowserModeProfile {
+			profile = profileDirFor(env.Source.Code)
+		}
+		ctx := env.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		lease, err := getBrowserPool().Acquire(ctx, proxyURL, profile)
+		if err != nil {
+			return nil, err
+		}
+		env.lease = lease
+	}
+	return env.lease.Browser(), nil
+}
+
+// reportBrowserResult 记录浏览器代理的使用结果；代理池模式下失败时关闭浏览器，下次启动换用其他代理。
+// 只有网络、连接错误和访问被拦截算作代理失败，轨迹本身的错误不影响代理
+func (env *adapterEnv) reportBrowserResult(ctx context.Context, err error) {
+	if env.lease == nil || env.lease.Proxy() == "" || ctx.Err() != nil || errors.Is(err, errRateLimited) {
+		return
+	}
+	if err != nil && !isProxyFailure(err) {
+		return
+	}
+	reportProxyResult(env.lease.Proxy(), err)
+	if err != nil && env.Source.Proxy == proxyPoolKeyword {
+		log.Printf("🔁 轨迹执行失败，下次将切换代理: %s", maskProxyURL(env.lease.Proxy()))
+		env.lease.Release(false)
+		env.lease = nil
+		env.sessionReady = false
+	}
+}
+
+// traceBrowser 执行轨迹所需的浏览器，HTTP 模式的轨迹不启动浏览器
+func (env *adapterEnv) traceBrowser(trace *TraceFile) (*rod.Browser, error) {
+	if !traceNeedsBrowser(trace) {
+		return nil, nil
+	}
+	return env.Browser()
+}
+
+// runTrace 执行轨迹：按需租用浏览器、恢复登录会话，检测到登录失效时重新登录并重试一次
+func (env *adapterEnv) runTrace(ctx context.Context, trace *TraceFile, params map[string]string) (interface{}, error) {
+	browser, err := env.traceBrowser(trace)
+	if err != nil {
+		return nil, err
+	}
+	if browser != nil {
+		if err := env.prepareSession(ctx, browser); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := executeTrace(ctx, browser, trace, params, env.Solver)
+	if errors.Is(err, errLoggedOut) && browser != nil {
+		log.Printf("🔐 %v，重新登录", err)
+		if err := env.login(ctx, browser); err != nil {
+			return nil, err
+		}
+		data, err = executeTrace(ctx, browser, trace, params, env.Solver)
+	}
+	env.reportBrowserResult(ctx, err)
+	return data, err
+}
+
+// runDetailTrace 用详情轨迹抓取条目详情，未配置详情轨迹时返回 nil
+func (env *adapterEnv) runDetailTrace(ctx context.Context, item map[string]string) (map[string]string, error) {
+	if env.DetailTrace == nil {
+		return nil, nil
+	}
+	data, err := env.runTrace(ctx, env.DetailTrace, map[string]string{"URL": item["url"]})
+	if err != nil {
+		return nil, err
+	}
+	detail, _ := data.(map[string]string)
+	return detail, nil
+}
+
+// Close 归还任务租用的浏览器
+func (env *adapterEnv) Close() {
+	if env.lease != nil {
+		env.lease.Release(false)
+		env.lease = nil
+		env.sessionReady = false
+	}
+}
+
+// sliceIterator 基于已获取列表的迭代器
+type sliceIterator struct {
+	items []map[string]string
+	pos   int
+}
+
+func newSliceIterator(items []map[string]string) *sliceIterator {
+	return &sliceIterator{items: items}
+}
+
+func (it *sliceIterator) Next(ctx context.Context) (map[string]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if it.pos >= len(it.items) {
+		return nil, io.EOF
+	}
+	item := it.items[it.pos]
+	it.pos++
+	return item, nil
+}
+
+// ==================== 列表轨迹分页 ====================
+
+// listPagerKey 列表翻页协调器的 context 键
+type listPagerKey struct{}
+
+// listPager 协调列表轨迹与迭代器：轨迹每提取完一页就暂停，迭代器取完已有条目后才继续翻页，
+// 提前停止迭代（达到条数上限、增量采集遇到已知条目）时不再请求后续页面
+type listPager struct {
+	pages    chan listPage
+	more     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// listPage 截至当前页已提取的全部条目，final 表示轨迹已执行结束
+type listPage struct {
+	items []map[string]string
+	final bool
+	err   error
+}
+
+func newListPager() *listPager {
+	return &listPager{
+		pages: make(chan listPage),
+		more:  make(chan struct{}),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+func withListPager(ctx context.Context, p *listPager) context.Context {
+	return context.WithValue(ctx, listPagerKey{}, p)
+}
+
+func listPagerFrom(ctx context.Context) *listPager {
+	p, _ := ctx.Value(listPagerKey{}).(*listPager)
+	return p
+}
+
+// wait 交出已提取的条目并等待迭代器请求下一页，返回 false 表示不再需要后续页面
+func (p *listPager) wait(ctx context.Context, items []map[string]string) bool {
+	select {
+	case p.pages <- listPage{items: items}:
+	case <-p.stop:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+	select {
+	case <-p.more:
+		return true
+	case <-p.stop:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// finish 交出轨迹的最终结果
+func (p *listPager) finish(ctx context.Context, items []map[string]string, err error) {
+	select {
+	case p.pages <- listPage{items: items, final: true, err: err}:
+	case <-p.stop:
+	case <-ctx.Done():
+	}
+}
+
+// pagedIterator 按需翻页的列表迭代器
+type pagedIterator struct {
+	pager *listPager
+	page  listPage
+	pos   int
+}
+
+// receive 等待轨迹交出下一页或最终结果
+func (it *pagedIterator) receive(ctx context.Context) error {
+	select {
+	case it.page = <-it.pager.pages:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (it *pagedIterator) Next(ctx context.Context) (map[string]string, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if it.pos < len(it.page.items) {
+			item := it.page.items[it.pos]
+			it.pos++
+			return item, nil
+		}
+		if it.page.final {
+			if it.page.err != nil {
+				return nil, it.page.err
+			}
+			return nil, io.EOF
+		}
+		select {
+		case it.pager.more <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if err := it.receive(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Close 停止翻页并等待轨迹结束，之后才能在同一浏览器上执行其他轨迹
+func (it *pagedIterator) Close() error {
+	it.pager.stopOnce.Do(func() { close(it.pager.stop) })
+	<-it.pager.done
+	return nil
+}
+
+// closeListIterator 结束迭代，支持按需翻页的迭代器据此停止后续翻页
+func closeListIterator(iter ListIterator) {
+	if c, ok := iter.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// ==================== 轨迹回放适配器 ====================
+
+// traceAdapter 回放录制的列表/详情轨迹
+type traceAdapter struct {
+	env       *adapterEnv
+	listTrace *TraceFile
+}
+
+func newTraceAdapter(env *adapterEnv) (SourceAdapter, error) {
+	if env.ListTrace == nil {
+		return nil, errNoListTrace
+	}
+	return &traceAdapter{env: env, listTrace: withListLimit(env.ListTrace, env.Limits.MaxItems)}, nil
+}
+
+// Search 在后台执行列表轨迹，带翻页的轨迹每提取完一页暂停，由迭代器按需继续翻页
+func (a *traceAdapter) Search(ctx context.Context, keyword string) (ListIterator, error) {
+	pager := newListPager()
+	go func() {
+		defer close(pager.done)
+		data, err := a.env.runTrace(withListPager(ctx, pager), a.listTrace, map[string]string{"Keyword": keyword})
+		items, _ := data.([]map[string]string)
+		if err == nil {
+			log.Printf("📋 列表采集完成，共 %d 条", len(items))
+		}
+		pager.finish(ctx, items, err)
+	}()
+
+	iter := &pagedIterator{pager: pager}
+	if err := iter.receive(ctx); err != nil {
+		iter.Close()
+		return nil, err
+	}
+	if iter.page.final && iter.page.err != nil {
+		<-pager.done
+		return nil, iter.page.err
+	}
+	return iter, nil
+}
+
+func (a *traceAdapter) Detail(ctx context.Context, item map[string]string) (map[string]string, error) {
+	return a.env.runDetailTrace(ctx, item)
+}
@@ -57,7 +57,7 @@ if errorlevel 1 (
 REM Start main program
 echo [STARTING] Launching main program...
 echo.
-go run main.go
+go run .
 
 REM If program exits abnormally
 echo.
@@ -59,7 +59,7 @@ Write-Host "[STARTING] Launching main program..."
 Write-Host ""
 
 try {
-    go run main.go
+    go run .
 } catch {
     Write-Host ""
     Write-Host "========================================"
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// ==================== 反检测 ====================
+//
+// 浏览器启动时去掉自动化标记（enable-automation、AutomationControlled），
+// 每个页面按采集源的 stealth 配置设置 UA、视口、语言和时区，并在页面脚本执行前隐藏 webdriver 等特征。
+// human 开启后，click/input 步骤模拟鼠标移动、停顿和逐字输入。
+
+const (
+	defaultStealthLocale   = "zh-CN"
+	defaultStealthTimezone = "Asia/Shanghai"
+	defaultStealthViewport = "1366x768"
+)
+
+// StealthOptions 采集源的反检测配置（JSON），未配置的项使用默认值
+type StealthOptions struct {
+	Disabled      bool   `json:"disabled,omitempty"`       // 关闭页面级伪装
+	UserAgent     string `json:"user_agent,omitempty"`     // 默认使用桌面版 Chrome UA
+	Viewport      string `json:"viewport,omitempty"`       // 宽x高，默认 1366x768
+	Locale        string `json:"locale,omitempty"`         // 默认 zh-CN
+	Timezone      string `json:"timezone,omitempty"`       // 默认 Asia/Shanghai
+	KeepWebdriver bool   `json:"keep_webdriver,omitempty"` // 不注入 webdriver 隐藏脚本
+	Human         bool   `json:"human,omitempty"`          // 模拟人工操作：鼠标轨迹、随机停顿、逐字输入
+}
+
+// parseStealthOptions 解析采集源的 stealth 配置，空字符串返回默认配置
+func parseStealthOptions(raw string) (StealthOptions, error) {
+	var opts StealthOptions
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &opts); err != nil {
+			return opts, fmt.Errorf("反检测配置解析失败: %v", err)
+		}
+	}
+	if opts.Viewport != "" {
+		if _, _, ok := parseViewport(opts.Viewport); !ok {
+			return opts, fmt.Errorf("视口格式无效: %s（应为 宽x高，如 1366x768）", opts.Viewport)
+		}
+	}
+	if opts.Timezone != "" {
+		if _, err := time.LoadLocation(opts.Timezone); err != nil {
+			return opts, fmt.Errorf("时区无效: %s", opts.Timezone)
+		}
+	}
+	return opts, nil
+}
+
+// parseViewport 解析 "1366x768"
+func parseViewport(s string) (int, int, bool) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), "x")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	w, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+	h, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err1 != nil || err2 != nil || w <= 0 || h <= 0 {
+		return 0, 0, false
+	}
+	return w, h, true
+}
+
+type stealthKey struct{}
+
+// withStealth 将采集源的反检测配置附加到 context
+func withStealth(ctx context.Context, opts StealthOptions) context.Context {
+	return context.WithValue(ctx, stealthKey{}, opts)
+}
+
+// stealthFrom 读取 context 中的反检测配置
+func stealthFrom(ctx context.Context) StealthOptions {
+	opts, _ := ctx.Value(stealthKey{}).(StealthOptions)
+	return opts
+}
+
+// applyLaunchStealth 设置浏览器进程级的反检测启动参数
+func applyLaunchStealth(l *launcher.Launcher) {
+	w, h, _ := parseViewport(defaultStealthViewport)
+	l.Delete("enable-automation").
+		Set("disable-blink-features", "AutomationControlled").
+		Set("window-size", fmt.Sprintf("%d,%d", w, h)).
+		Set("lang", defaultStealthLocale)
+}
+
+// webdriverMaskScript 在页面脚本执行前隐藏常见的自动化特征
+const webdriverMaskScript = `() => {
+	Object.defineProperty(Navigator.prototype, 'webdriver', { get: () => undefined });
+	if (!window.chrome) {
+		window.chrome = { runtime: {}, app: { isInstalled: false } };
+	}
+	Object.defineProperty(navigator, 'languages', { get: () => LANGUAGES });
+	if (navigator.plugins.length === 0) {
+		Object.defineProperty(navigator, 'plugins', { get: () => [1, 2, 3, 4, 5] });
+	}
+	const query = window.navigator.permissions && window.navigator.permissions.query;
+	if (query) {
+		window.navigator.permissions.query = (p) => p && p.name === 'notifications'
+			? Promise.resolve({ state: Notification.permission })
+			: query.call(window.navigator.permissions, p);
+	}
+}`
+
+// applyPageStealth 按配置设置页面的 UA、视口、语言、时区并注入隐藏脚本
+func applyPageStealth(page *rod.Page, opts StealthOptions) error {
+	if opts.Disabled {
+		return nil
+	}
+	locale := firstNonEmpty(opts.Locale, defaultStealthLocale)
+	timezone := firstNonEmpty(opts.Timezone, defaultStealthTimezone)
+	w, h, _ := parseViewport(firstNonEmpty(opts.Viewport, defaultStealthViewport))
+
+	languages := []string{locale}
+	if base := strings.SplitN(locale, "-", 2)[0]; base != locale {
+		languages = append(languages, base)
+	}
+	acceptLanguage := strings.Join(languages, ",")
+
+	if err := page.SetUserAgent(&proto.NetworkSetUserAgentOverride{
+		UserAgent:      firstNonEmpty(opts.UserAgent, defaultUserAgent),
+		AcceptLanguage: acceptLanguage,
+		Platform:       "Win32",
+	}); err != nil {
+		return fmt.Errorf("设置 UA 失败: %v", err)
+	}
+	if err := page.SetViewport(&proto.EmulationSetDeviceMetricsOverride{
+		Width: w, Height: h, DeviceScaleFactor: 1,
+	}); err != nil {
+		return fmt.Errorf("设置视口失败: %v", err)
+	}
+	if err := (proto.EmulationSetLocaleOverride{Locale: locale}).Call(page); err != nil {
+		log.Printf("⚠️ 设置语言失败: %v", err)
+	}
+	if err := (proto.EmulationSetTimezoneOverride{TimezoneID: timezone}).Call(page); err != nil {
+		log.Printf("⚠️ 设置时区失败: %v", err)
+	}
+
+	if !opts.KeepWebdriver {
+		langJSON, _ := json.Marshal(languages)
+		script := strings.Replace(webdriverMaskScript, "LANGUAGES", string(langJSON), 1)
+		if _, err := page.EvalOnNewDocument("(" + script + ")()"); err != nil {
+			return fmt.Errorf("注入反检测脚本失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// ==================== 模拟人工操作 ====================
+
+// humanPause 随机停顿 min~max 毫秒，可被取消
+func humanPause(ctx context.Context, minMs, maxMs int) error {
+	d := time.Duration(minMs+rand.Intn(maxMs-minMs+1)) * time.Millisecond
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// humanMoveTo 沿带随机拐点的轨迹把鼠标移到元素内的随机位置
+func humanMoveTo(page *rod.Page, elem *rod.Element) error {
+	shape, err := elem.Shape()
+	if err != nil {
+		return err
+	}
+	box := shape.Box()
+	if box == nil || box.Width <= 0 || box.Height <= 0 {
+		return fmt.Errorf("元素不可见")
+	}
+	target := proto.Point{
+		X: box.X + box.Width*(0.3+0.4*rand.Float64()),
+		Y: box.Y + box.Height*(0.3+0.4*rand.Float64()),
+	}
+
+	from := page.Mouse.Position()
+	mid := proto.Point{
+		X: (from.X+target.X)/2 + (rand.Float64()-0.5)*80,
+		Y: (from.Y+target.Y)/2 + (rand.Float64()-0.5)*80,
+	}
+	if err := page.Mouse.MoveLinear(mid, 5+rand.Intn(8)); err != nil {
+		return err
+	}
+	return page.Mouse.MoveLinear(target, 5+rand.Intn(8))
+}
+
+// humanType 逐字输入，字间随机停顿
+func humanType(ctx context.Context, elem *rod.Element, text string) error {
+	for _, r := range text {
+		if err := elem.Input(string(r)); err != nil {
+			return err
+		}
+		if err := humanPause(ctx, 60, 220); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// userAgentFrom HTTP 请求使用的 UA：采集源配置优先
+func userAgentFrom(ctx context.Context) string {
+	return firstNonEmpty(stealthFrom(ctx).UserAgent, defaultUserAgent)
+}
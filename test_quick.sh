@@ -73,5 +73,5 @@ echo "方式 2：只启动服务（如果已安装）"
 echo "   ./deploy.sh start"
 echo ""
 echo "方式 3：直接运行测试"
-echo "   go run main.go"
+echo "   go run ."
 echo ""
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"tender-monitor/traceconv"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/input"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// ==================== 页面交互步骤 ====================
+//
+//   - select：下拉框按 value 或文本选择 {"action": "select", "selector": "#type", "value": "货物类"}
+//   - scroll：滚动到底部/顶部/指定像素；设置 selector + max_items 时持续滚动直到元素数量达到要求（无限滚动列表）
+//   - hover：鼠标悬停（展开菜单）
+//   - press：按键，如 Enter、Tab、Escape；设置 selector 时先聚焦该元素
+//   - switch_frame：进入 selector 指定的 iframe，value 为 main 或留空时回到顶层页面
+//   - switch_tab：切换到新打开的标签页（value 为 last，默认）、原标签页（main）或 URL 匹配正则的标签页
+//   - close_tab：关闭当前标签页并回到原标签页
+//   - eval：执行 JS（表达式或函数），结果写入 var
+//   - screenshot：截取页面或 selector 指定的元素，保存到 data/screenshots，路径写入 var
+
+const (
+	scrollMaxRounds  = 30 // 持续滚动的最大轮数
+	scrollStaleLimit = 3  // 连续多少轮数量不再增长时停止
+)
+
+// pressKeys press 步骤支持的按键
+var pressKeys = map[string]input.Key{
+	"enter": input.Enter, "tab": input.Tab, "escape": input.Escape, "esc": input.Escape,
+	"backspace": input.Backspace, "delete": input.Delete, "space": input.Space,
+	"arrowup": input.ArrowUp, "arrowdown": input.ArrowDown, "arrowleft": input.ArrowLeft, "arrowright": input.ArrowRight,
+	"pageup": input.PageUp, "pagedown": input.PageDown, "home": input.Home, "end": input.End,
+}
+
+// isPageAction 是否为本文件处理的交互步骤
+func isPageAction(action string) bool {
+	switch action {
+	case "select", "scroll", "hover", "press", "switch_frame", "switch_tab", "close_tab", "eval", "screenshot":
+		return true
+	}
+	return false
+}
+
+// runPageAction 执行交互步骤
+func (r *browserRun) runPageAction(step TraceStep) error {
+	selector := replaceParams(step.Selector, r.vars)
+	value := replaceParams(step.Value, r.vars)
+
+	switch step.Action {
+	case "select":
+		return r.selectOption(selector, value, step.Type, step.Candidates)
+	case "scroll":
+		return r.scroll(selector, value, step.MaxItems, step.Candidates)
+	case "hover":
+		elem, err := r.findTarget(selector, step.Candidates)
+		if err != nil {
+			return fmt.Errorf("找不到悬停元素 '%s': %v", selector, err)
+		}
+		if r.stealth.Human {
+			if err := humanMoveTo(r.page, elem); err != nil {
+				log.Printf("⚠️ 鼠标移动失败: %v", err)
+			}
+		}
+		if err := elem.Hover(); err != nil {
+			return fmt.Errorf("悬停失败: %v", err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	case "press":
+		return r.press(selector, value, step.Candidates)
+	case "switch_frame":
+		return r.switchFrame(selector, value)
+	case "switch_tab":
+		return r.switchTab(value)
+	case "close_tab":
+		return r.closeTab()
+	case "eval":
+		return r.eval(value, step.Var)
+	case "screenshot":
+		return r.screenshot(selector, value, step.Var)
+	}
+	return nil
+}
+
+// selectOption 选择下拉框选项：matchBy 为 value/text，留空时先按 value 再按文本匹配
+func (r *browserRun) selectOption(selector, value, matchBy string, candidates []traceconv.SelectorCandidate) error {
+	elem, err := r.findTarget(selector, candidates)
+	if err != nil {
+		return fmt.Errorf("找不到下拉框 '%s': %v", selector, err)
+	}
+	if matchBy == "" || matchBy == "value" {
+		css := fmt.Sprintf("option[value=%s]", strconv.Quote(value))
+		if err = elem.Select([]string{css}, true, rod.SelectorTypeCSSSector); err == nil || matchBy == "value" {
+			if err != nil {
+				return fmt.Errorf("选择 '%s' 失败: %v", value, err)
+			}
+			return nil
+		}
+	}
+	if err := elem.Select([]string{"^" + regexp.QuoteMeta(value) + "$"}, true, rod.SelectorTypeRegex); err != nil {
+		if err := elem.Select([]string{value}, true, rod.SelectorTypeText); err != nil {
+			return fmt.Errorf("选择 '%s' 失败: %v", value, err)
+		}
+	}
+	return nil
+}
+
+// scroll 滚动页面
+func (r *browserRun) scroll(selector, value string, minCount int, candidates []traceconv.SelectorCandidate) error {
+	if selector != "" && minCount > 0 {
+		return r.scrollUntilCount(selector, minCount)
+	}
+	x, y, hasPosition := traceconv.ParseScrollPosition(value)
+	if selector != "" {
+		elem, err := r.findTarget(selector, candidates)
+		if err != nil {
+			return fmt.Errorf("找不到滚动目标 '%s': %v", selector, err)
+		}
+		if !hasPosition {
+			return elem.ScrollIntoView()
+		}
+		// 录制的位置是元素内部的滚动位置
+		if _, err := elem.Eval(`(x, y) => this.scrollTo(x, y)`, x, y); err != nil {
+			return fmt.Errorf("滚动失败: %v", err)
+		}
+		time.Sleep(time.Second)
+		return nil
+	}
+
+	var js string
+	switch {
+	case hasPosition:
+		js = fmt.Sprintf(`() => window.scrollTo(%d, %d)`, x, y)
+	case value == "" || value == "bottom":
+		js = `() => window.scrollTo(0, document.body.scrollHeight)`
+	case value == "top":
+		js = `() => window.scrollTo(0, 0)`
+	default:
+		px, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("scroll 的 value 应为 bottom、top、像素数或 x,y 位置: %s", value)
+		}
+		js = fmt.Sprintf(`() => window.scrollBy(0, %d)`, px)
+	}
+	if _, err := r.page.Eval(js); err != nil {
+		return fmt.Errorf("滚动失败: %v", err)
+	}
+	time.Sleep(time.Second)
+	return nil
+}
+
+// scrollUntilCount 反复滚动到最后一个元素，直到元素数量达到 minCount 或不再增长（无限滚动列表）
+func (r *browserRun) scrollUntilCount(selector string, minCount int) error {
+	sel := parseFieldSelector(selector)
+	last, stale := 0, 0
+	for round := 0; round < scrollMaxRounds; round++ {
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+		elems, err := queryElements(r.page, sel)
+		if err != nil {
+			return fmt.Errorf("查找滚动元素失败 '%s': %v", selector, err)
+		}
+		if len(elems) >= minCount {
+			break
+		}
+		if len(elems) == last {
+			if stale++; stale >= scrollStaleLimit {
+				break
+			}
+		} else {
+			last, stale = len(elems), 0
+		}
+
+		if len(elems) > 0 {
+			elems.Last().ScrollIntoView()
+		}
+		r.page.Eval(`() => window.scrollTo(0, document.body.scrollHeight)`)
+		time.Sleep(1500 * time.Millisecond)
+	}
+	elems, _ := queryElements(r.page, sel)
+	log.Printf("📜 滚动加载完成，共 %d 个元素", len(elems))
+	return nil
+}
+
+// press 按键，selector 非空时先聚焦元素
+func (r *browserRun) press(selector, value string, candidates []traceconv.SelectorCandidate) error {
+	key, ok := pressKeys[strings.ToLower(strings.TrimSpace(value))]
+	if !ok {
+		return fmt.Errorf("不支持的按键: %s", value)
+	}
+	if selector != "" {
+		elem, err := r.findTarget(selector, candidates)
+		if err != nil {
+			return fmt.Errorf("找不到按键元素 '%s': %v", selector, err)
+		}
+		if err := elem.Focus(); err != nil {
+			log.Printf("⚠️ 聚焦失败: %v", err)
+		}
+	}
+	if err := r.page.Keyboard.Type(key); err != nil {
+		return fmt.Errorf("按键失败: %v", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	return nil
+}
+
+// switchFrame 进入 iframe 或回到顶层页面；value 为下标路径（如 0.1）时按录制的 frame 层级逐层进入
+func (r *browserRun) switchFrame(selector, value string) error {
+	if frame, ok := traceconv.ParseFrameIndexPath(value); ok && selector == "" {
+		for _, idx := range frame {
+			elem, err := r.page.ElementByJS(rod.Eval(frameElementJS, idx))
+			if err != nil {
+				return fmt.Errorf("找不到第 %d 个子框架（路径 %s）: %v", idx, value, err)
+			}
+			if err := r.enterFrame(elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if selector == "" || value == "main" {
+		r.page = r.tab
+		r.item = nil
+		return nil
+	}
+	elem, err := r.findElement(selector)
+	if err != nil {
+		return fmt.Errorf("找不到 iframe '%s': %v", selector, err)
+	}
+	return r.enterFrame(elem)
+}
+
+// frameElementJS 当前文档中第 idx 个子框架（window.frames 顺序，与 Chrome 录制的 frame 下标一致）对应的 iframe 元素
+const frameElementJS = `(idx) => Array.from(document.querySelectorAll('iframe, frame')).find((f) => f.contentWindow === window.frames[idx]) || null`
+
+// enterFrame 进入 iframe 元素，后续步骤在其中执行
+func (r *browserRun) enterFrame(elem *rod.Element) error {
+	frame, err := elem.Frame()
+	if err != nil {
+		return fmt.Errorf("进入 iframe 失败: %v", err)
+	}
+	if err := frame.WaitLoad(); err != nil {
+		log.Printf("⚠️ iframe 加载未完成: %v", err)
+	}
+	r.page = frame.Timeout(30 * time.Second)
+	r.item = nil
+	return nil
+}
+
+// switchTab 切换标签页：last 为原标签页打开的最新标签页，main 为原标签页，其他值按 URL 正则匹配
+func (r *browserRun) switchTab(value string) error {
+	if value == "main" {
+		r.useTab(r.root)
+		return nil
+	}
+
+	var match *regexp.Regexp
+	if value != "" && value != "last" {
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return fmt.Errorf("标签页匹配正则无效: %v", err)
+		}
+		match = re
+	}
+
+	// 新标签页可能还在打开，最多等待 10 秒
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if tab := r.findTab(match); tab != nil {
+			if err := tab.WaitLoad(); err != nil {
+				log.Printf("⚠️ 标签页加载未完成: %v", err)
+			}
+			if err := applyPageStealth(tab, r.stealth); err != nil {
+				log.Printf("⚠️ 反检测设置失败: %v", err)
+			}
+			r.useTab(tab)
+			log.Printf("🗂️ 已切换标签页: %s", tab.MustInfo().URL)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("未找到要切换的标签页: %s", firstNonEmpty(value, "last"))
+		}
+		select {
+		case <-time.After(500 * time.Millisecond):
+		case <-r.ctx.Done():
+			return r.ctx.Err()
+		}
+	}
+}
+
+// findTab 查找由本次轨迹打开的标签页
+func (r *browserRun) findTab(match *regexp.Regexp) *rod.Page {
+	targets, err := proto.TargetGetTargets{}.Call(r.browser)
+	if err != nil {
+		return nil
+	}
+	opened := map[proto.TargetTargetID]bool{r.root.TargetID: true}
+	var found *rod.Page
+	for _, t := range targets.TargetInfos {
+		if t.Type != proto.TargetTargetInfoTypePage || !opened[t.OpenerID] || t.TargetID == r.tab.TargetID {
+			continue
+		}
+		opened[t.TargetID] = true
+		if match != nil && !match.MatchString(t.URL) {
+			continue
+		}
+		page, err := r.browser.PageFromTarget(t.TargetID)
+		if err != nil {
+			continue
+		}
+		found = page
+	}
+	return found
+}
+
+// useTab 切换当前操作的标签页
+func (r *browserRun) useTab(tab *rod.Page) {
+	if tab != r.root {
+		r.extraTabs = append(r.extraTabs, tab)
+	}
+	tab = tab.Timeout(30 * time.Second)
+	r.tab, r.page, r.item = tab, tab, nil
+}
+
+// closeTab 关闭当前标签页并回到原标签页
+func (r *browserRun) closeTab() error {
+	if r.tab.TargetID == r.root.TargetID {
+		return fmt.Errorf("不能关闭原标签页")
+	}
+	if err := r.tab.Close(); err != nil {
+		log.Printf("⚠️ 关闭标签页失败: %v", err)
+	}
+	r.useTab(r.root)
+	return nil
+}
+
+// closeExtraTabs 关闭执行过程中切换过的标签页
+func (r *browserRun) closeExtraTabs() {
+	for _, tab := range r.extraTabs {
+		tab.Close()
+	}
+}
+
+// eval 执行 JS，结果写入变量
+func (r *browserRun) eval(js, varName string) error {
+	if strings.TrimSpace(js) == "" {
+		return fmt.Errorf("eval 步骤缺少 value")
+	}
+	res, err := r.page.Eval(js)
+	if err != nil {
+		return fmt.Errorf("执行脚本失败: %v", err)
+	}
+	if varName != "" {
+		var value string
+		if s, ok := res.Value.Val().(string); ok {
+			value = s
+		} else if !res.Value.Nil() {
+			value = res.Value.JSON("", "")
+		}
+		r.vars[varName] = value
+		log.Printf("📌 %s = %s", varName, value)
+	}
+	return nil
+}
+
+// screenshot 截图保存到 data/screenshots，文件名可由 value 指定
+func (r *browserRun) screenshot(selector, name, varName string) error {
+	var img []byte
+	var err error
+	if selector != "" {
+		elem, findErr := r.findElement(selector)
+		if findErr != nil {
+			return fmt.Errorf("找不到截图元素 '%s': %v", selector, findErr)
+		}
+		img, err = elem.Screenshot(proto.PageCaptureScreenshotFormatPng, 0)
+	} else {
+		img, err = r.page.Screenshot(true, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("截图失败: %v", err)
+	}
+
+	dir := filepath.Join(dataDir, "screenshots")
+	os.MkdirAll(dir, 0755)
+	if name == "" {
+		name = fmt.Sprintf("%s_%s", r.trace.Name, time.Now().Format("20060102_150405"))
+	}
+	name = profileNameSanitizer.ReplaceAllString(strings.TrimSuffix(name, ".png"), "_") + ".png"
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, img, 0644); err != nil {
+		return fmt.Errorf("保存截图失败: %v", err)
+	}
+	log.Printf("📸 截图已保存: %s", path)
+	if varName != "" {
+		r.vars[varName] = path
+	}
+	return nil
+}
+
+// ==================== 列表翻页 ====================
+
+// waitForConsumer 把已提取的条目交给迭代器，等其取完后再翻页。
+// 暂停期间释放主机并发名额，避免同一主机的详情轨迹等待名额
+func (r *browserRun) waitForConsumer(pager *listPager, rows []map[string]string) (bool, error) {
+	if r.releasePage != nil {
+		r.releasePage()
+		r.releasePage = nil
+	}
+	if !pager.wait(r.ctx, rows) {
+		return false, nil
+	}
+	release, err := acquirePage(r.ctx, r.currentURL())
+	if err != nil {
+		return false, err
+	}
+	r.releasePage = release
+	return true, nil
+}
+
+// paginate 点击下一页按钮继续提取列表，直到达到页数/条数上限、按钮消失或禁用、或某页没有数据
+func (r *browserRun) paginate(step TraceStep) error {
+	maxPages := step.Pagination.MaxPages
+	if maxPages <= 0 {
+		maxPages = traceconv.DefaultMaxPages
+	}
+	next := replaceParams(step.Pagination.NextButton, r.vars)
+
+	for pageNo := 2; pageNo <= maxPages; pageNo++ {
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+		rows, _ := r.data.([]map[string]string)
+		if step.MaxItems > 0 && len(rows) >= step.MaxItems {
+			r.data = rows[:step.MaxItems]
+			break
+		}
+		if pager := listPagerFrom(r.ctx); pager != nil {
+			more, err := r.waitForConsumer(pager, rows)
+			if err != nil {
+				return err
+			}
+			if !more {
+				log.Printf("📄 采集已停止，不再翻页")
+				break
+			}
+		}
+
+		elems, err := findElements(r.page, next)
+		if err != nil || len(elems) == 0 {
+			log.Printf("📄 未找到下一页按钮，翻页结束")
+			break
+		}
+		btn := elems.First()
+		if disabled, _ := btn.Eval(`() => this.disabled || this.classList.contains('disabled') || this.getAttribute('aria-disabled') === 'true'`); disabled != nil && disabled.Value.Bool() {
+			log.Printf("📄 已是最后一页")
+			break
+		}
+		if err := btn.Click(proto.InputMouseButtonLeft, 1); err != nil {
+			return fmt.Errorf("点击下一页失败: %v", err)
+		}
+
+		log.Printf("📄 提取第 %d 页", pageNo)
+		// 翻到最后一页之后可能没有数据，后续页不计入健康检查
+		list := extractList(r.page, step, nil)
+		if len(list) == 0 {
+			break
+		}
+		r.data = mergeExtracted(r.data, list)
+	}
+
+	if rows, ok := r.data.([]map[string]string); ok && step.MaxItems > 0 && len(rows) > step.MaxItems {
+		r.data = rows[:step.MaxItems]
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"tender-monitor/traceconv"
+)
+
+// ==================== 轨迹编辑器：步骤接口 ====================
+//
+// 步骤路径与校验结果中的 step 一致，从 1 开始编号：
+// "3" 为第 3 个顶层步骤，"3.steps.2" 为其第 2 个子步骤，"3.else.1" 为 if 的 else 分支第 1 个步骤。
+// 编辑基于轨迹的最新版本，每次修改保存为不生效的新版本，编辑完成后通过 activate 接口校验并启用，
+// 编辑过程中的中间状态不会被采集使用。
+
+// editableTrace 编辑器加载的轨迹：Version 为最新版本（编辑的基础），ActiveVersion 为采集使用的生效版本
+type editableTrace struct {
+	ID            int
+	SourceID      int
+	Type          string
+	Status        string
+	Version       int
+	ActiveVersion int
+	Trace         *TraceFile
+}
+
+// stepEditRequest 步骤修改请求
+type stepEditRequest struct {
+	Path        string     `json:"path"` // 插入位置，默认追加到末尾
+	Step        *TraceStep `json:"step"`
+	To          string     `json:"to"`           // 移动的目标位置，按移出原步骤后的路径计算
+	BaseVersion int        `json:"base_version"` // 必填，编辑所基于的版本，与最新版本不一致时拒绝，避免覆盖他人的修改
+	Author      string     `json:"author"`
+	Comment     string     `json:"comment"`
+}
+
+// loadEditableTrace 读取轨迹的最新版本（可能是尚未启用的编辑结果）
+func loadEditableTrace(traceID int) (*editableTrace, error) {
+	t := &editableTrace{ID: traceID}
+	err := db.QueryRow(`SELECT COALESCE(source_id, 0), type, COALESCE(status, ''), COALESCE(active_version, 0),
+		(SELECT COALESCE(MAX(version), 0) FROM trace_versions WHERE trace_id = traces.id) FROM traces WHERE id = ?`, traceID).
+		Scan(&t.SourceID, &t.Type, &t.Status, &t.ActiveVersion, &t.Version)
+	if err != nil {
+		return nil, fmt.Errorf("轨迹不存在: %d", traceID)
+	}
+	v, err := getTraceVersion(traceID, t.Version)
+	if err != nil {
+		return nil, err
+	}
+	if t.Trace, err = parseTraceFile(v.RawContent); err != nil {
+		return nil, fmt.Errorf("解析轨迹失败: %v", err)
+	}
+	if t.Trace.Type == "" {
+		t.Trace.Type = t.Type
+	}
+	return t, nil
+}
+
+// resolveStepPath 解析步骤路径，返回步骤所在的列表和下标（从 0 开始）。
+// allowEnd 为 true 时最后一级允许指向列表末尾之后（用于插入）
+func resolveStepPath(steps *[]TraceStep, path string, allowEnd bool) (*[]TraceStep, int, error) {
+	segs := strings.Split(path, ".")
+	if len(segs)%2 == 0 {
+		return nil, 0, fmt.Errorf("步骤路径无效: %s", path)
+	}
+	list := steps
+	for i := 0; ; i += 2 {
+		last := i == len(segs)-1
+		limit := len(*list)
+		if allowEnd && last {
+			limit++
+		}
+		n, err := strconv.Atoi(segs[i])
+		if err != nil || n < 1 || n > limit {
+			return nil, 0, fmt.Errorf("步骤 %s 不存在", path)
+		}
+		if last {
+			return list, n - 1, nil
+		}
+		step := &(*list)[n-1]
+		switch {
+		case segs[i+1] == "steps" && (step.Action == "if" || step.Action == "foreach"):
+			list = &step.Steps
+		case segs[i+1] == "else" && step.Action == "if":
+			list = &step.Else
+		default:
+			return nil, 0, fmt.Errorf("步骤路径无效: %s（%s 步骤没有 %s）", path, step.Action, segs[i+1])
+		}
+	}
+}
+
+func insertStep(list *[]TraceStep, idx int, step TraceStep) {
+	*list = append(*list, TraceStep{})
+	copy((*list)[idx+1:], (*list)[idx:])
+	(*list)[idx] = step
+}
+
+func removeStep(list *[]TraceStep, idx int) TraceStep {
+	step := (*list)[idx]
+	*list = append((*list)[:idx], (*list)[idx+1:]...)
+	return step
+}
+
+// stepIssues 返回路径下（含子步骤）的错误级问题
+func stepIssues(issues []traceconv.Issue, path string) []traceconv.Issue {
+	var found []traceconv.Issue
+	for _, issue := range issues {
+		if issue.Level == traceconv.LevelError && (issue.Step == path || strings.HasPrefix(issue.Step, path+".")) {
+			found = append(found, issue)
+		}
+	}
+	return found
+}
+
+// traceEntryURL 轨迹第一个 navigate 步骤的地址
+func traceEntryURL(trace *TraceFile) string {
+	for _, step := range trace.Steps {
+		if step.Action == "navigate" && step.URL != "" {
+			return step.URL
+		}
+	}
+	return ""
+}
+
+// editTraceSteps 执行一次步骤修改：op 修改 t.Trace 并返回被修改步骤的最终路径（删除时为空）。
+// 被修改的步骤存在错误级校验问题时不保存，返回这些问题；保存的新版本不生效，需通过 activate 启用
+func editTraceSteps(t *editableTrace, req stepEditRequest, summary string, op func(steps *[]TraceStep) (string, error)) (int, []traceconv.Issue, []traceconv.Issue, error) {
+	path, err := op(&t.Trace.Steps)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	issues := traceconv.Lint(t.Trace)
+	if path != "" {
+		if blocking := stepIssues(issues, path); len(blocking) > 0 {
+			return 0, issues, blocking, fmt.Errorf("步骤 %s 校验未通过", path)
+		}
+	}
+
+	raw, _ := json.MarshalIndent(t.Trace, "", "  ")
+	comment := firstNonEmpty(req.Comment, "编辑器: "+summary)
+	_, version, err := saveTraceDraft(t.SourceID, t.Type, t.Trace.Name, string(raw), traceEntryURL(t.Trace), req.Author, comment)
+	if err != nil {
+		return 0, issues, nil, err
+	}
+	log.Printf("✏️ 轨迹 %d %s，已保存为版本 %d（未启用）", t.ID, summary, version)
+	return version, issues, nil, nil
+}
+
+// handleTraceSteps 步骤接口，由 handleTraceVersions 分发。修改请求都需要 base_version：
+//
+//	GET    /api/traces/{id}/steps               最新版本的步骤和校验结果
+//	POST   /api/traces/{id}/steps               插入步骤 {"path": "3", "step": {...}}，path 省略时追加
+//	PUT    /api/traces/{id}/steps/{path}        替换步骤 {"step": {...}}
+//	DELETE /api/traces/{id}/steps/{path}        删除步骤
+//	POST   /api/traces/{id}/steps/{path}/move   移动步骤 {"to": "1"}
+//	POST   /api/traces/{id}/steps/activate      校验并启用编辑后的版本
+func handleTraceSteps(w http.ResponseWriter, r *http.Request, traceID int, parts []string) {
+	t, err := loadEditableTrace(traceID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if r.Method == "GET" && len(parts) == 0 {
+		issues := traceconv.Lint(t.Trace)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": map[string]interface{}{
+			"trace_id": t.ID, "version": t.Version, "active_version": t.ActiveVersion, "name": t.Trace.Name, "type": t.Type, "status": t.Status, "mode": t.Trace.Mode,
+			"steps": t.Trace.Steps, "issues": issues, "valid": !traceconv.HasErrors(issues)}})
+		return
+	}
+
+	var req stepEditRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.BaseVersion <= 0 {
+		http.Error(w, "缺少 base_version", http.StatusBadRequest)
+		return
+	}
+	if req.BaseVersion != t.Version {
+		http.Error(w, fmt.Sprintf("轨迹已被修改（当前版本 %d，编辑基于版本 %d），请刷新后重试", t.Version, req.BaseVersion), http.StatusConflict)
+		return
+	}
+
+	if r.Method == "POST" && len(parts) == 1 && parts[0] == "activate" {
+		issues, err := activateCheckedTraceVersion(t.ID, t.Version)
+		if issues != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": err.Error(), "issues": issues})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("✅ 轨迹 %d 已启用版本 %d", t.ID, t.Version)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": map[string]int{"trace_id": t.ID, "active_version": t.Version}})
+		return
+	}
+
+	var summary string
+	var op func(steps *[]TraceStep) (string, error)
+	switch {
+	case r.Method == "POST" && len(parts) == 0:
+		if req.Step == nil {
+			http.Error(w, "缺少 step", http.StatusBadRequest)
+			return
+		}
+		path := firstNonEmpty(req.Path, strconv.Itoa(len(t.Trace.Steps)+1))
+		summary = fmt.Sprintf("插入步骤 %s (%s)", path, req.Step.Action)
+		op = func(steps *[]TraceStep) (string, error) {
+			list, idx, err := resolveStepPath(steps, path, true)
+			if err != nil {
+				return "", err
+			}
+			insertStep(list, idx, *req.Step)
+			return path, nil
+		}
+
+	case r.Method == "PUT" && len(parts) == 1:
+		if req.Step == nil {
+			http.Error(w, "缺少 step", http.StatusBadRequest)
+			return
+		}
+		path := parts[0]
+		summary = fmt.Sprintf("修改步骤 %s (%s)", path, req.Step.Action)
+		op = func(steps *[]TraceStep) (string, error) {
+			list, idx, err := resolveStepPath(steps, path, false)
+			if err != nil {
+				return "", err
+			}
+			(*list)[idx] = *req.Step
+			return path, nil
+		}
+
+	case r.Method == "DELETE" && len(parts) == 1:
+		path := parts[0]
+		summary = fmt.Sprintf("删除步骤 %s", path)
+		op = func(steps *[]TraceStep) (string, error) {
+			list, idx, err := resolveStepPath(steps, path, false)
+			if err != nil {
+				return "", err
+			}
+			removeStep(list, idx)
+			return "", nil
+		}
+
+	case r.Method == "POST" && len(parts) == 2 && parts[1] == "move":
+		from, to := parts[0], req.To
+		if to == "" {
+			http.Error(w, "缺少 to", http.StatusBadRequest)
+			return
+		}
+		summary = fmt.Sprintf("移动步骤 %s → %s", from, to)
+		op = func(steps *[]TraceStep) (string, error) {
+			list, idx, err := resolveStepPath(steps, from, false)
+			if err != nil {
+				return "", err
+			}
+			step := removeStep(list, idx)
+			if list, idx, err = resolveStepPath(steps, to, true); err != nil {
+				return "", err
+			}
+			insertStep(list, idx, step)
+			return to, nil
+		}
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	version, issues, blocking, err := editTraceSteps(t, req, summary, op)
+	if blocking != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": err.Error(), "issues": blocking})
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": map[string]interface{}{
+		"trace_id": t.ID, "version": version, "active_version": t.ActiveVersion, "steps": t.Trace.Steps, "issues": issues, "valid": !traceconv.HasErrors(issues)}})
+}
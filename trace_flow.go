@@ -0,0 +1,425 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"tender-monitor/traceconv"
+
+	"github.com/go-rod/rod"
+)
+
+// ==================== 轨迹流程控制 ====================
+//
+// 在线性步骤之外支持：
+//   - if：条件成立执行 steps，否则执行 else
+//     {"action": "if", "condition": {"exists": ".popup .close"}, "steps": [{"action": "click", "selector": ".popup .close"}]}
+//   - foreach：遍历值列表或页面元素，每次把当前值写入变量后执行 steps
+//     {"action": "foreach", "var": "Region", "values": ["北京", "上海"], "steps": [...]}
+//     {"action": "foreach", "var": "Tab", "selector": ".tabs li", "steps": [{"action": "click", "selector": "@item"}]}
+//   - set：从元素文本/属性（选择器表达式）或模板设置变量
+//     {"action": "set", "var": "Total", "selector": ".total | trim"}
+//
+// 步骤中的 URL、选择器、输入值均按 text/template 渲染，可引用轨迹参数和变量，并可使用
+// today、date "-7d"、now "2006-01-02 15:04" 等辅助函数。
+// 遍历元素时，click/input/set 等步骤的选择器可用 "@item" 表示当前元素，"@item .sub" 在当前元素内查找。
+// 多次执行的列表提取结果会合并。
+
+// TraceCondition if 步骤的条件，设置的各项需同时满足
+type TraceCondition = traceconv.TraceCondition
+
+// flowScope 流程控制步骤需要的页面能力，浏览器模式和 HTTP 模式分别实现
+type flowScope interface {
+	variables() map[string]string
+	currentURL() string
+	exists(selector string) bool
+	fieldValue(expr string) (string, bool)
+	forEachElement(selector string, limit int, fn func(i int, text string) error) error
+}
+
+// itemSelectorPrefix 遍历元素时引用当前元素的选择器
+const itemSelectorPrefix = "@item"
+
+// isFlowAction 是否为流程控制步骤
+func isFlowAction(action string) bool {
+	switch action {
+	case "if", "foreach", "set":
+		return true
+	}
+	return false
+}
+
+// runFlowStep 执行流程控制步骤，不是流程控制步骤时返回 false
+func runFlowStep(step TraceStep, scope flowScope, run func([]TraceStep) error) (bool, error) {
+	vars := scope.variables()
+
+	switch step.Action {
+	case "if":
+		if step.Condition == nil {
+			return true, fmt.Errorf("if 步骤缺少 condition")
+		}
+		ok, err := evalCondition(scope, step.Condition)
+		if err != nil {
+			return true, err
+		}
+		log.Printf("🔀 条件%s成立", map[bool]string{true: "", false: "不"}[ok])
+		if ok {
+			return true, run(step.Steps)
+		}
+		return true, run(step.Else)
+
+	case "foreach":
+		if step.Var == "" {
+			return true, fmt.Errorf("foreach 步骤缺少 var")
+		}
+		if len(step.Values) > 0 {
+			for i, raw := range step.Values {
+				value := replaceParams(raw, vars)
+				log.Printf("🔁 %s = %s (%d/%d)", step.Var, value, i+1, len(step.Values))
+				vars[step.Var] = value
+				vars[step.Var+"Index"] = strconv.Itoa(i + 1)
+				if err := run(step.Steps); err != nil {
+					return true, err
+				}
+			}
+			return true, nil
+		}
+		if step.Selector == "" {
+			return true, fmt.Errorf("foreach 步骤需要 values 或 selector")
+		}
+		selector := replaceParams(step.Selector, vars)
+		return true, scope.forEachElement(selector, step.MaxItems, func(i int, text string) error {
+			log.Printf("🔁 %s = %s (第 %d 个元素)", step.Var, text, i+1)
+			vars[step.Var] = text
+			vars[step.Var+"Index"] = strconv.Itoa(i + 1)
+			return run(step.Steps)
+		})
+
+	case "set":
+		if step.Var == "" {
+			return true, fmt.Errorf("set 步骤缺少 var")
+		}
+		var value string
+		if step.Selector != "" {
+			v, ok := scope.fieldValue(replaceParams(step.Selector, vars))
+			if !ok {
+				log.Printf("⚠️ set %s: 未找到元素 %s", step.Var, step.Selector)
+			}
+			value = v
+		} else {
+			value = replaceParams(step.Value, vars)
+		}
+		vars[step.Var] = value
+		log.Printf("📌 %s = %s", step.Var, value)
+		return true, nil
+	}
+	return false, nil
+}
+
+// evalCondition 判断 if 条件
+func evalCondition(scope flowScope, cond *TraceCondition) (bool, error) {
+	vars := scope.variables()
+	ok := true
+
+	if cond.Exists != "" {
+		ok = ok && scope.exists(replaceParams(cond.Exists, vars))
+	}
+	if cond.URLMatches != "" {
+		re, err := regexp.Compile(replaceParams(cond.URLMatches, vars))
+		if err != nil {
+			return false, fmt.Errorf("url_matches 正则无效: %v", err)
+		}
+		ok = ok && re.MatchString(scope.currentURL())
+	}
+	if cond.TextContains != "" {
+		selector := replaceParams(cond.Selector, vars)
+		if selector == "" {
+			selector = "body"
+		}
+		text, _ := scope.fieldValue(selector)
+		ok = ok && strings.Contains(text, replaceParams(cond.TextContains, vars))
+	}
+	if cond.Var != "" {
+		ok = ok && vars[cond.Var] == replaceParams(cond.Equals, vars)
+	}
+
+	if cond.Not {
+		ok = !ok
+	}
+	return ok, nil
+}
+
+// splitItemSelector 拆分 "@item .sub"，返回是否引用当前元素及元素内的子选择器
+func splitItemSelector(selector string) (bool, string) {
+	selector = strings.TrimSpace(selector)
+	if selector == itemSelectorPrefix {
+		return true, ""
+	}
+	if strings.HasPrefix(selector, itemSelectorPrefix+" ") {
+		return true, strings.TrimSpace(strings.TrimPrefix(selector, itemSelectorPrefix))
+	}
+	return false, selector
+}
+
+// copyParams 复制参数，步骤中设置的变量不影响调用方
+func copyParams(params map[string]string) map[string]string {
+	vars := make(map[string]string, len(params))
+	for k, v := range params {
+		vars[k] = v
+	}
+	return vars
+}
+
+// mergeExtracted 合并多次列表提取的结果（foreach 中每次搜索各提取一页）
+func mergeExtracted(prev interface{}, list []map[string]string) interface{} {
+	if existing, ok := prev.([]map[string]string); ok {
+		return append(existing, list...)
+	}
+	return list
+}
+
+// ==================== 浏览器模式 ====================
+
+func (r *browserRun) variables() map[string]string {
+	return r.vars
+}
+
+func (r *browserRun) currentURL() string {
+	info, err := r.page.Info()
+	if err != nil {
+		return ""
+	}
+	return info.URL
+}
+
+// itemScope 解析 @item 选择器：返回当前元素和元素内的子选择器
+func (r *browserRun) itemScope(selector string) (*rod.Element, string, error) {
+	if r.item == nil {
+		return nil, "", fmt.Errorf("%s 只能在 foreach 遍历元素时使用", itemSelectorPrefix)
+	}
+	_, sub := splitItemSelector(selector)
+	return r.item, sub, nil
+}
+
+// findElement 查找步骤操作的元素，支持 @item
+func (r *browserRun) findElement(selector string) (*rod.Element, error) {
+	if isItem, _ := splitItemSelector(selector); !isItem {
+		return findElement(r.page, selector)
+	}
+	item, sub, err := r.itemScope(selector)
+	if err != nil || sub == "" {
+		return item, err
+	}
+	return queryElement(item, parseFieldSelector(sub))
+}
+
+// findTarget 查找步骤的目标元素：设置了候选选择器时按顺序尝试主选择器和候选选择器，并记录实际命中的选择器
+func (r *browserRun) findTarget(selector string, candidates []traceconv.SelectorCandidate) (*rod.Element, error) {
+	if isItem, _ := splitItemSelector(selector); len(candidates) == 0 || isItem {
+		return r.findElement(selector)
+	}
+	// 主选择器最先尝试，候选按评分从高到低
+	candidates = append([]traceconv.SelectorCandidate(nil), candidates...)
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	selectors := []string{selector}
+	for _, c := range candidates {
+		selectors = append(selectors, replaceParams(c.Selector, r.vars))
+	}
+	elem, matched, err := findFirstMatch(r.page, selectors)
+	if err != nil {
+		return nil, fmt.Errorf("%v（已尝试 %d 个候选选择器）", err, len(candidates))
+	}
+	if matched == 0 {
+		log.Printf("🎯 命中主选择器: %s（评分 %d）", selector, traceconv.ScoreSelector(selector))
+	} else {
+		log.Printf("🔁 主选择器 '%s' 未匹配，命中候选选择器 %d/%d: %s（评分 %d）",
+			selector, matched, len(candidates), selectors[matched], candidates[matched-1].Score)
+		extractProbeFrom(r.ctx).recordFallback(selector, selectors[matched])
+	}
+	return elem, nil
+}
+
+func (r *browserRun) exists(selector string) bool {
+	if isItem, _ := splitItemSelector(selector); !isItem {
+		elems, err := findElements(r.page, selector)
+		return err == nil && len(elems) > 0
+	}
+	item, sub, err := r.itemScope(selector)
+	if err != nil || sub == "" {
+		return err == nil
+	}
+	elems, err := queryElements(item, parseFieldSelector(sub))
+	return err == nil && len(elems) > 0
+}
+
+func (r *browserRun) fieldValue(expr string) (string, bool) {
+	if isItem, _ := splitItemSelector(expr); !isItem {
+		return extractField(r.page, expr, r.currentURL())
+	}
+	item, sub, err := r.itemScope(expr)
+	if err != nil {
+		return "", false
+	}
+	if sub == "" {
+		text, err := item.Text()
+		return strings.TrimSpace(text), err == nil
+	}
+	return extractField(item, sub, r.currentURL())
+}
+
+// forEachElement 遍历元素；每次重新查询，子步骤改变页面后仍按序号定位
+func (r *browserRun) forEachElement(selector string, limit int, fn func(i int, text string) error) error {
+	sel := parseFieldSelector(selector)
+	elems, err := queryElements(r.page, sel)
+	if err != nil {
+		return fmt.Errorf("查找遍历元素失败 '%s': %v", selector, err)
+	}
+	count := len(elems)
+	if limit > 0 && count > limit {
+		count = limit
+	}
+	log.Printf("🔁 找到 %d 个元素: %s", count, selector)
+
+	prev := r.item
+	defer func() { r.item = prev }()
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			if elems, err = queryElements(r.page, sel); err != nil || i >= len(elems) {
+				log.Printf("⚠️ 第 %d 个元素已不存在，结束遍历", i+1)
+				return nil
+			}
+		}
+		r.item = elems[i]
+		text, _ := r.item.Text()
+		if err := fn(i, strings.TrimSpace(text)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ==================== 模板 ====================
+
+// templateCacheSize 解析后模板的缓存上限，超出时清空重建
+const templateCacheSize = 512
+
+var (
+	templateCacheMu sync.Mutex
+	templateCache   = make(map[string]*template.Template) // 模板文本 -> 解析结果
+
+	offsetPattern = regexp.MustCompile(`^([+-]?\d+)\s*([dwmyh])$`)
+)
+
+// traceTemplateFuncs 参数模板中可用的辅助函数
+var traceTemplateFuncs = template.FuncMap{
+	// today 今天，2006-01-02
+	"today": func() string { return time.Now().Format("2006-01-02") },
+	// date 相对今天的日期："-7d"、"+1m"、"-1y"、"-2w"，可选第二个参数指定格式
+	"date": func(offset string, layout ...string) (string, error) {
+		t, err := applyDateOffset(time.Now(), offset)
+		if err != nil {
+			return "", err
+		}
+		if len(layout) > 0 && layout[0] != "" {
+			return t.Format(layout[0]), nil
+		}
+		return t.Format("2006-01-02"), nil
+	},
+	// now 当前时间，默认 2006-01-02 15:04:05
+	"now": func(layout ...string) string {
+		if len(layout) > 0 && layout[0] != "" {
+			return time.Now().Format(layout[0])
+		}
+		return time.Now().Format("2006-01-02 15:04:05")
+	},
+	"timestamp":     func() string { return strconv.FormatInt(time.Now().UnixMilli(), 10) },
+	"unix":          func() string { return strconv.FormatInt(time.Now().Unix(), 10) },
+	"urlencode":     url.QueryEscape,
+	"trim":          strings.TrimSpace,
+	"upper":         strings.ToUpper,
+	"lower":         strings.ToLower,
+	"replace":       func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"contains":      strings.Contains,
+	"default":       func(def, value string) string { return firstNonEmpty(value, def) },
+	"add":           func(a, b interface{}) int { return toInt(a) + toInt(b) },
+	"sub":           func(a, b interface{}) int { return toInt(a) - toInt(b) },
+	"normalizeDate": normalizeDate,
+}
+
+// applyDateOffset 按 "-7d" 形式的偏移量计算日期
+func applyDateOffset(t time.Time, offset string) (time.Time, error) {
+	offset = strings.TrimSpace(offset)
+	if offset == "" || offset == "0" {
+		return t, nil
+	}
+	m := offsetPattern.FindStringSubmatch(offset)
+	if m == nil {
+		return t, fmt.Errorf("日期偏移格式无效: %s（如 -7d、+1m）", offset)
+	}
+	n, _ := strconv.Atoi(m[1])
+	switch m[2] {
+	case "h":
+		return t.Add(time.Duration(n) * time.Hour), nil
+	case "d":
+		return t.AddDate(0, 0, n), nil
+	case "w":
+		return t.AddDate(0, 0, 7*n), nil
+	case "m":
+		return t.AddDate(0, n, 0), nil
+	default:
+		return t.AddDate(n, 0, 0), nil
+	}
+}
+
+// toInt 将模板中的字符串或数字参数转换为整数
+func toInt(v interface{}) int {
+	switch x := v.(type) {
+	case int:
+		return x
+	case string:
+		n, _ := strconv.Atoi(strings.TrimSpace(x))
+		return n
+	default:
+		n, _ := strconv.Atoi(fmt.Sprint(x))
+		return n
+	}
+}
+
+// renderTemplate 以 text/template 渲染参数模板，引用了不存在的参数时返回错误
+func renderTemplate(text string, params map[string]string) (string, error) {
+	tmpl, err := parseTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, params); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// parseTemplate 解析模板，结果按文本缓存
+func parseTemplate(text string) (*template.Template, error) {
+	templateCacheMu.Lock()
+	defer templateCacheMu.Unlock()
+	if tmpl, ok := templateCache[text]; ok {
+		return tmpl, nil
+	}
+	tmpl, err := template.New("param").Option("missingkey=error").Funcs(traceTemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(templateCache) >= templateCacheSize {
+		templateCache = make(map[string]*template.Template)
+	}
+	templateCache[text] = tmpl
+	return tmpl, nil
+}
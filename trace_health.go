@@ -0,0 +1,445 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+)
+
+// ==================== 轨迹健康检查 ====================
+//
+// 站点改版后轨迹通常不会报错，而是静默地提取到 0 条，任务仍显示“采集完成”。
+// 每次采集结束后根据列表提取探针和历史采集结果判断轨迹是否失效，结果按采集源记录在
+// trace_health 表中，状态变差或恢复时发出告警（日志，配置 ALERT_WEBHOOK 时同时推送）。
+
+// 轨迹健康状态
+const (
+	TraceHealthHealthy  = "healthy"
+	TraceHealthDegraded = "degraded" // 部分字段缺失等，数据仍可用
+	TraceHealthBroken   = "broken"   // 列表选择器失效、没有有效条目等，需要修复轨迹
+	TraceHealthUnknown  = "unknown"
+)
+
+// alertWebhook 告警推送地址，请求体兼容企业微信、钉钉机器人的文本消息
+var alertWebhook = getEnv("ALERT_WEBHOOK", "")
+
+// HealthIssue 检查发现的问题
+type HealthIssue struct {
+	Kind    string `json:"kind"` // search_failed / selector_missing / no_valid_items / no_rows / empty_field / selector_fallback
+	Message string `json:"message"`
+}
+
+// TraceHealth 采集源的轨迹健康状态
+type TraceHealth struct {
+	SourceID      int           `json:"source_id"`
+	SourceName    string        `json:"source_name,omitempty"`
+	Status        string        `json:"status"`
+	Issues        []HealthIssue `json:"issues"`
+	ListSelector  string        `json:"list_selector,omitempty"`
+	PageURL       string        `json:"page_url,omitempty"`
+	LastTaskID    string        `json:"last_task_id,omitempty"`
+	CheckedAt     string        `json:"checked_at,omitempty"`
+	LastHealthyAt string        `json:"last_healthy_at,omitempty"`
+	BrokenSince   string        `json:"broken_since,omitempty"`
+	AlertedAt     string        `json:"alerted_at,omitempty"`
+}
+
+// Summary 状态和问题的简短说明
+func (h *TraceHealth) Summary() string {
+	var msgs []string
+	for _, issue := range h.Issues {
+		msgs = append(msgs, issue.Message)
+	}
+	prefix := "轨迹异常"
+	if h.Status == TraceHealthBroken {
+		prefix = "轨迹可能已失效"
+	}
+	return prefix + ": " + strings.Join(msgs, "；")
+}
+
+// healthRank 状态的严重程度
+func healthRank(status string) int {
+	switch status {
+	case TraceHealthDegraded:
+		return 1
+	case TraceHealthBroken:
+		return 2
+	}
+	return 0
+}
+
+// ==================== 提取探针 ====================
+
+type extractProbeKey struct{}
+
+// extractProbe 记录一次采集中列表提取的结果和候选选择器的使用情况，用于判断选择器是否失效
+type extractProbe struct {
+	mu       sync.Mutex
+	runs     int             // 列表提取次数（不含翻页）
+	misses   int             // 列表选择器没有匹配到元素的次数
+	missFull int             // 其中页面没有“暂无数据”等空结果提示的次数（页面有内容但选择器失效）
+	rows     int             // 匹配到的行数
+	items    int             // 提取到的有效条目数
+	fields   map[string]bool // 列表步骤提取的字段
+	selector string          // 最近一次使用的列表选择器
+	pageURL  string          // 最近一次提取时的页面地址
+
+	fallbacks map[string]string // 未匹配的主选择器 -> 实际命中的候选选择器
+}
+
+// withExtractProbe 将提取探针附加到 context
+func withExtractProbe(ctx context.Context, probe *extractProbe) context.Context {
+	return context.WithValue(ctx, extractProbeKey{}, probe)
+}
+
+// extractProbeFrom 读取 context 中的提取探针，未设置时返回 nil
+func extractProbeFrom(ctx context.Context) *extractProbe {
+	probe, _ := ctx.Value(extractProbeKey{}).(*extractProbe)
+	return probe
+}
+
+// emptyResultMarkers 搜索结果为空时页面常见的提示
+var emptyResultMarkers = []string{"暂无", "没有找到", "未找到", "无数据", "无记录", "无结果", "没有数据", "没有记录", "没有相关", "共0条", "共 0 条", "No data", "No results", "no records"}
+
+// isEmptyResultText 页面文本是否包含空结果提示
+func isEmptyResultText(text string) bool {
+	for _, marker := range emptyResultMarkers {
+		if strings.Contains(text, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// pageText 页面的可见文本，读取失败时返回空字符串
+func pageText(page *rod.Page) string {
+	res, err := page.Eval(`() => document.body ? document.body.innerText : ''`)
+	if err != nil {
+		return ""
+	}
+	return res.Value.Str()
+}
+
+// record 记录一次列表提取，probe 为 nil 时忽略；没有匹配到行时用 pageText 读取页面文本判断是否为空结果
+func (p *extractProbe) record(step TraceStep, pageURL string, rows, items int, pageText func() string) {
+	if p == nil {
+		return
+	}
+	emptyPage := true
+	if rows == 0 && pageText != nil {
+		text := strings.TrimSpace(pageText())
+		emptyPage = text == "" || isEmptyResultText(text)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.runs++
+	if rows == 0 {
+		p.misses++
+		if !emptyPage {
+			p.missFull++
+		}
+	}
+	p.rows += rows
+	p.items += items
+	if p.fields == nil {
+		p.fields = map[string]bool{}
+	}
+	for field := range step.Fields {
+		p.fields[field] = true
+	}
+	p.selector = step.Selector
+	if step.XPath != "" {
+		p.selector = "xpath:" + step.XPath
+	}
+	p.pageURL = pageURL
+}
+
+// recordFallback 记录主选择器未匹配、改用候选选择器的情况，probe 为 nil 时忽略
+func (p *extractProbe) recordFallback(primary, matched string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fallbacks == nil {
+		p.fallbacks = map[string]string{}
+	}
+	p.fallbacks[primary] = matched
+}
+
+// collectStats 一次采集的汇总，用于健康检查
+type collectStats struct {
+	searches     int
+	searchErrors int
+	lastError    string
+	found        int
+	emptyTitle   int
+	emptyDate    int
+}
+
+// observe 统计列表条目的必填字段
+func (s *collectStats) observe(item map[string]string) {
+	if strings.TrimSpace(item["title"]) == "" {
+		s.emptyTitle++
+	}
+	if strings.TrimSpace(item["date"]) == "" {
+		s.emptyDate++
+	}
+}
+
+// ==================== 检查 ====================
+
+// checkTraceHealth 采集结束后检查轨迹健康状态并保存，状态变化时告警；没有执行搜索时返回 nil
+func checkTraceHealth(source *Source, taskID string, stats collectStats, probe *extractProbe) *TraceHealth {
+	if stats.searches == 0 {
+		return nil
+	}
+	health := evaluateTraceHealth(source.ID, taskID, stats, probe)
+	health.SourceName = source.Name
+	if health.Status != TraceHealthHealthy {
+		log.Printf("🩺 %s %s", source.Name, health.Summary())
+	}
+	if err := saveTraceHealth(health); err != nil {
+		log.Printf("⚠️ 保存轨迹健康状态失败: %v", err)
+	}
+	return health
+}
+
+// evaluateTraceHealth 根据本次采集结果判断轨迹状态。失效（需要修复轨迹）只在证据明确时判定：
+// 所有搜索失败；多个关键词的列表选择器全部没有匹配；页面有内容（没有空结果提示）但选择器没有匹配；
+// 匹配到行但没有有效条目。其余情况（个别关键词没有结果、本次没有发现条目等）视为异常
+func evaluateTraceHealth(sourceID int, taskID string, stats collectStats, probe *extractProbe) *TraceHealth {
+	health := &TraceHealth{SourceID: sourceID, LastTaskID: taskID, Status: TraceHealthHealthy, Issues: []HealthIssue{}}
+	add := func(status, kind, format string, args ...interface{}) {
+		health.Issues = append(health.Issues, HealthIssue{Kind: kind, Message: fmt.Sprintf(format, args...)})
+		if healthRank(status) > healthRank(health.Status) {
+			health.Status = status
+		}
+	}
+	hadData := sourceHadData(sourceID, taskID)
+
+	if stats.searchErrors == stats.searches {
+		add(TraceHealthBroken, "search_failed", "所有关键词搜索均失败（%s）", stats.lastError)
+	}
+
+	if probe != nil {
+		probe.mu.Lock()
+		defer probe.mu.Unlock()
+	}
+	if probe != nil && probe.runs > 0 {
+		health.ListSelector, health.PageURL = probe.selector, probe.pageURL
+		switch {
+		case probe.missFull > 0:
+			add(TraceHealthBroken, "selector_missing", "列表选择器 %s 没有匹配到任何元素，但页面没有空结果提示（%d/%d 次提取）", probe.selector, probe.missFull, probe.runs)
+		case probe.misses == probe.runs && probe.runs > 1:
+			add(TraceHealthBroken, "selector_missing", "列表选择器 %s 在全部 %d 次提取中都没有匹配到元素", probe.selector, probe.runs)
+		case probe.misses > 0:
+			add(TraceHealthDegraded, "selector_missing", "列表选择器 %s 有 %d/%d 次提取没有匹配到元素（页面提示无结果）", probe.selector, probe.misses, probe.runs)
+		case probe.items == 0:
+			add(TraceHealthBroken, "no_valid_items", "列表匹配到 %d 行但没有提取到有效条目，字段选择器可能已失效", probe.rows)
+		}
+	}
+	// 主选择器已失效但候选选择器仍可用：采集正常，提示更新轨迹
+	if probe != nil {
+		primaries := make([]string, 0, len(probe.fallbacks))
+		for primary := range probe.fallbacks {
+			primaries = append(primaries, primary)
+		}
+		sort.Strings(primaries)
+		for _, primary := range primaries {
+			add(TraceHealthDegraded, "selector_fallback", "主选择器 %s 未匹配，改用了候选选择器 %s，建议更新轨迹", primary, probe.fallbacks[primary])
+		}
+	}
+	if stats.found == 0 && hadData && health.Status == TraceHealthHealthy {
+		add(TraceHealthDegraded, "no_rows", "本次没有发现任何条目，历史采集有数据")
+	}
+
+	// 只检查列表步骤中配置了的字段
+	if stats.found > 0 && probe != nil && probe.runs > 0 {
+		if probe.fields["title"] && stats.emptyTitle*2 >= stats.found {
+			add(TraceHealthDegraded, "empty_field", "%d/%d 条缺少标题", stats.emptyTitle, stats.found)
+		}
+		if probe.fields["date"] && stats.emptyDate*2 >= stats.found {
+			add(TraceHealthDegraded, "empty_field", "%d/%d 条缺少日期", stats.emptyDate, stats.found)
+		}
+	}
+	return health
+}
+
+// sourceHadData 最近 5 次完成的采集任务中有发现条目的，或已有入库数据
+func sourceHadData(sourceID int, excludeTaskID string) bool {
+	var n int
+	db.QueryRow(`SELECT COUNT(*) FROM (SELECT found FROM collect_tasks WHERE source_id = ? AND id != ? AND status = 'completed'
+		ORDER BY created_at DESC LIMIT 5) WHERE found > 0`, sourceID, excludeTaskID).Scan(&n)
+	if n > 0 {
+		return true
+	}
+	db.QueryRow("SELECT COUNT(*) FROM tenders WHERE source_id = ?", sourceID).Scan(&n)
+	return n > 0
+}
+
+// ==================== 存储 ====================
+
+// saveTraceHealth 保存检查结果：维护最近健康时间和失效起始时间，状态变差或恢复时告警
+func saveTraceHealth(health *TraceHealth) error {
+	now := time.Now().Format("2006-01-02 15:04:05")
+	health.CheckedAt = now
+
+	prev, err := getTraceHealth(health.SourceID)
+	if err != nil {
+		return err
+	}
+	health.LastHealthyAt, health.BrokenSince, health.AlertedAt = prev.LastHealthyAt, prev.BrokenSince, prev.AlertedAt
+	// 搜索失败时没有执行列表提取，沿用上次的列表选择器和页面
+	if health.ListSelector == "" {
+		health.ListSelector, health.PageURL = prev.ListSelector, prev.PageURL
+	}
+	switch health.Status {
+	case TraceHealthHealthy:
+		health.LastHealthyAt, health.BrokenSince = now, ""
+	case TraceHealthBroken:
+		if health.BrokenSince == "" {
+			health.BrokenSince = now
+		}
+	default:
+		health.BrokenSince = ""
+	}
+
+	worse := healthRank(health.Status) > healthRank(prev.Status)
+	recovered := health.Status == TraceHealthHealthy && healthRank(prev.Status) > 0
+	if worse || recovered {
+		health.AlertedAt = now
+		sendHealthAlert(health, prev.Status)
+	}
+
+	issues, _ := json.Marshal(health.Issues)
+	_, err = db.Exec(`INSERT OR REPLACE INTO trace_health (source_id, status, issues, list_selector, page_url, last_task_id,
+		checked_at, last_healthy_at, broken_since, alerted_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		health.SourceID, health.Status, string(issues), health.ListSelector, health.PageURL, health.LastTaskID,
+		health.CheckedAt, health.LastHealthyAt, health.BrokenSince, health.AlertedAt)
+	return err
+}
+
+const traceHealthColumns = `h.source_id, COALESCE(s.name, ''), h.status, COALESCE(h.issues, ''), COALESCE(h.list_selector, ''),
+	COALESCE(h.page_url, ''), COALESCE(h.last_task_id, ''), COALESCE(h.checked_at, ''), COALESCE(h.last_healthy_at, ''),
+	COALESCE(h.broken_since, ''), COALESCE(h.alerted_at, '')`
+
+func scanTraceHealth(row interface{ Scan(...interface{}) error }) (*TraceHealth, error) {
+	var h TraceHealth
+	var issues string
+	if err := row.Scan(&h.SourceID, &h.SourceName, &h.Status, &issues, &h.ListSelector, &h.PageURL, &h.LastTaskID,
+		&h.CheckedAt, &h.LastHealthyAt, &h.BrokenSince, &h.AlertedAt); err != nil {
+		return nil, err
+	}
+	h.Issues = []HealthIssue{}
+	json.Unmarshal([]byte(issues), &h.Issues)
+	return &h, nil
+}
+
+// getTraceHealth 读取采集源的健康状态，尚未检查过时状态为 unknown
+func getTraceHealth(sourceID int) (*TraceHealth, error) {
+	h, err := scanTraceHealth(db.QueryRow(`SELECT `+traceHealthColumns+`
+		FROM trace_health h LEFT JOIN sources s ON s.id = h.source_id WHERE h.source_id = ?`, sourceID))
+	if err == sql.ErrNoRows {
+		return &TraceHealth{SourceID: sourceID, Status: TraceHealthUnknown, Issues: []HealthIssue{}}, nil
+	}
+	return h, err
+}
+
+// listTraceHealth 所有检查过的采集源的健康状态，失效的在前
+func listTraceHealth() ([]TraceHealth, error) {
+	rows, err := db.Query(`SELECT ` + traceHealthColumns + `
+		FROM trace_health h LEFT JOIN sources s ON s.id = h.source_id
+		ORDER BY CASE h.status WHEN 'broken' THEN 0 WHEN 'degraded' THEN 1 ELSE 2 END, h.source_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []TraceHealth{}
+	for rows.Next() {
+		if h, err := scanTraceHealth(rows); err == nil {
+			list = append(list, *h)
+		}
+	}
+	return list, nil
+}
+
+// ==================== 告警 ====================
+
+// sendHealthAlert 记录告警日志，配置了 ALERT_WEBHOOK 时推送文本消息
+func sendHealthAlert(health *TraceHealth, prevStatus string) {
+	var text string
+	if health.Status == TraceHealthHealthy {
+		text = fmt.Sprintf("✅ 采集源 %s 的轨迹已恢复正常（之前为 %s）", health.SourceName, prevStatus)
+	} else {
+		text = fmt.Sprintf("🚨 采集源 %s %s", health.SourceName, health.Summary())
+		if health.PageURL != "" {
+			text += "\n页面: " + health.PageURL
+		}
+	}
+	log.Println(text)
+	if alertWebhook == "" {
+		return
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"msgtype":   "text",
+		"text":      map[string]string{"content": text},
+		"event":     "trace_health",
+		"source_id": health.SourceID,
+		"status":    health.Status,
+		"issues":    health.Issues,
+	})
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(alertWebhook, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("⚠️ 告警推送失败: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("⚠️ 告警推送失败: HTTP %d", resp.StatusCode)
+	}
+}
+
+// ==================== 接口 ====================
+
+// handleTraceHealth 轨迹健康状态：GET /api/trace-health[?source_id=1]
+func handleTraceHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if idStr := r.URL.Query().Get("source_id"); idStr != "" {
+		sourceID, err := parseInt(idStr)
+		if err != nil {
+			http.Error(w, "source_id 无效", http.StatusBadRequest)
+			return
+		}
+		health, err := getTraceHealth(sourceID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": health})
+		return
+	}
+
+	list, err := listTraceHealth()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": list})
+}
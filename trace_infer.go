@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"tender-monitor/traceconv"
+)
+
+// ==================== 列表结构推断 ====================
+//
+// 转换录制时只能从一次点击的选择器猜测列表行和字段（见 traceconv 的 inferListSelector），
+// 猜不出时退回 td:nth-child(1) span。这里直接分析加载好的结果页：找出重复出现的同类兄弟元素作为候选列表，
+// 按内容特征（日期、公告类标题、链接、地区、金额）给每列分类，生成带置信度的 extract 步骤。
+
+// inferMaxCandidates 返回的候选列表数
+const inferMaxCandidates = 3
+
+// inferScriptJS 推断页面中的列表结构
+const inferScriptJS = `function(maxCandidates) {` + domSelectorHelpersJS + `
+	const datePattern = /(\d{4})\s*[-/.年]\s*(\d{1,2})\s*[-/.月]\s*(\d{1,2})/;
+	const amountPattern = /\d[\d,]*(\.\d+)?\s*(亿元|万元|元)|[¥￥]\s*\d/;
+	const regionPattern = /^[一-龥]{2,10}(省|市|区|县|州|旗|盟)$/;
+	const titleWords = /(公告|招标|采购|中标|成交|询价|磋商|谈判|项目|结果|变更|更正|公示|竞价|比选|遴选)/;
+	const textOf = (e) => (e.textContent || '').replace(/\s+/g, ' ').trim();
+	const visible = (e) => { const r = e.getBoundingClientRect(); return r.width > 0 && r.height > 0; };
+	const signature = (e) => tagOf(e) + stableClasses(e).sort().map((c) => '.' + c).join('');
+	const ratio = (n, total) => total ? n / total : 0;
+	const round = (x) => Math.round(Math.max(0, Math.min(1, x)) * 100);
+
+	// 候选列表：同一父元素下至少 3 个签名（标签 + 稳定类名）相同的可见子元素，导航、页眉页脚、下拉框除外
+	const groups = [];
+	for (const parent of document.body.querySelectorAll('*')) {
+		if (parent.children.length < 3 || /^(select|optgroup|head|script|style|svg)$/i.test(parent.tagName)) continue;
+		if (parent.closest('nav, header, footer, select, [role=navigation], [role=menu], [role=menubar]')) continue;
+		const bySig = new Map();
+		for (const child of parent.children) {
+			if (!visible(child)) continue;
+			const sig = signature(child);
+			if (!bySig.has(sig)) bySig.set(sig, []);
+			bySig.get(sig).push(child);
+		}
+		bySig.forEach((rows) => { if (rows.length >= 3) groups.push({ container: parent, rows: rows }); });
+	}
+
+	// 列表得分：含链接、日期、公告类标题的行的比例，行文本长度适中，行数少于 5 时按比例降低
+	const listScore = (rows) => {
+		let links = 0, dates = 0, titled = 0, len = 0;
+		for (const r of rows) {
+			const t = textOf(r);
+			len += t.length;
+			if (r.querySelector('a[href]')) links++;
+			if (datePattern.test(t)) dates++;
+			if (titleWords.test(t)) titled++;
+		}
+		const n = rows.length, avg = len / n;
+		let s = 0.3 * ratio(links, n) + 0.3 * ratio(dates, n) + 0.2 * ratio(titled, n);
+		s += avg >= 15 && avg <= 400 ? 0.2 : (avg >= 8 ? 0.1 : 0);
+		return s * Math.min(1, n / 5);
+	};
+
+	// 列分类：按字段在行内的相对路径汇总各行的文本
+	const inferFields = (rows) => {
+		const cols = new Map();
+		for (const row of rows) {
+			const seen = new Set();
+			for (const el of row.querySelectorAll('*')) {
+				const own = Array.from(el.childNodes).some((n) => n.nodeType === 3 && n.textContent.trim());
+				if (!own || /^(script|style|option)$/i.test(el.tagName)) continue;
+				const path = relPath(row, el);
+				if (!path || seen.has(path)) continue;
+				seen.add(path);
+				if (!cols.has(path)) cols.set(path, { path: path, texts: [], titleAttr: 0, anchors: [] });
+				const c = cols.get(path);
+				c.texts.push(textOf(el));
+				if (el.getAttribute('title')) c.titleAttr++;
+				let a = el.closest('a[href]');
+				if (!a || !row.contains(a)) a = el.querySelector('a[href]');
+				const href = a ? a.getAttribute('href') : '';
+				c.anchors.push(a && a !== row && !/^(#|javascript:)/i.test(href) ? relPath(row, a) : '');
+			}
+		}
+
+		const scored = [];
+		cols.forEach((c) => {
+			const n = c.texts.length, presence = ratio(n, rows.length);
+			if (presence < 0.5) return;
+			const avg = c.texts.reduce((s, t) => s + t.length, 0) / n;
+			const dateFrac = ratio(c.texts.filter((t) => datePattern.test(t)).length, n);
+			scored.push({
+				col: c,
+				date: presence * dateFrac * (avg <= 25 ? 1 : 0.7),
+				amount: presence * ratio(c.texts.filter((t) => amountPattern.test(t)).length, n),
+				region: presence * ratio(c.texts.filter((t) => regionPattern.test(t.replace(/[\[\]【】()（）]/g, ''))).length, n),
+				title: avg < 6 || dateFrac > 0.5 ? 0 : presence * (0.4 * Math.min(1, avg / 15) +
+					0.3 * ratio(c.texts.filter((t) => titleWords.test(t)).length, n) +
+					0.3 * ratio(new Set(c.texts).size, n)),
+			});
+		});
+
+		const fields = {};
+		const used = new Set();
+		const pick = (kind, min) => {
+			let best = null;
+			for (const s of scored) if (!used.has(s.col.path) && s[kind] >= min && (!best || s[kind] > best[kind])) best = s;
+			if (best) used.add(best.col.path);
+			return best;
+		};
+		const samples = (c) => c.texts.slice(0, 3);
+
+		const title = pick('title', 0.3);
+		if (title) {
+			const c = title.col;
+			// 标题被截断时完整标题通常在 title 属性中
+			const truncated = ratio(c.texts.filter((t) => /(\.{2,}|…)$/.test(t)).length, c.texts.length) >= 0.3;
+			const useAttr = truncated && ratio(c.titleAttr, c.texts.length) >= 0.8;
+			fields.title = { selector: c.path + (useAttr ? '@title' : ''), confidence: round(title.title), samples: samples(c) };
+
+			const link = vote(c.anchors);
+			if (link.selector) {
+				fields.url = { selector: link.selector + '@href | abs', confidence: round(ratio(link.votes, rows.length)), samples: [] };
+			} else {
+				// 没有链接（Vue 等单页应用），点击标题获取详情地址
+				fields.url = { selector: '@click:' + c.path, confidence: 40, samples: [] };
+			}
+		}
+		for (const kind of ['date', 'region', 'amount']) {
+			const s = pick(kind, 0.5);
+			if (s) fields[kind] = { selector: s.col.path + (kind === 'date' && s.col.texts.some((t) => t.replace(datePattern, '').trim()) ? ' | date' : ''), confidence: round(s[kind]), samples: samples(s.col) };
+		}
+		return fields;
+	};
+
+	groups.forEach((g) => { g.score = listScore(g.rows); });
+	groups.sort((a, b) => b.score - a.score);
+
+	const candidates = [];
+	for (const g of groups) {
+		if (candidates.length >= maxCandidates || g.score < 0.2) break;
+		// 嵌套在已选列表中（如表格行内的单元格）或包含已选列表的结构不再重复推荐
+		if (candidates.some((c) => c.container.contains(g.container) || g.container.contains(c.container))) continue;
+		const fields = inferFields(g.rows);
+		if (!fields.title) continue;
+		const selector = rowSelector(g.container, g.rows);
+		const urlConf = fields.url ? fields.url.confidence / 100 : 0;
+		candidates.push({
+			container: g.container,
+			list_selector: selector,
+			row_count: countOf(selector),
+			confidence: round(0.5 * g.score + 0.3 * fields.title.confidence / 100 + 0.2 * urlConf),
+			fields: fields,
+		});
+	}
+
+	const next = Array.from(document.querySelectorAll('a, button, li, span')).find((e) =>
+		/^(下一页|下页|next|>|›|»)$/i.test(textOf(e)) && visible(e));
+	return {
+		candidates: candidates.map((c) => { delete c.container; return c; }),
+		next_button: next ? tagOf(next) + ":contains('" + textOf(next) + "')" : '',
+	};
+}`
+
+// InferredField 推断出的字段
+type InferredField struct {
+	Selector   string   `json:"selector"`
+	Confidence int      `json:"confidence"` // 0-100
+	Samples    []string `json:"samples,omitempty"`
+}
+
+// ListInference 一个候选列表结构
+type ListInference struct {
+	ListSelector string                   `json:"list_selector"`
+	RowCount     int                      `json:"row_count"`
+	Confidence   int                      `json:"confidence"` // 0-100
+	Fields       map[string]InferredField `json:"fields"`
+	Step         TraceStep                `json:"step"` // 可直接放入轨迹的 extract 步骤
+}
+
+// InferResult 推断结果，候选按置信度从高到低排列
+type InferResult struct {
+	PageURL        string              `json:"page_url"`
+	NextButton     string              `json:"next_button,omitempty"`
+	Candidates     []ListInference     `json:"candidates"`
+	Preview        []map[string]string `json:"preview"` // 最佳候选在当前页面的试提取结果
+	Trace          *TraceFile          `json:"trace,omitempty"`
+	AppliedVersion int                 `json:"applied_version,omitempty"`
+}
+
+// inferRequest 推断请求
+type inferRequest struct {
+	URL      string `json:"url"`       // 直接打开的结果页
+	SourceID int    `json:"source_id"` // 采集源已有列表轨迹时执行到提取步骤之前，没有时打开 url
+	Keyword  string `json:"keyword"`
+	Apply    bool   `json:"apply"` // 用最佳候选替换（或追加）列表轨迹的提取步骤并保存为新版本
+	Author   string `json:"author"`
+}
+
+// inferListStructure 打开结果页并推断列表结构
+func inferListStructure(ctx context.Context, req inferRequest) (*InferResult, error) {
+	var source *Source
+	var trace *TraceFile
+	if req.SourceID > 0 {
+		var err error
+		if source, err = getSourceByID(req.SourceID); err != nil {
+			return nil, fmt.Errorf("获取采集源失败: %v", err)
+		}
+		trace, _ = loadSourceTrace(source, "list")
+	}
+	if trace == nil {
+		if req.URL == "" {
+			return nil, fmt.Errorf("缺少 url（采集源没有列表轨迹时需要指定结果页地址）")
+		}
+		trace = &TraceFile{Name: "列表推断", Type: "list", URL: req.URL, Steps: []TraceStep{{Action: "navigate", URL: req.URL}}}
+		if source != nil {
+			trace.Name = source.Name + "列表"
+		}
+	}
+
+	log.Printf("🔍 推断列表结构: %s", trace.URL)
+	run, done, err := openListPage(ctx, source, trace, req.Keyword)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	// 等待异步渲染的列表，与 extractList 一致
+	time.Sleep(2 * time.Second)
+
+	res, err := run.page.Eval(inferScriptJS, inferMaxCandidates)
+	if err != nil {
+		return nil, fmt.Errorf("分析页面失败: %v", err)
+	}
+	var raw struct {
+		Candidates []ListInference `json:"candidates"`
+		NextButton string          `json:"next_button"`
+	}
+	if err := res.Value.Unmarshal(&raw); err != nil {
+		return nil, fmt.Errorf("解析推断结果失败: %v", err)
+	}
+
+	result := &InferResult{
+		PageURL:    run.page.MustInfo().URL,
+		NextButton: raw.NextButton,
+		Candidates: raw.Candidates,
+		Preview:    []map[string]string{},
+	}
+	idx := listExtractIndex(trace)
+	var old *TraceStep
+	if idx < len(trace.Steps) {
+		old = &trace.Steps[idx]
+	}
+	for i := range result.Candidates {
+		c := &result.Candidates[i]
+		c.Step = TraceStep{Action: "extract", Type: "list", Selector: c.ListSelector, Fields: map[string]string{}}
+		for field, f := range c.Fields {
+			c.Step.Fields[field] = f.Selector
+		}
+		// 保留原提取步骤的条数上限和翻页设置
+		if old != nil {
+			c.Step.MaxItems, c.Step.Pagination = old.MaxItems, old.Pagination
+		}
+		if c.Step.Pagination == nil && raw.NextButton != "" {
+			c.Step.Pagination = &traceconv.Pagination{NextButton: raw.NextButton}
+		}
+	}
+	sort.SliceStable(result.Candidates, func(i, j int) bool {
+		return result.Candidates[i].Confidence > result.Candidates[j].Confidence
+	})
+	if len(result.Candidates) == 0 {
+		log.Printf("🔍 没有找到重复的列表结构")
+		return result, nil
+	}
+
+	best := result.Candidates[0].Step
+	best.Pagination = nil
+	if preview := extractList(run.page, best, nil); len(preview) > 0 {
+		if len(preview) > 5 {
+			preview = preview[:5]
+		}
+		result.Preview = preview
+	}
+	result.Trace = replaceTraceStep(trace, idx, result.Candidates[0].Step)
+	log.Printf("🔍 推断出 %d 个候选列表，最佳: %s（置信度 %d）", len(result.Candidates), best.Selector, result.Candidates[0].Confidence)
+	return result, nil
+}
+
+// handleTraceInfer 列表结构推断：POST /api/traces/infer
+// {"url": "结果页地址"} 或 {"source_id": 1, "keyword": "电梯"}，加 "apply": true 保存为采集源列表轨迹的新版本
+func handleTraceInfer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req inferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	if req.Apply && req.SourceID <= 0 {
+		http.Error(w, "保存推断结果需要 source_id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Minute)
+	defer cancel()
+	result, err := inferListStructure(ctx, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Apply {
+		if result.Trace == nil {
+			http.Error(w, "页面中没有找到列表结构", http.StatusUnprocessableEntity)
+			return
+		}
+		comment := fmt.Sprintf("列表推断: %s（置信度 %d）", result.Candidates[0].ListSelector, result.Candidates[0].Confidence)
+		version, err := saveListTrace(req.SourceID, result.Trace, firstNonEmpty(req.Author, "列表推断"), comment)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.AppliedVersion = version
+		log.Printf("🔍 推断结果已保存为列表轨迹版本 %d", version)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": result})
+}
@@ -0,0 +1,715 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"tender-monitor/traceconv"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/ysmood/gson"
+)
+
+// ==================== 在线录制 ====================
+//
+// 在服务器管理的浏览器中打开页面，画面通过 screencast 推送给界面，界面把点击、输入、按键转发回来。
+// 页面中注入的脚本按 Chrome DevTools Recorder 的格式记录操作（候选选择器、输入值、按键、页面跳转），
+// 保存时与上传的 Chrome 录制走同一套转换规则（关键词输入、验证码、列表行点击、翻页按钮识别），
+// 结果保存为采集源的草稿轨迹。
+
+const (
+	recordingIdle         = 15 * time.Minute // 空闲超过该时间的录制自动关闭
+	maxRecordingSessions  = 2                // 同时进行的录制上限
+	recordingNavWindow    = 5 * time.Second  // 操作后多久内发生的页面跳转算作该操作导致的
+	recordingFrameQuality = 60
+	recordingFrameWidth   = 1280
+	recordingBinding      = "__tmRecord"
+)
+
+var (
+	recordings        = make(map[string]*recordingSession)
+	recordingsMu      sync.Mutex
+	recordingsJanitor sync.Once
+)
+
+// recorderScriptJS 注入页面的录制脚本，每个文档（含同源 iframe）执行一次
+const recorderScriptJS = `function() {
+	if (window.__tmRecorderInstalled) return;
+	window.__tmRecorderInstalled = true;` + domSelectorHelpersJS + `
+	const isField = (el) => /^(input|textarea|select)$/i.test(el.tagName);
+	const quote = (v) => '"' + v.replace(/\\/g, '\\\\').replace(/"/g, '\\"') + '"';
+	const xpathOf = (el) => {
+		const parts = [];
+		for (let cur = el; cur && cur.nodeType === 1; cur = cur.parentElement) {
+			const id = stableID(cur);
+			if (id) { parts.unshift('//*[@id=' + quote(id) + ']'); return parts.join('/'); }
+			const same = cur.parentElement ? Array.from(cur.parentElement.children).filter((c) => c.tagName === cur.tagName) : [cur];
+			parts.unshift(tagOf(cur) + (same.length > 1 ? '[' + (same.indexOf(cur) + 1) + ']' : ''));
+		}
+		return '/' + parts.join('/');
+	};
+	const labelOf = (el) => (el.getAttribute('aria-label') || el.getAttribute('placeholder') || el.getAttribute('title') ||
+		(isField(el) ? '' : (el.innerText || '').trim())).replace(/\s+/g, ' ');
+	// selectorsOf 与 Chrome 录制一致的候选选择器分组：ID 或属性选择器、CSS 路径、XPath、aria、文本
+	const selectorsOf = (el) => {
+		const groups = [];
+		const tag = tagOf(el);
+		const id = stableID(el);
+		if (id && isUnique('#' + esc(id), el)) groups.push(['#' + esc(id)]);
+		for (const attr of ['name', 'placeholder', 'title', 'aria-label']) {
+			const v = el.getAttribute(attr);
+			const sel = v && v.length <= 40 ? tag + '[' + attr + '=' + quote(v) + ']' : '';
+			if (sel && isUnique(sel, el)) groups.push([sel]);
+		}
+		// 表单控件有稳定的属性选择器时不再给出路径，列表行、链接的路径用于识别列表结构
+		if (!groups.length || !isField(el)) groups.push([cssPath(el)]);
+		groups.push(['xpath/' + xpathOf(el)]);
+		const label = labelOf(el);
+		if (label && label.length <= 30) {
+			const role = tag === 'a' ? 'link' : (tag === 'button' ? 'button' : '');
+			groups.push(['aria/' + label + (role ? '[role="' + role + '"]' : '')]);
+			if (!isField(el)) groups.push(['text/' + label]);
+		}
+		return groups;
+	};
+	const framePath = () => {
+		const path = [];
+		try {
+			for (let w = window; w !== w.top; w = w.parent) {
+				// 与 Chrome 录制一致，记录在父窗口 window.frames 中的下标
+				const siblings = w.parent.frames;
+				let idx = -1;
+				for (let i = 0; i < siblings.length; i++) {
+					if (siblings[i] === w) { idx = i; break; }
+				}
+				if (idx < 0) return [];
+				path.unshift(idx);
+			}
+		} catch (e) { return []; }
+		return path;
+	};
+	const send = (step) => {
+		const frame = framePath();
+		if (frame.length) step.frame = frame;
+		try { window.` + recordingBinding + `(step); } catch (e) {}
+	};
+	// 点击的目标取最近的可交互祖先，避免记录到按钮里的图标、文字
+	const clickable = (el) => el.closest('a,button,input,select,textarea,label,[role=button],[role=link],[onclick]') || el;
+	document.addEventListener('click', (e) => {
+		if (!(e.target instanceof Element)) return;
+		const el = clickable(e.target);
+		const rect = el.getBoundingClientRect();
+		send({ type: 'click', selectors: selectorsOf(el), offsetX: e.clientX - rect.left, offsetY: e.clientY - rect.top });
+	}, true);
+	const onValue = (e) => {
+		const el = e.target;
+		if (!(el instanceof Element) || !isField(el) || /^(checkbox|radio|submit|button)$/i.test(el.type || '')) return;
+		// 密码不落盘，登录轨迹执行时由凭据填入
+		const value = el.type === 'password' ? '{{.Password}}' : el.value;
+		send({ type: 'change', selectors: selectorsOf(el), value: value });
+	};
+	document.addEventListener('input', onValue, true);
+	document.addEventListener('change', onValue, true);
+	document.addEventListener('keydown', (e) => {
+		if (e.key && e.key.length > 1 && !/^(Shift|Control|Alt|Meta|CapsLock|Process|Unidentified)$/.test(e.key)) {
+			send({ type: 'keyDown', key: e.key });
+		}
+	}, true);
+}`
+
+// recordingFrame 一帧画面，尺寸为页面视口的 CSS 像素，界面按此换算点击坐标
+type recordingFrame struct {
+	Image   string  `json:"image"` // JPEG，base64
+	Width   float64 `json:"width"`
+	Height  float64 `json:"height"`
+	ScrollY float64 `json:"scroll_y"`
+}
+
+// recordingEvent 推送给界面的事件：frame、step、navigated、closed
+type recordingEvent struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// recordingInput 界面转发的操作，坐标为视口的 CSS 像素
+type recordingInput struct {
+	Type   string  `json:"type"` // click / type / key / scroll / navigate / close_tab
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Text   string  `json:"text"`
+	Key    string  `json:"key"`
+	DeltaY float64 `json:"delta_y"`
+	URL    string  `json:"url"`
+}
+
+// recordingRequest 开始录制的参数
+type recordingRequest struct {
+	SourceID int    `json:"source_id"`
+	URL      string `json:"url"`     // 起始页面，默认为采集源的 base_url
+	Type     string `json:"type"`    // 轨迹类型：list（默认）/ detail / login
+	Name     string `json:"name"`    // 轨迹名称，默认为采集源名称
+	Keyword  string `json:"keyword"` // 录制时搜索的关键词，输入该值的输入框会替换为 {{.Keyword}}
+}
+
+// recordingSession 一次录制
+type recordingSession struct {
+	mu        sync.Mutex // 保护页面操作
+	id        string
+	source    *Source
+	traceType string
+	name      string
+	keyword   string
+	ctx       context.Context
+	cancel    context.CancelFunc
+	run       *browserRun
+	done      func()
+	target    string   // 当前标签页：main 或弹出标签页的 URL
+	detach    []func() // 停止各标签页的录制
+	lastUsed  time.Time
+
+	eventsMu  sync.Mutex // 保护以下录制结果和订阅者
+	steps     []ChromeDevToolsStep
+	stepAt    time.Time
+	expectNav bool // 导航命令发出后，随后的页面跳转不再归到上一个操作
+	frame     *recordingFrame
+	subs      map[chan recordingEvent]struct{}
+}
+
+// startRecording 打开浏览器和起始页面，开始录制
+func startRecording(req recordingRequest) (*recordingSession, error) {
+	source, err := getSourceByID(req.SourceID)
+	if err != nil {
+		return nil, fmt.Errorf("获取采集源失败: %v", err)
+	}
+	startURL := firstNonEmpty(req.URL, source.BaseURL)
+	if startURL == "" {
+		return nil, fmt.Errorf("缺少 url（采集源没有配置 base_url）")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &recordingSession{
+		id:        fmt.Sprintf("rec_%d_%d", source.ID, time.Now().UnixNano()),
+		source:    source,
+		traceType: firstNonEmpty(req.Type, "list"),
+		name:      firstNonEmpty(req.Name, source.Name),
+		keyword:   req.Keyword,
+		ctx:       ctx,
+		cancel:    cancel,
+		target:    "main",
+		lastUsed:  time.Now(),
+		subs:      make(map[chan recordingEvent]struct{}),
+	}
+	run, done, err := openTracePage(ctx, source, &TraceFile{Name: s.name, Type: s.traceType}, req.Keyword)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	s.run, s.done = run, done
+
+	if err := s.attach(run.root, "main"); err != nil {
+		s.close()
+		return nil, err
+	}
+	if err := s.navigate(startURL); err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// attach 在标签页中注入录制脚本，开始推送画面
+func (s *recordingSession) attach(page *rod.Page, target string) error {
+	stopExpose, err := page.Expose(recordingBinding, func(req gson.JSON) (interface{}, error) {
+		var step ChromeDevToolsStep
+		if err := req.Unmarshal(&step); err != nil {
+			return nil, err
+		}
+		step.Target = target
+		s.addStep(step)
+		return nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("注入录制脚本失败: %v", err)
+	}
+	removeScript, err := page.EvalOnNewDocument("(" + recorderScriptJS + ")()")
+	if err != nil {
+		stopExpose()
+		return fmt.Errorf("注入录制脚本失败: %v", err)
+	}
+	if _, err := page.Eval(recorderScriptJS); err != nil {
+		log.Printf("⚠️ 当前页面注入录制脚本失败: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	events := page.Context(ctx)
+	go events.EachEvent(func(e *proto.PageScreencastFrame) {
+		proto.PageScreencastFrameAck{SessionID: e.SessionID}.Call(events)
+		if e.Metadata != nil {
+			s.publishFrame(&recordingFrame{Image: base64.StdEncoding.EncodeToString(e.Data),
+				Width: e.Metadata.DeviceWidth, Height: e.Metadata.DeviceHeight, ScrollY: e.Metadata.ScrollOffsetY})
+		}
+	}, func(e *proto.PageFrameNavigated) {
+		if e.Frame != nil && e.Frame.ParentID == "" {
+			s.navigated(target, e.Frame.URL)
+		}
+	})()
+
+	quality, width := recordingFrameQuality, recordingFrameWidth
+	if err := (proto.PageStartScreencast{Format: proto.PageStartScreencastFormatJpeg, Quality: &quality, MaxWidth: &width}).Call(page); err != nil {
+		log.Printf("⚠️ 开始推送画面失败: %v", err)
+	}
+	s.detach = append(s.detach, func() {
+		proto.PageStopScreencast{}.Call(page)
+		removeScript()
+		stopExpose()
+		cancel()
+	})
+	return nil
+}
+
+// addStep 记录一个操作，同一输入框连续的输入只保留最后的值
+func (s *recordingSession) addStep(step ChromeDevToolsStep) {
+	s.eventsMu.Lock()
+	if n := len(s.steps); step.Type == "change" && n > 0 && s.steps[n-1].Type == "change" &&
+		s.steps[n-1].Target == step.Target && sameSelector(s.steps[n-1].Selectors, step.Selectors) {
+		s.steps[n-1].Value = step.Value
+	} else {
+		s.steps = append(s.steps, step)
+	}
+	s.stepAt = time.Now()
+	count := len(s.steps)
+	s.eventsMu.Unlock()
+
+	s.publish(recordingEvent{Type: "step", Data: map[string]interface{}{"index": count, "step": step}})
+}
+
+func sameSelector(a, b [][]string) bool {
+	return len(a) > 0 && len(b) > 0 && len(a[0]) > 0 && len(b[0]) > 0 && a[0][0] == b[0][0]
+}
+
+// navigated 页面跳转：紧跟在操作之后的跳转记为该操作触发的导航（列表行点击据此识别进入详情页）
+func (s *recordingSession) navigated(target, url string) {
+	s.eventsMu.Lock()
+	if s.expectNav {
+		s.expectNav = false
+	} else if n := len(s.steps); n > 0 && s.steps[n-1].Target == target && time.Since(s.stepAt) < recordingNavWindow {
+		last := &s.steps[n-1]
+		if last.Type != "navigate" && len(last.AssertedEvents) == 0 {
+			last.AssertedEvents = []traceconv.AssertedEvent{{Type: "navigation", URL: url}}
+		}
+	}
+	s.eventsMu.Unlock()
+
+	s.publish(recordingEvent{Type: "navigated", Data: map[string]string{"url": url, "target": target}})
+}
+
+// navigate 打开地址并记录为 navigate 步骤
+func (s *recordingSession) navigate(url string) error {
+	s.eventsMu.Lock()
+	s.expectNav = true
+	s.eventsMu.Unlock()
+	s.addStep(ChromeDevToolsStep{Type: "navigate", URL: url, Target: s.target})
+	return s.run.runStep(TraceStep{Action: "navigate", URL: url})
+}
+
+// input 在当前标签页执行界面转发的操作，页面中的录制脚本会记录由此产生的事件
+func (s *recordingSession) input(in recordingInput) error {
+	page := s.run.tab
+	switch in.Type {
+	case "click":
+		if err := page.Mouse.MoveTo(proto.Point{X: in.X, Y: in.Y}); err != nil {
+			return fmt.Errorf("移动鼠标失败: %v", err)
+		}
+		if err := page.Mouse.Click(proto.InputMouseButtonLeft, 1); err != nil {
+			return fmt.Errorf("点击失败: %v", err)
+		}
+		s.followPopup()
+	case "type":
+		if err := page.InsertText(in.Text); err != nil {
+			return fmt.Errorf("输入失败: %v", err)
+		}
+	case "key":
+		key, ok := pressKeys[strings.ToLower(strings.TrimSpace(in.Key))]
+		if !ok {
+			return fmt.Errorf("不支持的按键: %s", in.Key)
+		}
+		if err := page.Keyboard.Type(key); err != nil {
+			return fmt.Errorf("按键失败: %v", err)
+		}
+		s.followPopup()
+	case "scroll":
+		if err := page.Mouse.MoveTo(proto.Point{X: in.X, Y: in.Y}); err != nil {
+			return fmt.Errorf("移动鼠标失败: %v", err)
+		}
+		if err := page.Mouse.Scroll(0, in.DeltaY, 1); err != nil {
+			return fmt.Errorf("滚动失败: %v", err)
+		}
+	case "navigate":
+		if in.URL == "" {
+			return fmt.Errorf("缺少 url")
+		}
+		return s.navigate(in.URL)
+	case "close_tab":
+		return s.closeTab()
+	default:
+		return fmt.Errorf("不支持的操作: %s", in.Type)
+	}
+	return nil
+}
+
+// followPopup 操作打开了新标签页时，录制和画面都切换到新标签页
+func (s *recordingSession) followPopup() {
+	time.Sleep(time.Second)
+	tab := s.run.findTab(nil)
+	if tab == nil {
+		return
+	}
+	if err := tab.WaitLoad(); err != nil {
+		log.Printf("⚠️ 标签页加载未完成: %v", err)
+	}
+	info, err := tab.Info()
+	if err != nil {
+		return
+	}
+	proto.PageStopScreencast{}.Call(s.run.tab)
+	s.run.useTab(tab)
+	s.target = info.URL
+	if err := s.attach(tab, info.URL); err != nil {
+		log.Printf("⚠️ %v", err)
+	}
+	log.Printf("🗂️ 录制 %s 切换到新标签页: %s", s.id, info.URL)
+	s.publish(recordingEvent{Type: "navigated", Data: map[string]string{"url": info.URL, "target": info.URL}})
+}
+
+// closeTab 关闭弹出的标签页，回到原标签页继续录制
+func (s *recordingSession) closeTab() error {
+	if s.target == "main" {
+		return fmt.Errorf("不能关闭原标签页")
+	}
+	s.addStep(ChromeDevToolsStep{Type: "close", Target: s.target})
+	if err := s.run.closeTab(); err != nil {
+		return err
+	}
+	s.target = "main"
+	quality, width := recordingFrameQuality, recordingFrameWidth
+	return proto.PageStartScreencast{Format: proto.PageStartScreencastFormatJpeg, Quality: &quality, MaxWidth: &width}.Call(s.run.root)
+}
+
+// undo 删除最后一个记录的操作
+func (s *recordingSession) undo() int {
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	if n := len(s.steps); n > 0 {
+		s.steps = s.steps[:n-1]
+	}
+	return len(s.steps)
+}
+
+// draft 按 Chrome 录制的转换规则生成轨迹
+func (s *recordingSession) draft() *TraceFile {
+	s.eventsMu.Lock()
+	rec := &ChromeDevToolsRecording{Title: s.name, Steps: append([]ChromeDevToolsStep{}, s.steps...)}
+	s.eventsMu.Unlock()
+
+	trace := traceconv.Convert(rec, s.traceType)
+	for i := range trace.Steps {
+		if s.keyword != "" && trace.Steps[i].Action == "input" && trace.Steps[i].Value == s.keyword {
+			trace.Steps[i].Value = "{{.Keyword}}"
+		}
+	}
+	return trace
+}
+
+// ==================== 画面推送 ====================
+
+func (s *recordingSession) publishFrame(frame *recordingFrame) {
+	s.eventsMu.Lock()
+	s.frame = frame
+	s.eventsMu.Unlock()
+	s.publish(recordingEvent{Type: "frame", Data: frame})
+}
+
+// publish 推送事件给所有订阅者，订阅者来不及接收时丢弃
+func (s *recordingSession) publish(event recordingEvent) {
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	for ch := range s.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+func (s *recordingSession) subscribe() (chan recordingEvent, func()) {
+	ch := make(chan recordingEvent, 16)
+	s.eventsMu.Lock()
+	s.subs[ch] = struct{}{}
+	if s.frame != nil {
+		ch <- recordingEvent{Type: "frame", Data: s.frame}
+	}
+	s.eventsMu.Unlock()
+	return ch, func() {
+		s.eventsMu.Lock()
+		delete(s.subs, ch)
+		s.eventsMu.Unlock()
+	}
+}
+
+// serveEvents 以 Server-Sent Events 推送画面和录制到的操作
+func (s *recordingSession) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "不支持事件推送", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch, unsubscribe := s.subscribe()
+	defer unsubscribe()
+	for {
+		select {
+		case event := <-ch:
+			data, _ := json.Marshal(event.Data)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		case <-s.ctx.Done():
+			fmt.Fprint(w, "event: closed\ndata: {}\n\n")
+			flusher.Flush()
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// close 结束录制，关闭页面并归还浏览器
+func (s *recordingSession) close() {
+	s.cancel()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, detach := range s.detach {
+		detach()
+	}
+	s.detach = nil
+	if s.done != nil {
+		s.done()
+		s.done = nil
+	}
+}
+
+// ==================== 录制管理 ====================
+
+func getRecording(id string) *recordingSession {
+	recordingsMu.Lock()
+	defer recordingsMu.Unlock()
+	return recordings[id]
+}
+
+func removeRecording(id string) *recordingSession {
+	recordingsMu.Lock()
+	defer recordingsMu.Unlock()
+	s := recordings[id]
+	delete(recordings, id)
+	return s
+}
+
+// closeIdleRecordings 关闭空闲超时的录制
+func closeIdleRecordings() {
+	recordingsMu.Lock()
+	var idle []*recordingSession
+	for id, s := range recordings {
+		if !s.mu.TryLock() {
+			continue // 正在执行操作
+		}
+		expired := time.Since(s.lastUsed) > recordingIdle
+		s.mu.Unlock()
+		if expired {
+			idle = append(idle, s)
+			delete(recordings, id)
+		}
+	}
+	recordingsMu.Unlock()
+
+	for _, s := range idle {
+		log.Printf("🧹 录制 %s 空闲超时，已关闭", s.id)
+		s.close()
+	}
+}
+
+// state 录制的当前结果：已记录的操作数和转换出的轨迹
+func (s *recordingSession) state() map[string]interface{} {
+	trace := s.draft()
+	issues := traceconv.Lint(trace)
+	return map[string]interface{}{
+		"id": s.id, "source_id": s.source.ID, "type": s.traceType, "target": s.target,
+		"url": s.run.currentURL(), "recorded": s.recorded(), "trace": trace, "issues": issues,
+	}
+}
+
+func (s *recordingSession) recorded() int {
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	return len(s.steps)
+}
+
+// handleRecordings 开始录制：POST /api/recordings {"source_id": 1, "url": "可选", "type": "list", "keyword": "电梯"}
+func handleRecordings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req recordingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SourceID <= 0 {
+		http.Error(w, "缺少 source_id", http.StatusBadRequest)
+		return
+	}
+	switch req.Type {
+	case "", "list", "detail", "login":
+	default:
+		http.Error(w, "type 应为 list、detail 或 login", http.StatusBadRequest)
+		return
+	}
+
+	recordingsMu.Lock()
+	full := len(recordings) >= maxRecordingSessions
+	recordingsMu.Unlock()
+	if full {
+		http.Error(w, fmt.Sprintf("进行中的录制已达上限（%d 个），请先结束不用的录制", maxRecordingSessions), http.StatusTooManyRequests)
+		return
+	}
+
+	s, err := startRecording(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recordingsMu.Lock()
+	recordings[s.id] = s
+	recordingsMu.Unlock()
+
+	recordingsJanitor.Do(func() {
+		go func() {
+			ticker := time.NewTicker(time.Minute)
+			defer ticker.Stop()
+			for range ticker.C {
+				closeIdleRecordings()
+			}
+		}()
+	})
+
+	log.Printf("⏺️ 开始录制 %s: %s（%s 轨迹）", s.id, s.source.Name, s.traceType)
+	s.mu.Lock()
+	state := s.state()
+	s.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": state})
+}
+
+// handleRecording 录制操作：
+//
+//	GET    /api/recordings/{id}          录制到的操作转换成的轨迹和校验结果
+//	GET    /api/recordings/{id}/events   画面和操作的事件流（Server-Sent Events）
+//	GET    /api/recordings/{id}/frame    最新一帧画面
+//	POST   /api/recordings/{id}/input    转发操作 {"type": "click", "x": 100, "y": 200}
+//	POST   /api/recordings/{id}/undo     删除最后一个操作
+//	POST   /api/recordings/{id}/save     保存为采集源的草稿轨迹 {"author": "", "comment": ""}
+//	DELETE /api/recordings/{id}          结束录制
+func handleRecording(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/recordings/"), "/"), "/")
+	if r.Method == "DELETE" && len(parts) == 1 {
+		if s := removeRecording(parts[0]); s != nil {
+			s.close()
+			log.Printf("⏹️ 录制 %s 已结束", s.id)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+		return
+	}
+
+	s := getRecording(parts[0])
+	if s == nil || s.ctx.Err() != nil {
+		http.Error(w, "录制不存在或已结束", http.StatusNotFound)
+		return
+	}
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	// 事件流是长连接，不占用页面操作锁
+	switch {
+	case r.Method == "GET" && action == "events":
+		s.serveEvents(w, r)
+		return
+	case r.Method == "GET" && action == "frame":
+		s.eventsMu.Lock()
+		frame := s.frame
+		s.eventsMu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": frame})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer func() { s.lastUsed = time.Now() }()
+
+	switch {
+	case r.Method == "GET" && action == "":
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": s.state()})
+
+	case r.Method == "POST" && action == "input":
+		var in recordingInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.input(in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": map[string]interface{}{
+			"target": s.target, "url": s.run.currentURL(), "recorded": s.recorded()}})
+
+	case r.Method == "POST" && action == "undo":
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": map[string]int{"recorded": s.undo()}})
+
+	case r.Method == "POST" && action == "save":
+		var req struct {
+			Author  string `json:"author"`
+			Comment string `json:"comment"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		trace := s.draft()
+		if len(trace.Steps) == 0 {
+			http.Error(w, "还没有录制到任何操作", http.StatusBadRequest)
+			return
+		}
+		issues := traceconv.Lint(trace)
+		raw, _ := json.MarshalIndent(trace, "", "  ")
+		comment := firstNonEmpty(req.Comment, fmt.Sprintf("在线录制: %d 个步骤", len(trace.Steps)))
+		traceID, version, err := saveTraceDraft(s.source.ID, trace.Type, trace.Name, string(raw), traceEntryURL(trace), req.Author, comment)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("💾 录制 %s 已保存为草稿: 轨迹 %d 版本 %d", s.id, traceID, version)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": map[string]interface{}{
+			"trace_id": traceID, "version": version, "trace": trace, "issues": issues, "valid": !traceconv.HasErrors(issues)}})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ==================== 轨迹修复助手 ====================
+//
+// 站点改版后，用已入库的标题和发布日期在当前页面中反查：找到样本标题所在的元素，
+// 多个样本的公共祖先即列表容器，其子元素即列表行，再按多数样本一致的相对路径生成
+// 标题、日期、链接字段的选择器。建议的选择器会在当前页面上试提取一次作为预览。
+
+// repairMaxSamples 用于匹配的已入库样本数
+const repairMaxSamples = 30
+
+// domSelectorHelpersJS 页面脚本中生成选择器的公共函数
+const domSelectorHelpersJS = `
+	const norm = (s) => (s || '').replace(/[\s\u00a0]+/g, '').toLowerCase();
+	const esc = (s) => CSS.escape(s);
+	const tagOf = (el) => el.tagName.toLowerCase();
+	// 含长数字、哈希的类名多为自动生成，表示状态的类名因行而异，都不参与选择器
+	const stableClasses = (el) => Array.from(el.classList).filter((c) =>
+		!/\d{3,}/.test(c) && !/^[a-z]{1,3}-[a-z0-9]{5,}$/i.test(c) &&
+		!/^(active|on|cur|current|selected|hover|odd|even|first|last|clearfix)$/i.test(c));
+	const stableID = (el) => (el.id && !/\d{4,}/.test(el.id) && !/^[a-f0-9-]{16,}$/i.test(el.id)) ? el.id : '';
+	// 同类型兄弟元素多于一个时返回 :nth-of-type(n)
+	const nthOfType = (el) => {
+		const parent = el.parentElement;
+		if (!parent) return '';
+		const same = Array.from(parent.children).filter((c) => c.tagName === el.tagName);
+		return same.length > 1 ? ':nth-of-type(' + (same.indexOf(el) + 1) + ')' : '';
+	};
+	const isUnique = (sel, el) => {
+		try { const found = document.querySelectorAll(sel); return found.length === 1 && found[0] === el; } catch (e) { return false; }
+	};
+	// cssPath 元素在页面中唯一的选择器：优先 ID，其次 标签.类名，必要时加 nth-of-type，遇到带 ID 的祖先即停止
+	const cssPath = (el) => {
+		const parts = [];
+		for (let cur = el; cur && cur !== document.documentElement; cur = cur.parentElement) {
+			const id = stableID(cur);
+			if (id && isUnique('#' + esc(id), cur)) { parts.unshift('#' + esc(id)); break; }
+			let seg = tagOf(cur) + stableClasses(cur).slice(0, 2).map((c) => '.' + esc(c)).join('');
+			const parent = cur.parentElement;
+			if (parent && Array.from(parent.children).some((c) => c !== cur && c.matches(seg))) seg += nthOfType(cur);
+			parts.unshift(seg);
+			if (isUnique(parts.join(' > '), el)) break;
+		}
+		return parts.join(' > ');
+	};
+	// relPath 元素相对列表行的最短选择器（标签加 nth-of-type），与轨迹中字段选择器的写法一致
+	const relPath = (row, el) => {
+		const parts = [];
+		for (let cur = el; cur && cur !== row; cur = cur.parentElement) {
+			parts.unshift(tagOf(cur) + nthOfType(cur));
+			try { if (row.querySelector(parts.join(' ')) === el) break; } catch (e) {}
+		}
+		return parts.join(' ');
+	};
+	// rowSelector 列表行选择器：容器路径加上所有行共有的类名
+	const rowSelector = (container, rows) => {
+		let common = stableClasses(rows[0]);
+		for (const r of rows) common = common.filter((c) => r.classList.contains(c));
+		return cssPath(container) + ' > ' + tagOf(rows[0]) + common.slice(0, 2).map((c) => '.' + esc(c)).join('');
+	};
+	const countOf = (sel) => { try { return document.querySelectorAll(sel).length; } catch (e) { return 0; } };
+	// vote 取出现次数最多的选择器
+	const vote = (list) => {
+		const counts = {};
+		let best = '', votes = 0;
+		for (const v of list) if (v) counts[v] = (counts[v] || 0) + 1;
+		for (const k in counts) if (counts[k] > votes) { best = k; votes = counts[k]; }
+		return { selector: best, votes: votes, total: list.length };
+	};
+`
+
+// repairScriptJS 用样本标题、日期反查列表结构
+const repairScriptJS = `function(samples) {` + domSelectorHelpersJS + `
+	const elements = Array.from(document.body.querySelectorAll('*')).filter((el) =>
+		!/^(script|style|noscript|template)$/i.test(el.tagName) &&
+		(el.getAttribute('title') || Array.from(el.childNodes).some((n) => n.nodeType === 3 && n.textContent.trim())));
+	// 标题可能被截断（以 ... 结尾，完整标题在 title 属性中），也可能带有 [公告] 等前缀
+	const titleMatch = (el, t) => {
+		const text = norm(el.textContent).replace(/(\.{2,}|…+)$/, '');
+		if (text && (text === t || (text.includes(t) && text.length <= t.length + 20))) return 'text';
+		if (norm(el.getAttribute('title')) === t) return 'title';
+		return text.length >= 10 && t.startsWith(text) ? 'text' : '';
+	};
+	const dateRegex = (d) => {
+		const m = (d || '').match(/(\d{4})\D+(\d{1,2})\D+(\d{1,2})/);
+		if (!m) return null;
+		return new RegExp(m[1] + '\\s*[-/.年]\\s*0?' + Number(m[2]) + '\\s*[-/.月]\\s*0?' + Number(m[3]) + '(?!\\d)');
+	};
+	const lca = (a, b) => { for (let cur = a; cur; cur = cur.parentElement) if (cur.contains(b)) return cur; return null; };
+	const childUnder = (container, el) => { let cur = el; while (cur && cur.parentElement !== container) cur = cur.parentElement; return cur; };
+
+	const matches = [];
+	for (const s of samples) {
+		const t = norm(s.title);
+		if (t.length < 4) continue;
+		for (const el of elements) {
+			const how = titleMatch(el, t);
+			if (how) { matches.push({ el: el, how: how, sample: s }); break; }
+		}
+	}
+	const result = { samples: samples.length, matched: 0, fields: {} };
+	if (!matches.length) return result;
+
+	// 样本两两的最近公共祖先中出现最多的即列表容器，侧栏等处的零星匹配不影响结果
+	let container = null;
+	const votes = new Map();
+	for (let i = 0; i < matches.length; i++) for (let j = i + 1; j < matches.length; j++) {
+		const c = lca(matches[i].el, matches[j].el);
+		if (c && c !== matches[i].el && c !== matches[j].el) votes.set(c, (votes.get(c) || 0) + 1);
+	}
+	let best = 0;
+	votes.forEach((v, c) => { if (v > best) { container = c; best = v; } });
+
+	let used;
+	if (container) {
+		used = matches.filter((m) => container.contains(m.el));
+		used.forEach((m) => { m.row = childUnder(container, m.el); });
+	} else {
+		// 只有一个样本：向上找 tr/li 等列表行，或有多个同类兄弟的块
+		const m = matches[0];
+		for (let cur = m.el.parentElement; cur && cur !== document.body; cur = cur.parentElement) {
+			const p = cur.parentElement;
+			if (/^(tr|li|dd|dt|article)$/i.test(cur.tagName) || (p && !/^(td|th|a|span|p|h\d)$/i.test(cur.tagName) &&
+				Array.from(p.children).filter((c) => c.tagName === cur.tagName && c.className === cur.className).length >= 3)) {
+				m.row = cur;
+				break;
+			}
+		}
+		if (!m.row) return result;
+		container = m.row.parentElement;
+		used = [m];
+	}
+
+	const rows = Array.from(new Set(used.map((m) => m.row)));
+	result.matched = used.length;
+	result.list_selector = rowSelector(container, rows);
+	result.row_count = countOf(result.list_selector);
+
+	const titles = [], urls = [], dates = [];
+	for (const m of used) {
+		const path = relPath(m.row, m.el);
+		titles.push(path && m.how === 'title' ? path + '@title' : path);
+
+		let a = m.el.closest('a[href]');
+		if (!a || !m.row.contains(a)) a = m.el.querySelector('a[href]') || m.row.querySelector('a[href]');
+		urls.push(a && a !== m.row ? relPath(m.row, a) + '@href | abs' : '');
+
+		const re = dateRegex(m.sample.date);
+		if (!re) continue;
+		const found = Array.from(m.row.querySelectorAll('*')).filter((e) => re.test(e.textContent));
+		const el = found.find((e) => !found.some((c) => c !== e && e.contains(c)));
+		if (!el) { dates.push(''); continue; }
+		// 日期前后有“发布时间：”等文字时用 date 处理器取出日期
+		const extra = norm(el.textContent).length > norm(el.textContent.match(re)[0]).length;
+		dates.push(relPath(m.row, el) + (extra ? ' | date' : ''));
+	}
+	result.fields.title = vote(titles);
+	result.fields.url = vote(urls);
+	if (dates.length) result.fields.date = vote(dates);
+	return result;
+}`
+
+// ==================== 列表页 ====================
+
+// listExtractIndex 轨迹中第一个顶层列表提取步骤的下标，没有时返回步骤数
+func listExtractIndex(trace *TraceFile) int {
+	for i, step := range trace.Steps {
+		if step.Action == "extract" && step.Type == "list" {
+			return i
+		}
+	}
+	return len(trace.Steps)
+}
+
+// openListPage 执行列表轨迹到列表提取步骤之前，停留在列表页上供分析页面结构
+func openListPage(ctx context.Context, source *Source, trace *TraceFile, keyword string) (*browserRun, func(), error) {
+	run, closeAll, err := openTracePage(ctx, source, trace, keyword)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := run.runSteps(trace.Steps[:listExtractIndex(trace)]); err != nil {
+		closeAll()
+		return nil, nil, fmt.Errorf("执行列表轨迹失败: %v", err)
+	}
+	return run, closeAll, nil
+}
+
+// openTracePage 为执行轨迹准备一个空白页面，尚未执行任何步骤。
+// source 不为空时按采集源的代理、反检测设置租用浏览器，非登录轨迹还会恢复登录会话；返回的函数关闭页面并归还浏览器
+func openTracePage(ctx context.Context, source *Source, trace *TraceFile, keyword string) (*browserRun, func(), error) {
+	env := &adapterEnv{Source: &Source{}, Solver: NewCaptchaSolver(captchaService), ctx: ctx}
+	if source != nil {
+		ctx = sourceContext(ctx, source)
+		env.Source, env.ctx = source, ctx
+		if trace.Type != "login" {
+			if env.LoginTrace, _ = loadSourceTrace(source, "login"); env.LoginTrace != nil {
+				env.LoginTrace.Type = "login"
+			}
+		}
+		var err error
+		if env.credential, err = getSourceCredential(source.ID); err != nil {
+			return nil, nil, fmt.Errorf("读取登录凭据失败: %v", err)
+		}
+	}
+	browser, err := env.Browser()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := env.prepareSession(ctx, browser); err != nil {
+		env.Close()
+		return nil, nil, err
+	}
+
+	run, done := newBrowserRun(ctx, browser, trace, map[string]string{"Keyword": keyword}, env.Solver)
+	return run, func() {
+		done()
+		env.Close()
+	}, nil
+}
+
+// replaceTraceStep 返回替换了第 idx 个步骤的轨迹副本，idx 等于步骤数时追加
+func replaceTraceStep(trace *TraceFile, idx int, step TraceStep) *TraceFile {
+	patched := *trace
+	patched.Steps = append([]TraceStep{}, trace.Steps...)
+	if idx >= len(patched.Steps) {
+		patched.Steps = append(patched.Steps, step)
+	} else {
+		patched.Steps[idx] = step
+	}
+	return &patched
+}
+
+// saveListTrace 把轨迹保存为采集源列表轨迹的新版本，返回版本号
+func saveListTrace(sourceID int, trace *TraceFile, author, comment string) (int, error) {
+	if blocking := activationBlockers(trace); blocking != nil {
+		return 0, fmt.Errorf("修复后的轨迹校验未通过: %s", blocking[0])
+	}
+	raw, _ := json.MarshalIndent(trace, "", "  ")
+	_, version, err := saveTraceVersion(sourceID, "list", trace.Name, string(raw), trace.URL, author, comment)
+	return version, err
+}
+
+// ==================== 样本匹配 ====================
+
+// repairSample 用于匹配的已知条目
+type repairSample struct {
+	Title string `json:"title"`
+	Date  string `json:"date"`
+}
+
+// repairMatch 页面脚本的匹配结果
+type repairMatch struct {
+	Samples      int                  `json:"samples"`
+	Matched      int                  `json:"matched"`
+	ListSelector string               `json:"list_selector"`
+	RowCount     int                  `json:"row_count"`
+	Fields       map[string]fieldVote `json:"fields"`
+}
+
+type fieldVote struct {
+	Selector string `json:"selector"`
+	Votes    int    `json:"votes"`
+	Total    int    `json:"total"`
+}
+
+// FieldProposal 字段选择器建议
+type FieldProposal struct {
+	Old      string `json:"old"`
+	Selector string `json:"selector"`
+	Votes    int    `json:"votes"` // 与建议一致的样本数
+	Total    int    `json:"total"`
+}
+
+// RepairProposal 修复建议
+type RepairProposal struct {
+	SourceID       int                      `json:"source_id"`
+	TraceVersion   int                      `json:"trace_version"`
+	Keyword        string                   `json:"keyword"`
+	PageURL        string                   `json:"page_url"`
+	Samples        int                      `json:"samples"`
+	Matched        int                      `json:"matched"` // 在页面中找到的样本数
+	OldSelector    string                   `json:"old_selector"`
+	ListSelector   string                   `json:"list_selector"`
+	RowCount       int                      `json:"row_count"`
+	Fields         map[string]FieldProposal `json:"fields"`
+	Confidence     int                      `json:"confidence"` // 0-100
+	Preview        []map[string]string      `json:"preview"`
+	Trace          *TraceFile               `json:"trace,omitempty"` // 替换列表提取步骤后的轨迹
+	AppliedVersion int                      `json:"applied_version,omitempty"`
+}
+
+// repairRequest 修复请求
+type repairRequest struct {
+	SourceID int      `json:"source_id"`
+	Keyword  string   `json:"keyword"` // 默认取最近入库条目的关键词
+	Samples  []string `json:"samples"` // 当前页面上能看到的标题，补充已入库的样本
+	Apply    bool     `json:"apply"`   // 保存为列表轨迹的新版本
+	Author   string   `json:"author"`
+}
+
+// repairListTrace 执行列表轨迹到提取步骤之前，用样本反查新的列表和字段选择器
+func repairListTrace(ctx context.Context, req repairRequest) (*RepairProposal, error) {
+	source, err := getSourceByID(req.SourceID)
+	if err != nil {
+		return nil, fmt.Errorf("获取采集源失败: %v", err)
+	}
+	trace, version := loadSourceTrace(source, "list")
+	if trace == nil {
+		return nil, errNoListTrace
+	}
+	idx := listExtractIndex(trace)
+	if idx == len(trace.Steps) {
+		return nil, fmt.Errorf("列表轨迹中没有顶层的列表提取步骤，修复助手暂不支持 if/foreach 中的提取")
+	}
+
+	keyword, samples, err := loadRepairSamples(source.ID, req.Keyword)
+	if err != nil {
+		return nil, err
+	}
+	for _, title := range req.Samples {
+		if title = strings.TrimSpace(title); title != "" {
+			samples = append(samples, repairSample{Title: title})
+		}
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("没有可用于匹配的样本，请在 samples 中提供当前页面上能看到的标题")
+	}
+
+	log.Printf("🔧 修复助手: %s，关键词=%s，样本 %d 条", source.Name, keyword, len(samples))
+	run, done, err := openListPage(ctx, source, trace, keyword)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	res, err := run.page.Eval(repairScriptJS, samples)
+	if err != nil {
+		return nil, fmt.Errorf("页面匹配失败: %v", err)
+	}
+	var match repairMatch
+	if err := res.Value.Unmarshal(&match); err != nil {
+		return nil, fmt.Errorf("解析匹配结果失败: %v", err)
+	}
+
+	old := trace.Steps[idx]
+	proposal := &RepairProposal{
+		SourceID:     source.ID,
+		TraceVersion: version,
+		Keyword:      keyword,
+		PageURL:      run.page.MustInfo().URL,
+		Samples:      match.Samples,
+		Matched:      match.Matched,
+		OldSelector:  old.Selector,
+		ListSelector: match.ListSelector,
+		RowCount:     match.RowCount,
+		Fields:       map[string]FieldProposal{},
+		Preview:      []map[string]string{},
+	}
+	if old.XPath != "" {
+		proposal.OldSelector = "xpath:" + old.XPath
+	}
+	if match.ListSelector == "" {
+		log.Printf("🔧 修复助手: 页面中没有找到样本标题")
+		return proposal, nil
+	}
+
+	step := old
+	step.Selector, step.XPath = match.ListSelector, ""
+	step.Fields = map[string]string{}
+	for field, selector := range old.Fields {
+		step.Fields[field] = selector
+	}
+	for field, v := range match.Fields {
+		if v.Selector == "" {
+			continue
+		}
+		proposal.Fields[field] = FieldProposal{Old: old.Fields[field], Selector: v.Selector, Votes: v.Votes, Total: v.Total}
+		step.Fields[field] = v.Selector
+	}
+
+	if preview := extractList(run.page, step, nil); len(preview) > 0 {
+		if len(preview) > 5 {
+			preview = preview[:5]
+		}
+		proposal.Preview = preview
+	}
+	proposal.Confidence = repairConfidence(match, len(proposal.Preview))
+
+	proposal.Trace = replaceTraceStep(trace, idx, step)
+	log.Printf("🔧 修复助手建议: %s → %s（置信度 %d）", proposal.OldSelector, step.Selector, proposal.Confidence)
+	return proposal, nil
+}
+
+// repairConfidence 置信度：标题路径一致的样本比例 × 匹配样本数（3 个以上为满分），
+// 日期路径一致的比例占 20%，没有找到链接或预览提取不到数据时降低
+func repairConfidence(match repairMatch, previewCount int) int {
+	ratio := func(v fieldVote) float64 {
+		if v.Total == 0 {
+			return 0
+		}
+		return float64(v.Votes) / float64(v.Total)
+	}
+	coverage := float64(match.Matched) / 3
+	if coverage > 1 {
+		coverage = 1
+	}
+	conf := ratio(match.Fields["title"]) * coverage
+	if date, ok := match.Fields["date"]; ok {
+		conf = conf*0.8 + ratio(date)*0.2
+	}
+	if match.Fields["url"].Selector == "" {
+		conf *= 0.8
+	}
+	if previewCount == 0 {
+		conf /= 2
+	}
+	return int(conf*100 + 0.5)
+}
+
+// loadRepairSamples 读取已入库的样本：同一关键词最近入库的条目，这些条目最可能出现在当前搜索结果中
+func loadRepairSamples(sourceID int, keyword string) (string, []repairSample, error) {
+	if keyword == "" {
+		db.QueryRow(`SELECT COALESCE(keywords, '') FROM tenders WHERE source_id = ? AND COALESCE(keywords, '') != ''
+			ORDER BY created_at DESC LIMIT 1`, sourceID).Scan(&keyword)
+	}
+	if keyword == "" {
+		return "", nil, fmt.Errorf("采集源没有已入库的条目，请指定 keyword 和 samples")
+	}
+
+	rows, err := db.Query(`SELECT COALESCE(title, ''), COALESCE(publish_date, '') FROM tenders
+		WHERE source_id = ? AND keywords = ? AND COALESCE(title, '') != '' ORDER BY created_at DESC LIMIT ?`,
+		sourceID, keyword, repairMaxSamples)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+
+	var samples []repairSample
+	for rows.Next() {
+		var s repairSample
+		if rows.Scan(&s.Title, &s.Date) == nil {
+			samples = append(samples, s)
+		}
+	}
+	return keyword, samples, nil
+}
+
+// handleTraceRepair 修复助手：POST /api/trace-health/repair
+// {"source_id": 1, "keyword": "可选", "samples": ["可选的页面标题"], "apply": false}
+// apply 为 true 且找到列表时，把建议保存为列表轨迹的新版本
+func handleTraceRepair(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req repairRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SourceID <= 0 {
+		http.Error(w, "缺少 source_id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Minute)
+	defer cancel()
+	proposal, err := repairListTrace(ctx, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Apply {
+		if proposal.Trace == nil {
+			http.Error(w, "页面中没有找到样本，无法生成修复版本", http.StatusUnprocessableEntity)
+			return
+		}
+		comment := fmt.Sprintf("修复助手: 列表选择器 %s → %s（置信度 %d）", proposal.OldSelector, proposal.ListSelector, proposal.Confidence)
+		version, err := saveListTrace(req.SourceID, proposal.Trace, firstNonEmpty(req.Author, "修复助手"), comment)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		proposal.AppliedVersion = version
+		log.Printf("🔧 修复建议已保存为列表轨迹版本 %d", version)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": proposal})
+}
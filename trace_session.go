@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ==================== 轨迹编辑器：调试会话 ====================
+//
+// 调试会话保持一个浏览器页面，按需执行轨迹的前 N 个顶层步骤，返回截图和下一步要操作的元素位置。
+// 再次执行时只补跑新增的步骤；目标步骤在已执行位置之前、或已执行的步骤被修改过时从头重新执行。
+
+const (
+	traceSessionIdle   = 10 * time.Minute // 空闲超过该时间的会话自动关闭
+	maxTraceSessions   = 3                // 同时打开的调试会话上限
+	maxHighlightBoxes  = 20
+	highlightTextRunes = 60
+)
+
+var (
+	traceSessions       = make(map[string]*traceSession)
+	traceSessionsMu     sync.Mutex
+	traceSessionJanitor sync.Once
+)
+
+// traceSession 一个调试会话
+type traceSession struct {
+	mu       sync.Mutex
+	id       string
+	traceID  int
+	version  int // 固定调试的版本，0 表示跟随编辑器的最新版本（修改后无需启用即可调试）
+	keyword  string
+	ctx      context.Context
+	cancel   context.CancelFunc
+	trace    *TraceFile // 已执行步骤所属的轨迹
+	run      *browserRun
+	done     func()
+	pos      int // 已执行的顶层步骤数
+	lastUsed time.Time
+}
+
+// highlightBox 元素在截图中的位置（CSS 像素）
+type highlightBox struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Text   string  `json:"text,omitempty"`
+}
+
+// elementHighlight 选择器在当前页面的匹配情况
+type elementHighlight struct {
+	Selector string         `json:"selector"`
+	Count    int            `json:"count"`
+	Boxes    []highlightBox `json:"boxes"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// sessionSnapshot 执行后的页面状态
+type sessionSnapshot struct {
+	SessionID  string            `json:"session_id"`
+	TraceID    int               `json:"trace_id"`
+	Version    int               `json:"version"`
+	Position   int               `json:"position"` // 已执行的顶层步骤数
+	StepCount  int               `json:"step_count"`
+	URL        string            `json:"url,omitempty"`
+	Screenshot string            `json:"screenshot,omitempty"` // 当前视口的 PNG 截图，base64
+	Vars       map[string]string `json:"vars,omitempty"`
+	Data       interface{}       `json:"data,omitempty"`
+	FailedStep int               `json:"failed_step,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Highlight  *elementHighlight `json:"highlight,omitempty"` // 下一步（或指定选择器）要操作的元素
+}
+
+// sessionRunRequest 执行请求
+type sessionRunRequest struct {
+	To        int    `json:"to"`        // 执行到第几个顶层步骤（含），默认全部
+	Restart   bool   `json:"restart"`   // 强制从头执行
+	Highlight string `json:"highlight"` // 要高亮的选择器，默认为下一步的目标元素
+}
+
+// loadSessionTrace 读取会话调试的轨迹版本
+func (s *traceSession) loadSessionTrace() (*editableTrace, error) {
+	t, err := loadEditableTrace(s.traceID)
+	if err != nil || s.version == 0 || s.version == t.Version {
+		return t, err
+	}
+	v, err := getTraceVersion(s.traceID, s.version)
+	if err != nil {
+		return nil, err
+	}
+	if t.Trace, err = parseTraceFile(v.RawContent); err != nil {
+		return nil, fmt.Errorf("解析轨迹失败: %v", err)
+	}
+	if t.Trace.Type == "" {
+		t.Trace.Type = t.Type
+	}
+	t.Version = s.version
+	return t, nil
+}
+
+// reset 关闭当前页面，下次执行时从头开始
+func (s *traceSession) reset() {
+	if s.done != nil {
+		s.done()
+	}
+	s.run, s.done, s.trace, s.pos = nil, nil, nil, 0
+}
+
+// runTo 执行到第 to 个顶层步骤，已执行的前缀未变化时只补跑后续步骤
+func (s *traceSession) runTo(req sessionRunRequest) (*sessionSnapshot, error) {
+	t, err := s.loadSessionTrace()
+	if err != nil {
+		return nil, err
+	}
+	steps := t.Trace.Steps
+	to := req.To
+	if to <= 0 {
+		to = len(steps)
+	}
+	if to > len(steps) {
+		return nil, fmt.Errorf("轨迹只有 %d 个步骤", len(steps))
+	}
+
+	if s.run == nil || req.Restart || to < s.pos || !sameSteps(s.trace.Steps[:s.pos], steps) {
+		s.reset()
+		var source *Source
+		if t.SourceID > 0 {
+			source, _ = getSourceByID(t.SourceID)
+		}
+		run, done, err := openTracePage(s.ctx, source, t.Trace, s.keyword)
+		if err != nil {
+			return nil, err
+		}
+		s.run, s.done = run, done
+	}
+	s.trace = t.Trace
+	s.run.trace = t.Trace
+
+	snap := &sessionSnapshot{SessionID: s.id, TraceID: s.traceID, Version: t.Version, StepCount: len(steps)}
+	for s.pos < to {
+		log.Printf("🐞 调试会话 %s 执行步骤 %d/%d: %s", s.id, s.pos+1, len(steps), steps[s.pos].Action)
+		if err := s.run.runSteps(steps[s.pos : s.pos+1]); err != nil {
+			snap.FailedStep, snap.Error = s.pos+1, err.Error()
+			break
+		}
+		s.pos++
+	}
+
+	highlight := req.Highlight
+	if highlight == "" && s.pos < len(steps) {
+		highlight = stepTargetSelector(steps[s.pos])
+	}
+	s.fillSnapshot(snap, highlight)
+	return snap, nil
+}
+
+// sameSteps 判断 prefix 是否为 steps 的前缀（逐个步骤比较内容）
+func sameSteps(prefix, steps []TraceStep) bool {
+	if len(prefix) > len(steps) {
+		return false
+	}
+	a, _ := json.Marshal(prefix)
+	b, _ := json.Marshal(steps[:len(prefix)])
+	return string(a) == string(b)
+}
+
+// stepTargetSelector 步骤操作的元素选择器
+func stepTargetSelector(step TraceStep) string {
+	switch {
+	case step.Selector != "":
+		return step.Selector
+	case step.WaitForVisible != "":
+		return step.WaitForVisible
+	case step.Condition != nil && step.Condition.Exists != "":
+		return step.Condition.Exists
+	}
+	return ""
+}
+
+// fillSnapshot 截取当前页面状态
+func (s *traceSession) fillSnapshot(snap *sessionSnapshot, highlight string) {
+	snap.Position = s.pos
+	if s.run == nil {
+		return
+	}
+	snap.URL = s.run.currentURL()
+	snap.Data = s.run.data
+	snap.Vars = copyParams(s.run.vars)
+	if _, ok := snap.Vars["Password"]; ok {
+		snap.Vars["Password"] = "******"
+	}
+	if img, err := s.run.tab.Screenshot(false, nil); err != nil {
+		log.Printf("⚠️ 调试会话截图失败: %v", err)
+	} else {
+		snap.Screenshot = base64.StdEncoding.EncodeToString(img)
+	}
+	if highlight != "" {
+		snap.Highlight = s.highlight(highlight)
+	}
+}
+
+// highlight 查找选择器匹配的元素及其位置
+func (s *traceSession) highlight(selector string) *elementHighlight {
+	h := &elementHighlight{Selector: selector, Boxes: []highlightBox{}}
+	if isItem, _ := splitItemSelector(selector); isItem {
+		h.Error = fmt.Sprintf("%s 选择器只能在 foreach 遍历元素时定位", itemSelectorPrefix)
+		return h
+	}
+	elems, err := findElements(s.run.page, replaceParams(selector, s.run.vars))
+	if err != nil {
+		h.Error = err.Error()
+		return h
+	}
+	h.Count = len(elems)
+	for _, elem := range elems {
+		if len(h.Boxes) >= maxHighlightBoxes {
+			break
+		}
+		shape, err := elem.Shape()
+		if err != nil || len(shape.Quads) == 0 {
+			continue // 不可见的元素没有位置
+		}
+		box := shape.Box()
+		text, _ := elem.Text()
+		text = strings.Join(strings.Fields(text), " ")
+		if utf8.RuneCountInString(text) > highlightTextRunes {
+			text = string([]rune(text)[:highlightTextRunes]) + "…"
+		}
+		h.Boxes = append(h.Boxes, highlightBox{X: box.X, Y: box.Y, Width: box.Width, Height: box.Height, Text: text})
+	}
+	return h
+}
+
+// close 关闭会话的页面并归还浏览器
+func (s *traceSession) close() {
+	s.cancel()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reset()
+}
+
+// ==================== 会话管理 ====================
+
+func getTraceSession(id string) *traceSession {
+	traceSessionsMu.Lock()
+	defer traceSessionsMu.Unlock()
+	return traceSessions[id]
+}
+
+func removeTraceSession(id string) *traceSession {
+	traceSessionsMu.Lock()
+	defer traceSessionsMu.Unlock()
+	s := traceSessions[id]
+	delete(traceSessions, id)
+	return s
+}
+
+// closeIdleTraceSessions 关闭空闲超时的会话
+func closeIdleTraceSessions() {
+	traceSessionsMu.Lock()
+	var idle []*traceSession
+	for id, s := range traceSessions {
+		if !s.mu.TryLock() {
+			continue // 正在执行步骤
+		}
+		expired := time.Since(s.lastUsed) > traceSessionIdle
+		s.mu.Unlock()
+		if expired {
+			idle = append(idle, s)
+			delete(traceSessions, id)
+		}
+	}
+	traceSessionsMu.Unlock()
+
+	for _, s := range idle {
+		log.Printf("🧹 调试会话 %s 空闲超时，已关闭", s.id)
+		s.close()
+	}
+}
+
+// handleTraceSessionCreate 创建调试会话：POST /api/traces/{id}/session {"version": 0, "keyword": "可选"}
+func handleTraceSessionCreate(w http.ResponseWriter, r *http.Request, traceID int) {
+	var req struct {
+		Version int    `json:"version"`
+		Keyword string `json:"keyword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &traceSession{
+		id:       fmt.Sprintf("session_%d_%d", traceID, time.Now().UnixNano()),
+		traceID:  traceID,
+		version:  req.Version,
+		keyword:  req.Keyword,
+		ctx:      ctx,
+		cancel:   cancel,
+		lastUsed: time.Now(),
+	}
+	t, err := s.loadSessionTrace()
+	if err != nil {
+		cancel()
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	traceSessionsMu.Lock()
+	if len(traceSessions) >= maxTraceSessions {
+		traceSessionsMu.Unlock()
+		cancel()
+		http.Error(w, fmt.Sprintf("调试会话已达上限（%d 个），请先关闭不用的会话", maxTraceSessions), http.StatusTooManyRequests)
+		return
+	}
+	traceSessions[s.id] = s
+	traceSessionsMu.Unlock()
+
+	traceSessionJanitor.Do(func() {
+		go func() {
+			ticker := time.NewTicker(time.Minute)
+			defer ticker.Stop()
+			for range ticker.C {
+				closeIdleTraceSessions()
+			}
+		}()
+	})
+
+	log.Printf("🐞 已创建轨迹 %d 的调试会话 %s", traceID, s.id)
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": &sessionSnapshot{
+		SessionID: s.id, TraceID: traceID, Version: t.Version, StepCount: len(t.Trace.Steps)}})
+}
+
+// handleTraceSession 调试会话操作：
+//
+//	GET    /api/trace-sessions/{sid}             当前页面状态
+//	POST   /api/trace-sessions/{sid}/run         执行到第 N 步 {"to": 3, "restart": false, "highlight": "可选选择器"}
+//	POST   /api/trace-sessions/{sid}/highlight   在当前页面定位元素 {"selector": "..."}
+//	DELETE /api/trace-sessions/{sid}             关闭会话
+func handleTraceSession(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/trace-sessions/"), "/"), "/")
+	if r.Method == "DELETE" && len(parts) == 1 {
+		if s := removeTraceSession(parts[0]); s != nil {
+			s.close()
+			log.Printf("🐞 调试会话 %s 已关闭", s.id)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+		return
+	}
+
+	s := getTraceSession(parts[0])
+	if s == nil {
+		http.Error(w, "调试会话不存在或已过期", http.StatusNotFound)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer func() { s.lastUsed = time.Now() }()
+	if s.ctx.Err() != nil {
+		http.Error(w, "调试会话已关闭", http.StatusGone)
+		return
+	}
+
+	switch {
+	case r.Method == "GET" && len(parts) == 1:
+		snap := &sessionSnapshot{SessionID: s.id, TraceID: s.traceID, Version: s.version}
+		if s.trace != nil {
+			snap.StepCount = len(s.trace.Steps)
+		}
+		s.fillSnapshot(snap, "")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": snap})
+
+	case r.Method == "POST" && len(parts) == 2 && parts[1] == "run":
+		var req sessionRunRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		snap, err := s.runTo(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": snap})
+
+	case r.Method == "POST" && len(parts) == 2 && parts[1] == "highlight":
+		var req struct {
+			Selector string `json:"selector"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Selector == "" {
+			http.Error(w, "缺少 selector", http.StatusBadRequest)
+			return
+		}
+		if s.run == nil {
+			http.Error(w, "会话尚未执行任何步骤", http.StatusConflict)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": s.highlight(req.Selector)})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,541 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"tender-monitor/traceconv"
+)
+
+// ==================== 轨迹版本 ====================
+//
+// 每次上传轨迹都保存为 trace_versions 中一个不可变的新版本（版本号按轨迹递增），
+// traces 表每个 (source_id, type) 一行，active_version 指向当前生效的版本，
+// raw_content/name/parsed_url 是生效版本的副本，采集时直接读取。回滚即把旧版本重新设为生效版本。
+
+// TraceVersion 轨迹的一个版本
+type TraceVersion struct {
+	ID         int    `json:"id"`
+	TraceID    int    `json:"trace_id"`
+	Version    int    `json:"version"`
+	Name       string `json:"name"`
+	RawContent string `json:"raw_content,omitempty"`
+	ParsedURL  string `json:"parsed_url"`
+	StepCount  int    `json:"step_count"`
+	Author     string `json:"author"`
+	Comment    string `json:"comment"`
+	Active     bool   `json:"active"`
+	CreatedAt  string `json:"created_at"`
+}
+
+func migrateTracesTable() {
+	ensureColumns("traces", []columnMigration{
+		{"active_version", "INTEGER DEFAULT 0"},
+	})
+}
+
+// backfillTraceVersions 为升级前上传的轨迹补充第 1 个版本
+func backfillTraceVersions() {
+	rows, err := db.Query(`SELECT id, name, COALESCE(raw_content,''), COALESCE(parsed_url,''), COALESCE(created_at,'') FROM traces
+		WHERE id NOT IN (SELECT trace_id FROM trace_versions)`)
+	if err != nil {
+		return
+	}
+	var pending []TraceVersion
+	for rows.Next() {
+		var v TraceVersion
+		if rows.Scan(&v.TraceID, &v.Name, &v.RawContent, &v.ParsedURL, &v.CreatedAt) == nil {
+			pending = append(pending, v)
+		}
+	}
+	rows.Close()
+
+	for _, v := range pending {
+		db.Exec(`INSERT INTO trace_versions (trace_id, version, name, raw_content, parsed_url, author, comment, created_at)
+			VALUES (?, 1, ?, ?, ?, '', '升级前的轨迹', ?)`, v.TraceID, v.Name, v.RawContent, v.ParsedURL, v.CreatedAt)
+		db.Exec("UPDATE traces SET active_version = 1 WHERE id = ?", v.TraceID)
+	}
+	if len(pending) > 0 {
+		log.Printf("📦 已为 %d 个历史轨迹创建版本 1", len(pending))
+	}
+}
+
+// saveTraceVersion 保存新版本并设为生效版本，返回轨迹 ID 和版本号
+func saveTraceVersion(sourceID int, traceType, name, rawContent, parsedURL, author, comment string) (int, int, error) {
+	return storeTraceVersion(sourceID, traceType, name, rawContent, parsedURL, author, comment, true)
+}
+
+// saveTraceDraft 保存为草稿版本，不切换生效版本；轨迹不存在时以 draft 状态创建，采集时不会使用。
+// 草稿轨迹的后续版本仍为草稿，回滚到某个版本即可启用
+func saveTraceDraft(sourceID int, traceType, name, rawContent, parsedURL, author, comment string) (int, int, error) {
+	return storeTraceVersion(sourceID, traceType, name, rawContent, parsedURL, author, comment, false)
+}
+
+func storeTraceVersion(sourceID int, traceType, name, rawContent, parsedURL, author, comment string, activate bool) (int, int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	var traceID int
+	var status string
+	err = tx.QueryRow("SELECT id, COALESCE(status, '') FROM traces WHERE source_id = ? AND type = ?", sourceID, traceType).Scan(&traceID, &status)
+	if err == sql.ErrNoRows {
+		status = "active"
+		if !activate {
+			status = "draft"
+		}
+		res, insErr := tx.Exec(`INSERT INTO traces (source_id, name, type, status, active_version) VALUES (?, ?, ?, ?, 0)`,
+			sourceID, name, traceType, status)
+		if insErr != nil {
+			return 0, 0, fmt.Errorf("创建轨迹失败: %v", insErr)
+		}
+		id, _ := res.LastInsertId()
+		traceID = int(id)
+	} else if err != nil {
+		return 0, 0, err
+	}
+
+	var version int
+	tx.QueryRow("SELECT COALESCE(MAX(version), 0) + 1 FROM trace_versions WHERE trace_id = ?", traceID).Scan(&version)
+	if _, err := tx.Exec(`INSERT INTO trace_versions (trace_id, version, name, raw_content, parsed_url, author, comment, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, traceID, version, name, rawContent, parsedURL, author, comment,
+		time.Now().Format("2006-01-02 15:04:05")); err != nil {
+		return 0, 0, fmt.Errorf("保存轨迹版本失败: %v", err)
+	}
+	switch {
+	case activate:
+		if err := activateVersionTx(tx, traceID, version); err != nil {
+			return 0, 0, err
+		}
+	case status == "draft":
+		// 草稿轨迹指向最新的草稿版本，便于编辑和调试，状态保持 draft 直到启用
+		if _, err := tx.Exec("UPDATE traces SET name = ?, raw_content = ?, parsed_url = ?, active_version = ? WHERE id = ?",
+			name, rawContent, parsedURL, version, traceID); err != nil {
+			return 0, 0, fmt.Errorf("保存轨迹版本失败: %v", err)
+		}
+	}
+	return traceID, version, tx.Commit()
+}
+
+// activateTraceVersion 将指定版本设为生效版本（回滚）
+func activateTraceVersion(traceID, version int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := activateVersionTx(tx, traceID, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// activationBlockers 轨迹生效前的完整校验，返回错误级问题。
+// 上传、修复助手保存、编辑器启用和回滚都使用这一规则：有错误级问题的轨迹不会成为生效版本
+func activationBlockers(trace *TraceFile) []traceconv.Issue {
+	var blocking []traceconv.Issue
+	for _, issue := range traceconv.Lint(trace) {
+		if issue.Level == traceconv.LevelError {
+			blocking = append(blocking, issue)
+		}
+	}
+	return blocking
+}
+
+// activateCheckedTraceVersion 完整校验指定版本，没有错误级问题时设为生效版本；
+// 校验未通过时返回这些问题，不切换版本
+func activateCheckedTraceVersion(traceID, version int) ([]traceconv.Issue, error) {
+	v, err := getTraceVersion(traceID, version)
+	if err != nil {
+		return nil, err
+	}
+	trace, err := parseTraceFile(v.RawContent)
+	if err != nil {
+		return nil, fmt.Errorf("解析轨迹失败: %v", err)
+	}
+	if blocking := activationBlockers(trace); blocking != nil {
+		return blocking, fmt.Errorf("版本 %d 校验未通过，不能启用", version)
+	}
+	return nil, activateTraceVersion(traceID, version)
+}
+
+func activateVersionTx(tx *sql.Tx, traceID, version int) error {
+	res, err := tx.Exec(`UPDATE traces SET
+			name = (SELECT name FROM trace_versions WHERE trace_id = ? AND version = ?),
+			raw_content = (SELECT raw_content FROM trace_versions WHERE trace_id = ? AND version = ?),
+			parsed_url = (SELECT parsed_url FROM trace_versions WHERE trace_id = ? AND version = ?),
+			active_version = ?, status = 'active'
+		WHERE id = ? AND EXISTS (SELECT 1 FROM trace_versions WHERE trace_id = ? AND version = ?)`,
+		traceID, version, traceID, version, traceID, version, version, traceID, traceID, version)
+	if err != nil {
+		return fmt.Errorf("切换轨迹版本失败: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("轨迹 %d 不存在版本 %d", traceID, version)
+	}
+	return nil
+}
+
+// getTraceVersions 列出轨迹的所有版本（不含内容），新版本在前
+func getTraceVersions(traceID int) ([]TraceVersion, error) {
+	var active int
+	if err := db.QueryRow("SELECT COALESCE(active_version, 0) FROM traces WHERE id = ?", traceID).Scan(&active); err != nil {
+		return nil, fmt.Errorf("轨迹不存在: %d", traceID)
+	}
+	rows, err := db.Query(`SELECT id, trace_id, version, name, COALESCE(raw_content,''), COALESCE(parsed_url,''),
+		COALESCE(author,''), COALESCE(comment,''), COALESCE(created_at,'')
+		FROM trace_versions WHERE trace_id = ? ORDER BY version DESC`, traceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []TraceVersion{}
+	for rows.Next() {
+		var v TraceVersion
+		if err := rows.Scan(&v.ID, &v.TraceID, &v.Version, &v.Name, &v.RawContent, &v.ParsedURL,
+			&v.Author, &v.Comment, &v.CreatedAt); err != nil {
+			continue
+		}
+		if trace, err := parseTraceFile(v.RawContent); err == nil {
+			v.StepCount = len(trace.Steps)
+		}
+		v.RawContent = ""
+		v.Active = v.Version == active
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// getTraceVersion 读取指定版本（含内容）
+func getTraceVersion(traceID, version int) (*TraceVersion, error) {
+	var v TraceVersion
+	var active int
+	err := db.QueryRow(`SELECT v.id, v.trace_id, v.version, v.name, COALESCE(v.raw_content,''), COALESCE(v.parsed_url,''),
+		COALESCE(v.author,''), COALESCE(v.comment,''), COALESCE(v.created_at,''), COALESCE(t.active_version, 0)
+		FROM trace_versions v JOIN traces t ON t.id = v.trace_id WHERE v.trace_id = ? AND v.version = ?`, traceID, version).
+		Scan(&v.ID, &v.TraceID, &v.Version, &v.Name, &v.RawContent, &v.ParsedURL, &v.Author, &v.Comment, &v.CreatedAt, &active)
+	if err != nil {
+		return nil, fmt.Errorf("轨迹 %d 不存在版本 %d", traceID, version)
+	}
+	v.Active = v.Version == active
+	return &v, nil
+}
+
+// deleteTrace 删除轨迹及其所有版本
+func deleteTrace(traceID int) error {
+	if _, err := db.Exec("DELETE FROM trace_versions WHERE trace_id = ?", traceID); err != nil {
+		return err
+	}
+	_, err := db.Exec("DELETE FROM traces WHERE id = ?", traceID)
+	return err
+}
+
+// ==================== 版本对比 ====================
+
+// FieldChange 字段变化
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from,omitempty"`
+	To    interface{} `json:"to,omitempty"`
+}
+
+// StepDiff 步骤级差异，from_index/to_index 为步骤序号（从 1 开始，0 表示不存在）
+type StepDiff struct {
+	Op        string        `json:"op"` // equal / added / removed / changed
+	FromIndex int           `json:"from_index,omitempty"`
+	ToIndex   int           `json:"to_index,omitempty"`
+	Action    string        `json:"action"`
+	From      *TraceStep    `json:"from,omitempty"`
+	To        *TraceStep    `json:"to,omitempty"`
+	Changes   []FieldChange `json:"changes,omitempty"`
+}
+
+// TraceDiff 两个版本的差异
+type TraceDiff struct {
+	From    int            `json:"from"`
+	To      int            `json:"to"`
+	Header  []FieldChange  `json:"header,omitempty"` // 名称、URL、模式等轨迹级字段的变化
+	Steps   []StepDiff     `json:"steps"`
+	Summary map[string]int `json:"summary"`
+}
+
+// diffTraces 对比两个轨迹：步骤按最长公共子序列对齐，相邻的删除和新增若动作相同则合并为修改
+func diffTraces(from, to *TraceFile) *TraceDiff {
+	fromHeader, toHeader := *from, *to
+	fromHeader.Steps, toHeader.Steps = nil, nil
+	diff := &TraceDiff{
+		Header:  fieldChanges(fromHeader, toHeader),
+		Steps:   diffSteps(from.Steps, to.Steps),
+		Summary: map[string]int{"equal": 0, "added": 0, "removed": 0, "changed": 0},
+	}
+	for _, d := range diff.Steps {
+		diff.Summary[d.Op]++
+	}
+	return diff
+}
+
+func diffSteps(a, b []TraceStep) []StepDiff {
+	keys := func(steps []TraceStep) []string {
+		out := make([]string, len(steps))
+		for i, s := range steps {
+			data, _ := json.Marshal(s)
+			out[i] = string(data)
+		}
+		return out
+	}
+	ka, kb := keys(a), keys(b)
+
+	// lcs[i][j] 为 a[i:] 与 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if ka[i] == kb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var result []StepDiff
+	var removed, added []int
+	// flush 把一段连续的删除/新增输出，按位置配对动作相同的步骤为修改
+	flush := func() {
+		n := len(removed)
+		if len(added) < n {
+			n = len(added)
+		}
+		for k := 0; k < len(removed) || k < len(added); k++ {
+			switch {
+			case k < n && a[removed[k]].Action == b[added[k]].Action:
+				result = append(result, StepDiff{
+					Op: "changed", FromIndex: removed[k] + 1, ToIndex: added[k] + 1, Action: b[added[k]].Action,
+					From: &a[removed[k]], To: &b[added[k]], Changes: fieldChanges(a[removed[k]], b[added[k]]),
+				})
+			default:
+				if k < len(removed) {
+					result = append(result, StepDiff{Op: "removed", FromIndex: removed[k] + 1, Action: a[removed[k]].Action, From: &a[removed[k]]})
+				}
+				if k < len(added) {
+					result = append(result, StepDiff{Op: "added", ToIndex: added[k] + 1, Action: b[added[k]].Action, To: &b[added[k]]})
+				}
+			}
+		}
+		removed, added = nil, nil
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && ka[i] == kb[j]:
+			flush()
+			result = append(result, StepDiff{Op: "equal", FromIndex: i + 1, ToIndex: j + 1, Action: a[i].Action})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			added = append(added, j)
+			j++
+		default:
+			removed = append(removed, i)
+			i++
+		}
+	}
+	flush()
+	return result
+}
+
+// fieldChanges 按 JSON 字段对比两个值
+func fieldChanges(from, to interface{}) []FieldChange {
+	toMap := func(v interface{}) map[string]interface{} {
+		data, _ := json.Marshal(v)
+		m := map[string]interface{}{}
+		json.Unmarshal(data, &m)
+		return m
+	}
+	a, b := toMap(from), toMap(to)
+
+	fields := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		fields = append(fields, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+
+	var changes []FieldChange
+	for _, f := range fields {
+		if !reflect.DeepEqual(a[f], b[f]) {
+			changes = append(changes, FieldChange{Field: f, From: a[f], To: b[f]})
+		}
+	}
+	return changes
+}
+
+// ==================== 接口 ====================
+
+// handleTraceVersions 轨迹版本接口：
+//
+//	GET  /api/traces/{id}/versions              版本列表
+//	GET  /api/traces/{id}/versions/{version}    版本内容
+//	GET  /api/traces/{id}/diff?from=1&to=2      版本对比（to 默认为生效版本，from 默认为 to 的上一版本）
+//	POST /api/traces/{id}/rollback              {"version": 2} 校验通过后设为生效版本
+func handleTraceVersions(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/traces/"), "/"), "/")
+	traceID, err := parseInt(parts[0])
+	if err != nil || len(parts) < 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case parts[1] == "versions" && len(parts) == 2 && r.Method == "GET":
+		versions, err := getTraceVersions(traceID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": versions})
+
+	case parts[1] == "versions" && len(parts) == 3 && r.Method == "GET":
+		version, err := parseInt(parts[2])
+		if err != nil {
+			http.Error(w, "版本号无效", http.StatusBadRequest)
+			return
+		}
+		v, err := getTraceVersion(traceID, version)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": v})
+
+	case parts[1] == "diff" && r.Method == "GET":
+		handleTraceDiff(w, r, traceID)
+
+	case parts[1] == "export" && r.Method == "GET":
+		handleTraceExport(w, r, traceID)
+
+	case parts[1] == "steps":
+		handleTraceSteps(w, r, traceID, parts[2:])
+
+	case parts[1] == "session" && len(parts) == 2 && r.Method == "POST":
+		handleTraceSessionCreate(w, r, traceID)
+
+	case parts[1] == "rollback" && r.Method == "POST":
+		var req struct {
+			Version int `json:"version"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Version <= 0 {
+			http.Error(w, "缺少 version", http.StatusBadRequest)
+			return
+		}
+		issues, err := activateCheckedTraceVersion(traceID, req.Version)
+		if issues != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": err.Error(), "issues": issues})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("⏪ 轨迹 %d 已回滚到版本 %d", traceID, req.Version)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": map[string]int{"trace_id": traceID, "active_version": req.Version}})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func handleTraceDiff(w http.ResponseWriter, r *http.Request, traceID int) {
+	q := r.URL.Query()
+	to, err := parseInt(q.Get("to"))
+	if err != nil {
+		if db.QueryRow("SELECT COALESCE(active_version, 0) FROM traces WHERE id = ?", traceID).Scan(&to) != nil {
+			http.Error(w, "轨迹不存在", http.StatusNotFound)
+			return
+		}
+	}
+	from, err := parseInt(q.Get("from"))
+	if err != nil {
+		from = to - 1
+	}
+
+	var traces [2]*TraceFile
+	for i, version := range []int{from, to} {
+		v, err := getTraceVersion(traceID, version)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if traces[i], err = parseTraceFile(v.RawContent); err != nil {
+			http.Error(w, fmt.Sprintf("版本 %d 解析失败: %v", version, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	diff := diffTraces(traces[0], traces[1])
+	diff.From, diff.To = from, to
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": diff})
+}
+
+// ==================== 导出 ====================
+
+// handleTraceExport 导出轨迹为 Chrome DevTools Recorder 录制（默认导出生效版本），
+// keyword 参数用于渲染 {{.Keyword}}，便于在 Recorder 中直接回放
+func handleTraceExport(w http.ResponseWriter, r *http.Request, traceID int) {
+	q := r.URL.Query()
+	if format := q.Get("format"); format != "" && format != "chrome" {
+		http.Error(w, "不支持的导出格式: "+format, http.StatusBadRequest)
+		return
+	}
+	version, err := parseInt(q.Get("version"))
+	if err != nil {
+		if db.QueryRow("SELECT COALESCE(active_version, 0) FROM traces WHERE id = ?", traceID).Scan(&version) != nil {
+			http.Error(w, "轨迹不存在", http.StatusNotFound)
+			return
+		}
+	}
+	v, err := getTraceVersion(traceID, version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	trace, err := parseTraceFile(v.RawContent)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("版本 %d 解析失败: %v", version, err), http.StatusBadRequest)
+		return
+	}
+
+	params := map[string]string{"Keyword": q.Get("keyword"), "URL": trace.URL}
+	recording, warnings := traceconv.ToRecording(trace, func(s string) string { return replaceParams(s, params) })
+	for _, warning := range warnings {
+		log.Printf("⚠️ 导出轨迹 %d 版本 %d: %s", traceID, version, warning)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=trace-%d-v%d.json", traceID, version))
+	w.Header().Set("X-Export-Warnings", fmt.Sprintf("%d", len(warnings)))
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(recording)
+}
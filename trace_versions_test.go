@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestUploadAndRollbackShareLintPolicy 上传与回滚使用同一校验规则：
+// 有错误级问题的上传不保存，因此也不会出现生效过却无法回滚的版本
+func TestUploadAndRollbackShareLintPolicy(t *testing.T) {
+	setupTestDB(t)
+
+	valid, err := os.ReadFile("traces/guangdong_list.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken := strings.Replace(string(valid), `"selector": "button.el-button--primary"`, `"selector": ""`, 1)
+	if broken == string(valid) {
+		t.Fatal("构造错误轨迹失败")
+	}
+
+	upload := func(raw string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{"raw_content": raw, "source_id": 1, "type": "list"})
+		w := httptest.NewRecorder()
+		handleTraces(w, httptest.NewRequest("POST", "/api/traces", strings.NewReader(string(body))))
+		return w
+	}
+	rollback := func(traceID, version int) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handleTraceVersions(w, httptest.NewRequest("POST", fmt.Sprintf("/api/traces/%d/rollback", traceID),
+			strings.NewReader(fmt.Sprintf(`{"version": %d}`, version))))
+		return w
+	}
+	activeVersion := func(traceID int) int {
+		var v int
+		db.QueryRow("SELECT active_version FROM traces WHERE id = ?", traceID).Scan(&v)
+		return v
+	}
+
+	w := upload(string(valid))
+	if w.Code != http.StatusOK {
+		t.Fatalf("上传正常轨迹: %d %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Data struct {
+			TraceID int `json:"trace_id"`
+			Version int `json:"version"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	traceID := resp.Data.TraceID
+
+	// 有错误的上传被拒绝，不产生新版本
+	if w := upload(broken); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("上传错误轨迹应返回 422，实际 %d %s", w.Code, w.Body)
+	}
+	if w := rollback(traceID, resp.Data.Version+1); w.Code != http.StatusBadRequest {
+		t.Errorf("回滚到被拒绝的上传应失败，实际 %d %s", w.Code, w.Body)
+	}
+	if v := activeVersion(traceID); v != resp.Data.Version {
+		t.Errorf("生效版本 = %d, 期望 %d", v, resp.Data.Version)
+	}
+
+	// 未启用的草稿版本存在错误时同样不能通过回滚启用
+	_, draft, err := saveTraceDraft(1, "list", "草稿", broken, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := rollback(traceID, draft); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("回滚到错误版本应返回 422，实际 %d %s", w.Code, w.Body)
+	}
+	if v := activeVersion(traceID); v != resp.Data.Version {
+		t.Errorf("生效版本 = %d, 期望 %d", v, resp.Data.Version)
+	}
+
+	// 曾经生效的版本始终可以回滚
+	if w := rollback(traceID, resp.Data.Version); w.Code != http.StatusOK {
+		t.Errorf("回滚到正常版本: %d %s", w.Code, w.Body)
+	}
+}
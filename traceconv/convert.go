@@ -0,0 +1,465 @@
+package traceconv
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ==================== 录制转换 ====================
+
+// DefaultMaxPages 列表翻页默认最多提取的页数
+const DefaultMaxPages = 5
+
+// ParseRecording 解析 Chrome DevTools Recorder 导出的 JSON
+func ParseRecording(data []byte) (*ChromeRecording, error) {
+	var rec ChromeRecording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("无法解析JSON: %v", err)
+	}
+	if len(rec.Steps) == 0 {
+		return nil, fmt.Errorf("录制中没有步骤")
+	}
+	return &rec, nil
+}
+
+// InferType 根据录制的标题和 URL 推断轨迹类型
+func InferType(rec *ChromeRecording) string {
+	if strings.Contains(rec.URL, "detail") || strings.Contains(rec.Title, "详情") {
+		return "detail"
+	}
+	return "list"
+}
+
+// Convert 把录制转换为轨迹，traceType 为空时自动推断
+func Convert(rec *ChromeRecording, traceType string) *TraceFile {
+	if traceType == "" {
+		traceType = InferType(rec)
+	}
+	trace := &TraceFile{
+		Name:  rec.Title,
+		Type:  traceType,
+		URL:   rec.URL,
+		Steps: convertSteps(rec.Steps, traceType),
+	}
+	if trace.URL == "" {
+		for _, step := range rec.Steps {
+			if step.Type == "navigate" {
+				trace.URL = step.URL
+				break
+			}
+		}
+	}
+	return trace
+}
+
+// scrollValue 录制滚动步骤对应的 scroll value：有位置时为 "x,y"，否则页面滚动到底部、元素滚动到可见
+func scrollValue(step ChromeStep, selector string) string {
+	if step.X != 0 || step.Y != 0 {
+		return fmt.Sprintf("%d,%d", step.X, step.Y)
+	}
+	if selector == "" {
+		return "bottom"
+	}
+	return ""
+}
+
+// intermediateStep 中间步骤：合并输入、过滤无用步骤后的操作序列
+type intermediateStep struct {
+	Type       string
+	Selector   string
+	Candidates []SelectorCandidate
+	Value      string
+	URL        string
+}
+
+// convertSteps 转换录制步骤（保守策略：保留为主，删除为辅）
+func convertSteps(chromeSteps []ChromeStep, traceType string) []TraceStep {
+	var intermediate []intermediateStep
+	var listSelector, nextButton string
+	var fields listFields
+	hasListRow := false
+
+	// 同一输入框的多次 change 事件只保留最后的值，按首次出现的顺序输出
+	var pendingOrder []string
+	pendingChanges := make(map[string]string)
+	pendingCandidates := make(map[string][]SelectorCandidate)
+	flushPendingChanges := func() {
+		for _, selector := range pendingOrder {
+			if value := pendingChanges[selector]; value != "" {
+				intermediate = append(intermediate, intermediateStep{
+					Type:       "input",
+					Selector:   selector,
+					Candidates: pendingCandidates[selector],
+					Value:      value,
+				})
+			}
+		}
+		pendingOrder = nil
+		pendingChanges = make(map[string]string)
+		pendingCandidates = make(map[string][]SelectorCandidate)
+	}
+
+	// 第一遍：检测列表结构和翻页按钮
+	for _, step := range chromeSteps {
+		if step.Type != "click" {
+			continue
+		}
+		selector := bestSelector(step.Selectors)
+		if isPaginationClick(selector) {
+			if nextButton == "" {
+				nextButton = selector
+				log.Printf("🔍 检测到翻页按钮: selector=%s", selector)
+			}
+			continue
+		}
+		// 检测列表行点击（无论是否导致页面跳转）
+		if !hasListRow && isListRowClick(selector) {
+			hasListRow = true
+			listSelector = inferListSelector(selector)
+			fields = inferListFields(step.Selectors)
+			log.Printf("🔍 检测到列表行点击: selector=%s", selector)
+		}
+	}
+
+	// 第二遍：转换步骤
+	currentTarget := "main"
+	var currentFrame []string
+steps:
+	for i, step := range chromeSteps {
+		// 只跳过明确无用的步骤
+		if isNoiseStep(step) {
+			continue
+		}
+
+		// 标签页或 iframe 发生切换
+		if target := firstNonEmpty(step.Target, "main"); target != currentTarget && step.Type != "close" {
+			flushPendingChanges()
+			value := "last"
+			if target == "main" {
+				value = "main"
+			}
+			intermediate = append(intermediate, intermediateStep{Type: "switch_tab", Value: value})
+			currentTarget, currentFrame = target, nil
+		}
+		if frame := step.framePath(); step.Type != "close" && !sameFrame(frame, currentFrame) {
+			flushPendingChanges()
+			if len(currentFrame) > 0 {
+				intermediate = append(intermediate, intermediateStep{Type: "switch_frame", Value: "main"})
+			}
+			for _, level := range frame {
+				// 下标路径按录制的 frame 层级定位，选择器路径逐层进入 iframe 元素
+				if IsFrameIndexPath(level) {
+					intermediate = append(intermediate, intermediateStep{Type: "switch_frame", Value: level})
+					continue
+				}
+				intermediate = append(intermediate, intermediateStep{
+					Type:     "switch_frame",
+					Selector: level,
+				})
+			}
+			currentFrame = frame
+		}
+
+		switch step.Type {
+		case "navigate":
+			flushPendingChanges()
+			intermediate = append(intermediate, intermediateStep{
+				Type: "navigate",
+				URL:  step.URL,
+			})
+
+		case "click":
+			selector := bestSelector(step.Selectors)
+			if selector == "" {
+				continue
+			}
+
+			// 翻页按钮转为 extract 的翻页配置
+			if selector == nextButton {
+				continue
+			}
+
+			// 导致页面跳转的列表行点击：列表轨迹到此为止（后面是详情页操作），其他轨迹跳过该点击
+			if isListRowClick(selector) && navigatesAt(chromeSteps, i) {
+				if traceType == "list" {
+					break steps
+				}
+				continue
+			}
+
+			// 后面紧跟同一元素的 change 事件时跳过点击（change 会被转为 input）
+			skipClick := false
+			for j := i + 1; j < len(chromeSteps); j++ {
+				futureStep := chromeSteps[j]
+				if isNoiseStep(futureStep) {
+					continue
+				}
+				if futureStep.Type == "change" && bestSelector(futureStep.Selectors) == selector {
+					skipClick = true
+				}
+				// 只检查紧接着的有效步骤
+				break
+			}
+
+			if !skipClick {
+				flushPendingChanges()
+				intermediate = append(intermediate, intermediateStep{
+					Type:       "click",
+					Selector:   selector,
+					Candidates: candidateSelectors(step.Selectors, selector),
+				})
+			}
+
+		case "change":
+			selector := bestSelector(step.Selectors)
+			if selector == "" {
+				continue
+			}
+			if isSelectElement(selector) {
+				flushPendingChanges()
+				intermediate = append(intermediate, intermediateStep{
+					Type:       "select",
+					Selector:   selector,
+					Candidates: candidateSelectors(step.Selectors, selector),
+					Value:      step.Value,
+				})
+				continue
+			}
+			if _, ok := pendingChanges[selector]; !ok {
+				pendingOrder = append(pendingOrder, selector)
+			}
+			pendingChanges[selector] = step.Value
+			pendingCandidates[selector] = candidateSelectors(step.Selectors, selector)
+
+		case "keyDown":
+			// 只保留功能键，普通字符已包含在 change 事件中
+			flushPendingChanges()
+			intermediate = append(intermediate, intermediateStep{
+				Type:  "press",
+				Value: step.Key,
+			})
+
+		case "hover":
+			selector := bestSelector(step.Selectors)
+			if selector == "" {
+				continue
+			}
+			flushPendingChanges()
+			intermediate = append(intermediate, intermediateStep{
+				Type:       "hover",
+				Selector:   selector,
+				Candidates: candidateSelectors(step.Selectors, selector),
+			})
+
+		case "scroll":
+			// 录制的 x/y 是滚动到的位置，连续滚动合并为一次，取最后的位置；没有位置时滚动到底部（或滚动到元素）
+			selector := bestSelector(step.Selectors)
+			value := scrollValue(step, selector)
+			if n := len(intermediate); len(pendingOrder) == 0 && n > 0 &&
+				intermediate[n-1].Type == "scroll" && intermediate[n-1].Selector == selector {
+				intermediate[n-1].Value = value
+				continue
+			}
+			flushPendingChanges()
+			intermediate = append(intermediate, intermediateStep{Type: "scroll", Selector: selector, Candidates: candidateSelectors(step.Selectors, selector), Value: value})
+
+		case "waitForElement":
+			selector := bestSelector(step.Selectors)
+			if selector == "" {
+				continue
+			}
+			flushPendingChanges()
+			intermediate = append(intermediate, intermediateStep{
+				Type:       "waitForElement",
+				Selector:   selector,
+				Candidates: candidateSelectors(step.Selectors, selector),
+			})
+
+		case "close":
+			if currentTarget != "main" {
+				flushPendingChanges()
+				intermediate = append(intermediate, intermediateStep{Type: "close_tab"})
+				currentTarget, currentFrame = "main", nil
+			}
+		}
+	}
+
+	flushPendingChanges()
+
+	result := buildSteps(intermediate)
+
+	// 自动添加数据提取步骤
+	if traceType == "list" {
+		if !hasListRow {
+			listSelector = "tbody tr"
+			fields = defaultListFields()
+		}
+		extract := TraceStep{
+			Action:   "extract",
+			Type:     "list",
+			Selector: listSelector,
+			Fields: map[string]string{
+				"title": fields.title,
+				"date":  fields.date,
+				"url":   fields.url,
+			},
+		}
+		if nextButton != "" {
+			extract.Pagination = &Pagination{NextButton: nextButton, MaxPages: DefaultMaxPages}
+		}
+		result = append(result, extract)
+		log.Printf("📊 生成 extract 步骤: selector=%s, fields=%+v", listSelector, extract.Fields)
+	} else if traceType == "detail" {
+		result = append(result, TraceStep{
+			Action: "extract",
+			Type:   "detail",
+			Fields: map[string]string{
+				"amount":  "span:contains('预算金额')",
+				"contact": "span:contains('联系人')",
+				"phone":   "span:contains('联系电话')",
+			},
+		})
+	}
+
+	return result
+}
+
+// navigatesAt 第 i 步是否导致页面跳转：录制了 navigation 事件，或下一个有效步骤是 navigate
+func navigatesAt(steps []ChromeStep, i int) bool {
+	if steps[i].navigates() {
+		return true
+	}
+	for j := i + 1; j < len(steps); j++ {
+		if isNoiseStep(steps[j]) {
+			continue
+		}
+		return steps[j].Type == "navigate"
+	}
+	return false
+}
+
+// buildSteps 由中间步骤生成最终步骤：补充等待、识别关键词输入和验证码
+func buildSteps(intermediate []intermediateStep) []TraceStep {
+	var result []TraceStep
+
+	for i, step := range intermediate {
+		switch step.Type {
+		case "navigate":
+			result = append(result, TraceStep{
+				Action: "navigate",
+				URL:    step.URL,
+			})
+			result = append(result, TraceStep{
+				Action:   "wait",
+				WaitTime: 2000,
+			})
+
+		case "click":
+			// 修正查询按钮选择器（去掉 > span）
+			selector := fixSearchButtonSelector(step.Selector)
+			result = append(result, TraceStep{
+				Action:     "click",
+				Selector:   selector,
+				Candidates: step.Candidates,
+			})
+			// 点击后的等待时间
+			waitTime := 2000 // 默认2秒，足够动画和元素加载
+			if isSearchButton(selector) {
+				waitTime = 3000 // 查询按钮等待3秒
+			}
+			result = append(result, TraceStep{
+				Action:   "wait",
+				WaitTime: waitTime,
+			})
+
+		case "input":
+			if !isCaptchaInput(step.Selector, step.Value) {
+				value := step.Value
+				// 智能识别关键词输入框
+				if isKeywordInput(step.Selector) {
+					value = "{{.Keyword}}"
+				}
+				result = append(result, TraceStep{
+					Action:     "input",
+					Selector:   step.Selector,
+					Candidates: step.Candidates,
+					Value:      value,
+				})
+				continue
+			}
+
+			// 验证码图片通常是输入前点击过的图片（点击刷新），该点击不再保留
+			imgSelector := ""
+			for j := i - 1; j >= 0; j-- {
+				if intermediate[j].Type != "click" {
+					continue
+				}
+				sel := intermediate[j].Selector
+				if strings.Contains(sel, "img") || strings.Contains(sel, "captcha") || strings.Contains(sel, "验证码") {
+					imgSelector = sel
+					if n := len(result); n >= 2 && result[n-2].Action == "click" && result[n-2].Selector == fixSearchButtonSelector(sel) {
+						result = result[:n-2]
+					}
+					break
+				}
+			}
+			if imgSelector == "" {
+				imgSelector = "img[src*='captcha'], img[alt*='验证码'], img[title*='验证码']"
+				log.Printf("⚠️ 未找到验证码图片选择器，使用通用选择器")
+			}
+
+			result = append(result, TraceStep{
+				Action:        "captcha",
+				ImageSelector: imgSelector,
+				InputSelector: step.Selector,
+			})
+			// 验证码输入后添加等待，让页面响应
+			result = append(result, TraceStep{
+				Action:   "wait",
+				WaitTime: 2000,
+			})
+
+		case "press":
+			result = append(result, TraceStep{
+				Action: "press",
+				Value:  step.Value,
+			})
+			// 回车通常会提交查询
+			if strings.EqualFold(step.Value, "Enter") {
+				result = append(result, TraceStep{
+					Action:   "wait",
+					WaitTime: 3000,
+				})
+			}
+
+		case "waitForElement":
+			result = append(result, TraceStep{
+				Action:         "wait",
+				WaitForVisible: step.Selector,
+				Candidates:     step.Candidates,
+			})
+
+		case "select", "hover", "scroll", "switch_tab", "close_tab", "switch_frame":
+			result = append(result, TraceStep{
+				Action:     step.Type,
+				Selector:   step.Selector,
+				Candidates: step.Candidates,
+				Value:      step.Value,
+			})
+		}
+	}
+
+	return result
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
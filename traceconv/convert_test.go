@@ -0,0 +1,54 @@
+package traceconv
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "用当前转换结果覆盖 testdata 中的 golden 文件")
+
+// goldenRecordings testdata 中的录制，对应 traces/ 下的四条轨迹。原始录制没有随仓库保存，
+// 按 Chrome Recorder 的导出格式（setViewport、多组备选选择器、输入法拼音过程等）在相同页面上重新整理
+var goldenRecordings = []string{"guangdong_list", "guangdong_detail", "shandong_list", "shandong_detail"}
+
+// TestConvertGolden 录制转换结果与 golden 文件一致，修改转换规则后用 go test ./traceconv -update 更新
+func TestConvertGolden(t *testing.T) {
+	for _, name := range goldenRecordings {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", name+".recording.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec, format, err := ParseAnyRecording(data)
+			if err != nil {
+				t.Fatalf("解析录制失败: %v", err)
+			}
+			if format != FormatChrome {
+				t.Fatalf("录制格式识别为 %q", format)
+			}
+			got, err := json.MarshalIndent(Convert(rec, ""), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join("testdata", name+".golden.json")
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("读取 golden 文件失败（首次运行请加 -update）: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("转换结果与 %s 不一致:\n%s", golden, got)
+			}
+		})
+	}
+}
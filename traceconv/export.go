@@ -0,0 +1,191 @@
+package traceconv
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ==================== 导出为 Chrome 录制 ====================
+
+// scrollBottomOffset 滚动到底部时使用的纵向距离
+const scrollBottomOffset = 100000
+
+var (
+	textPseudoPattern = regexp.MustCompile(`:(?:contains|text)\((['"])(.*?)['"]\)`)
+	frameIndexPattern = regexp.MustCompile(`^iframe:nth-of-type\((\d+)\)$`)
+)
+
+// ToRecording 把轨迹转换为 Chrome DevTools Recorder 录制，便于在浏览器中回放调试。
+// render 用于渲染步骤中的参数模板（如 {{.Keyword}}），为 nil 时保留原样。
+// Recorder 无法表达的步骤（固定等待、验证码、变量、标签页切换等）会被跳过或简化，说明在返回的提示中
+func ToRecording(trace *TraceFile, render func(string) string) (*ChromeRecording, []string) {
+	if render == nil {
+		render = func(s string) string { return s }
+	}
+	e := &exporter{render: render}
+	e.rec = &ChromeRecording{Title: trace.Name, URL: render(trace.URL)}
+	e.steps(trace.Steps, "")
+	return e.rec, e.warnings
+}
+
+// exporter 导出状态
+type exporter struct {
+	rec      *ChromeRecording
+	render   func(string) string
+	frame    []int
+	warnings []string
+}
+
+func (e *exporter) warn(path, format string, args ...interface{}) {
+	e.warnings = append(e.warnings, fmt.Sprintf("步骤 %s: %s", path, fmt.Sprintf(format, args...)))
+}
+
+func (e *exporter) add(step ChromeStep) {
+	step.Target = "main"
+	step.Frame = append([]int(nil), e.frame...)
+	e.rec.Steps = append(e.rec.Steps, step)
+}
+
+// addSelectorStep 添加需要选择器的步骤，候选选择器作为 Recorder 的备选；选择器无法导出时跳过
+func (e *exporter) addSelectorStep(path string, step ChromeStep, selector string, candidates ...SelectorCandidate) {
+	selectors := chromeSelectors(e.render(selector))
+	for _, c := range candidates {
+		selectors = append(selectors, chromeSelectors(e.render(c.Selector))...)
+	}
+	if len(selectors) == 0 {
+		e.warn(path, "选择器 %q 无法在 Recorder 中使用，已跳过", selector)
+		return
+	}
+	step.Selectors = selectors
+	e.add(step)
+}
+
+func (e *exporter) steps(steps []TraceStep, prefix string) {
+	for i, step := range steps {
+		path := fmt.Sprintf("%s%d", prefix, i+1)
+		switch step.Action {
+		case "navigate":
+			url := e.render(step.URL)
+			e.add(ChromeStep{Type: "navigate", URL: url, AssertedEvents: []AssertedEvent{{Type: "navigation", URL: url}}})
+
+		case "click":
+			e.addSelectorStep(path, ChromeStep{Type: "click", OffsetX: 1, OffsetY: 1}, step.Selector, step.Candidates...)
+
+		case "input":
+			e.addSelectorStep(path, ChromeStep{Type: "change", Value: e.render(step.Value)}, step.Selector, step.Candidates...)
+
+		case "select":
+			// Recorder 按选项 value 选择，轨迹中按文本匹配的选项需要手动改为 value
+			e.addSelectorStep(path, ChromeStep{Type: "change", Value: e.render(step.Value)}, step.Selector, step.Candidates...)
+
+		case "hover":
+			e.addSelectorStep(path, ChromeStep{Type: "hover"}, step.Selector, step.Candidates...)
+
+		case "press":
+			key := e.render(step.Value)
+			if step.Selector != "" {
+				e.addSelectorStep(path, ChromeStep{Type: "click", OffsetX: 1, OffsetY: 1}, step.Selector, step.Candidates...)
+			}
+			e.add(ChromeStep{Type: "keyDown", Key: key})
+			e.add(ChromeStep{Type: "keyUp", Key: key})
+
+		case "scroll":
+			scroll := ChromeStep{Type: "scroll"}
+			switch value := e.render(step.Value); value {
+			case "top":
+			case "", "bottom":
+				scroll.Y = scrollBottomOffset
+			default:
+				if x, y, ok := ParseScrollPosition(value); ok {
+					scroll.X, scroll.Y = x, y
+				} else {
+					scroll.Y, _ = strconv.Atoi(value)
+				}
+			}
+			if step.Selector != "" && step.MaxItems == 0 {
+				e.addSelectorStep(path, scroll, step.Selector, step.Candidates...)
+			} else {
+				e.add(scroll)
+			}
+
+		case "wait":
+			if step.WaitForVisible != "" {
+				e.addSelectorStep(path, ChromeStep{Type: "waitForElement"}, step.WaitForVisible, step.Candidates...)
+			}
+
+		case "captcha":
+			e.addSelectorStep(path, ChromeStep{Type: "waitForElement"}, step.ImageSelector)
+			e.warn(path, "验证码无法自动回放，回放到此处时请手动输入")
+
+		case "extract":
+			// 等待列表出现，回放时可以直观确认列表选择器；翻页时点击一次下一页
+			if step.Selector != "" {
+				e.addSelectorStep(path, ChromeStep{Type: "waitForElement"}, step.Selector)
+			}
+			if step.Pagination != nil && step.Pagination.NextButton != "" {
+				e.addSelectorStep(path, ChromeStep{Type: "click", OffsetX: 1, OffsetY: 1}, step.Pagination.NextButton)
+				if step.Selector != "" {
+					e.addSelectorStep(path, ChromeStep{Type: "waitForElement"}, step.Selector)
+				}
+			}
+
+		case "switch_frame":
+			// 下标路径没有选择器，需先于回到顶层页面的判断处理
+			if frame, ok := ParseFrameIndexPath(step.Value); ok && step.Selector == "" {
+				e.frame = append(e.frame, frame...)
+				continue
+			}
+			if step.Value == "main" || (step.Selector == "" && step.Value == "") {
+				e.frame = nil
+				continue
+			}
+			idx := 0
+			if m := frameIndexPattern.FindStringSubmatch(step.Selector); m != nil {
+				idx, _ = strconv.Atoi(m[1])
+				idx--
+			} else {
+				e.warn(path, "Recorder 只能按下标定位 iframe，%q 按第一个 iframe 导出", step.Selector)
+			}
+			e.frame = append(e.frame, idx)
+
+		case "if":
+			e.warn(path, "if 条件无法导出，按条件成立导出子步骤")
+			e.steps(step.Steps, path+".steps.")
+
+		case "foreach":
+			e.warn(path, "foreach 循环无法导出，只导出一次子步骤")
+			e.steps(step.Steps, path+".steps.")
+
+		case "switch_tab", "close_tab":
+			e.warn(path, "%s 无法导出，后续步骤仍在原标签页回放", step.Action)
+
+		case "set", "eval", "screenshot":
+			e.warn(path, "%s 步骤没有对应的 Recorder 步骤，已跳过", step.Action)
+		}
+	}
+}
+
+// chromeSelectors 运行时选择器转为 Recorder 的候选选择器：
+// text= 转为 text/，xpath: 转为 xpath/，含 :contains() 扩展伪类的 CSS 转为 text/
+func chromeSelectors(sel string) [][]string {
+	sel = strings.TrimSpace(sel)
+	switch {
+	case sel == "", strings.HasPrefix(sel, "regex="):
+		return nil
+	case strings.HasPrefix(sel, "text="):
+		return [][]string{{"text/" + strings.TrimPrefix(sel, "text=")}}
+	case strings.HasPrefix(sel, "xpath:"):
+		return [][]string{{"xpath/" + strings.TrimPrefix(sel, "xpath:")}}
+	case strings.HasPrefix(sel, "text/"), strings.HasPrefix(sel, "xpath/"),
+		strings.HasPrefix(sel, "aria/"), strings.HasPrefix(sel, "pierce/"):
+		return [][]string{{sel}}
+	}
+
+	// 去掉伪类后的 CSS 会匹配到其他元素，只保留文本选择器
+	if matches := textPseudoPattern.FindAllStringSubmatch(sel, -1); len(matches) > 0 {
+		return [][]string{{"text/" + matches[len(matches)-1][2]}}
+	}
+	return [][]string{{sel}}
+}
@@ -0,0 +1,53 @@
+package traceconv
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestExportFrameIndexPathRoundTrip 嵌套 iframe 中的步骤经过转换和导出后保留原来的 frame 下标路径
+func TestExportFrameIndexPathRoundTrip(t *testing.T) {
+	data := []byte(`{
+		"title": "嵌套 iframe",
+		"steps": [
+			{"type": "navigate", "url": "https://example.com/", "assertedEvents": [{"type": "navigation", "url": "https://example.com/"}]},
+			{"type": "click", "target": "main", "frame": [0, 1], "selectors": [["#keyword"]], "offsetX": 1, "offsetY": 1},
+			{"type": "change", "target": "main", "frame": [0, 1], "selectors": [["#keyword"]], "value": "视频监控"},
+			{"type": "click", "target": "main", "selectors": [["#search"]], "offsetX": 1, "offsetY": 1}
+		]
+	}`)
+	rec, _, err := ParseAnyRecording(data)
+	if err != nil {
+		t.Fatalf("解析录制失败: %v", err)
+	}
+	trace := Convert(rec, "视频监控")
+
+	var frameSteps []string
+	for _, step := range trace.Steps {
+		if step.Action == "switch_frame" {
+			frameSteps = append(frameSteps, step.Value)
+		}
+	}
+	if want := []string{"0.1", "main"}; !reflect.DeepEqual(frameSteps, want) {
+		t.Fatalf("switch_frame 步骤 = %v, 期望 %v", frameSteps, want)
+	}
+
+	exported, warnings := ToRecording(trace, nil)
+	for _, w := range warnings {
+		t.Errorf("不应有导出提示: %s", w)
+	}
+	frames := map[string][]int{}
+	for _, step := range exported.Steps {
+		if len(step.Selectors) > 0 {
+			frames[step.Type+" "+step.Selectors[0][0]] = append([]int{}, step.Frame...)
+		}
+	}
+	// 输入前的点击在转换时合并到 input 步骤中
+	want := map[string][]int{
+		"change #keyword": {0, 1},
+		"click #search":   {},
+	}
+	if !reflect.DeepEqual(frames, want) {
+		t.Errorf("导出步骤的 frame = %v, 期望 %v", frames, want)
+	}
+}
@@ -0,0 +1,275 @@
+package traceconv
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// ==================== 识别规则 ====================
+
+// dynamicIDPrefixes 组件库自动生成的 ID 前缀（Element UI、Material UI、React 等），每次渲染都会变化
+var dynamicIDPrefixes = []string{"el-id-", "mui-", "rc-", "headlessui-"}
+
+// pressKeyNames 转换为 press 步骤的按键，其余 keyDown 是普通字符输入
+var pressKeyNames = map[string]bool{
+	"enter": true, "tab": true, "escape": true,
+}
+
+var (
+	selectTagPattern  = regexp.MustCompile(`(^|[\s>+~,/])select([#.\[:\s]|$)`)
+	tdNthColumn       = regexp.MustCompile(`td:nth-(?:of-type|child)\((\d+)\)`)
+	xpathTdIndex      = regexp.MustCompile(`/td\[(\d+)\]`)
+	elTableColumn     = regexp.MustCompile(`td\.el-table_\d+_column_(\d+)`)
+	linkAfterCell     = regexp.MustCompile(`td[^/>\s]*(\s*>\s*|\s+|/)(.*[\s>/])?a([\s.:#\[>/]|$)`)
+	paginationPattern = regexp.MustCompile(`(?i)pager|paginat|btn-next|next-?page|page-?next|\bnext\b|下一?页`)
+)
+
+// isNoiseStep 转换时忽略的步骤：无用的步骤类型，以及普通字符的 keyDown
+func isNoiseStep(step ChromeStep) bool {
+	switch step.Type {
+	case "setViewport", "keyUp":
+		return true
+	case "keyDown":
+		return !pressKeyNames[strings.ToLower(step.Key)]
+	}
+	return false
+}
+
+// containsDynamicID 选择器是否包含动态生成的 ID
+func containsDynamicID(selector string) bool {
+	for _, prefix := range dynamicIDPrefixes {
+		if strings.Contains(selector, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// bestSelector 从录制的候选选择器中选出最稳定的一个：稳定 ID > CSS > XPath，
+// 都不可用时尝试从 aria 的 placeholder 生成，最后才使用可能包含动态 ID 的降级选择器
+func bestSelector(selectors [][]string) string {
+	var selectedSelector string
+	var fallbackSelector string // 降级选择器（即使是动态的）
+	var ariaPlaceholder string  // 从 aria 选择器提取的 placeholder
+	var textSelector string     // 只有文本选择器时使用（如导出后重新上传的录制）
+	var priority int            // 优先级：3=ID, 2=CSS, 1=XPath, 0=其他
+
+	for _, selectorGroup := range selectors {
+		if len(selectorGroup) == 0 {
+			continue
+		}
+		sel := selectorGroup[0]
+
+		// 提取 aria 选择器中的 placeholder 信息
+		if strings.HasPrefix(sel, "aria/") {
+			ariaText := strings.TrimPrefix(sel, "aria/")
+			if strings.Contains(ariaText, "请输入") {
+				ariaPlaceholder = ariaText
+			}
+			continue
+		}
+
+		// 跳过 text 选择器
+		if strings.HasPrefix(sel, "text/") {
+			if textSelector == "" {
+				textSelector = "text=" + strings.TrimPrefix(sel, "text/")
+			}
+			continue
+		}
+
+		sel = strings.TrimPrefix(sel, "pierce/")
+
+		// 保存第一个可用选择器作为降级选项
+		if fallbackSelector == "" {
+			fallbackSelector = sel
+		}
+		if containsDynamicID(sel) {
+			continue
+		}
+
+		// XPath 选择器
+		if strings.HasPrefix(sel, "xpath") {
+			if priority < 1 {
+				selectedSelector = sel
+				priority = 1
+			}
+			continue
+		}
+
+		// 稳定的 ID 选择器优先级最高
+		if strings.Contains(sel, "#") {
+			selectedSelector = sel
+			priority = 3
+			break
+		}
+
+		// 标准 CSS 选择器
+		if priority < 2 {
+			selectedSelector = sel
+			priority = 2
+		}
+	}
+
+	// 没有稳定的选择器时，从 aria 文本生成基于 placeholder 的选择器
+	if selectedSelector == "" && ariaPlaceholder != "" {
+		if placeholderText := strings.TrimPrefix(ariaPlaceholder, "请输入"); placeholderText != "" {
+			generatedSelector := fmt.Sprintf("input[placeholder*=\"%s\"]", placeholderText)
+			log.Printf("✨ 从 aria 生成稳定选择器: %s", generatedSelector)
+			return generatedSelector
+		}
+	}
+
+	if selectedSelector == "" && fallbackSelector != "" {
+		log.Printf("⚠️ 未找到稳定选择器，使用降级选择器: %s (可能包含动态ID，需手动验证)", fallbackSelector)
+		return fallbackSelector
+	}
+
+	if selectedSelector == "" && textSelector != "" {
+		return textSelector
+	}
+
+	return selectedSelector
+}
+
+// isListRowClick 判断是否是列表行点击
+func isListRowClick(selector string) bool {
+	patterns := []string{
+		"tr:nth-of-type", "tbody tr", "td:nth-of-type", "td.el-table",
+		"li:nth-of-type", ".list-item", ".item",
+		// Selenium IDE、Playwright 生成的 nth-child 和 XPath 下标
+		"tr:nth-child", "li:nth-child", "/tr[", "/li[", "tr:contains(",
+	}
+	for _, p := range patterns {
+		if strings.Contains(selector, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPaginationClick 判断是否是翻页按钮点击
+func isPaginationClick(selector string) bool {
+	return selector != "" && paginationPattern.MatchString(selector)
+}
+
+// inferListSelector 从行选择器推断列表容器选择器
+func inferListSelector(rowSelector string) string {
+	if (strings.Contains(rowSelector, "li:nth-") || strings.Contains(rowSelector, "/li[")) && !strings.Contains(rowSelector, "tr") {
+		return "ul li"
+	}
+	return "tbody tr"
+}
+
+// listFields 推断出的列表字段选择器
+type listFields struct {
+	title string
+	date  string
+	url   string
+}
+
+func defaultListFields() listFields {
+	return listFields{
+		title: "td:nth-child(1) span",
+		date:  "td:nth-child(3)",
+		url:   "@click:td:nth-child(1) span",
+	}
+}
+
+// inferListFields 从列表行点击的候选选择器推断字段：点击所在列作为标题，
+// 单元格内有链接时取链接地址，否则通过点击获取跳转 URL（Vue 等单页应用）
+func inferListFields(selectors [][]string) listFields {
+	for _, selectorGroup := range selectors {
+		for _, sel := range selectorGroup {
+			col := 0
+			for _, re := range []*regexp.Regexp{tdNthColumn, elTableColumn, xpathTdIndex} {
+				if m := re.FindStringSubmatch(sel); m != nil {
+					fmt.Sscanf(m[1], "%d", &col)
+					break
+				}
+			}
+			if col <= 0 {
+				continue
+			}
+
+			fields := listFields{
+				title: fmt.Sprintf("td:nth-child(%d) span", col),
+				date:  "td:nth-child(3)",
+				url:   fmt.Sprintf("@click:td:nth-child(%d) span", col),
+			}
+			// 标题在第 3 列时，日期通常在其后一列
+			if col == 3 {
+				fields.date = "td:nth-child(4)"
+			}
+			if linkAfterCell.MatchString(sel) {
+				fields.title = fmt.Sprintf("td:nth-child(%d) a", col)
+				fields.url = fmt.Sprintf("td:nth-child(%d) a@href | abs", col)
+			}
+			log.Printf("🔍 解析列字段: col=%d, selector=%s", col, sel)
+			return fields
+		}
+	}
+
+	log.Printf("⚠️ 列字段解析失败，使用默认值")
+	return defaultListFields()
+}
+
+// isSearchButton 判断是否是查询按钮
+func isSearchButton(selector string) bool {
+	return strings.Contains(selector, "button") &&
+		(strings.Contains(selector, "primary") ||
+			strings.Contains(selector, "search") ||
+			strings.Contains(selector, "查询"))
+}
+
+// fixSearchButtonSelector 修正查询按钮选择器：指向 button > span 时改为指向 button 本身
+func fixSearchButtonSelector(selector string) string {
+	if !isSearchButton(selector) {
+		return selector
+	}
+	// button.class > span:nth-of-type(1) -> button.class
+	if idx := strings.Index(selector, " > span"); idx > 0 {
+		return selector[:idx]
+	}
+	return strings.TrimSuffix(selector, "> span")
+}
+
+// isKeywordInput 判断是否是关键词输入框
+func isKeywordInput(selector string) bool {
+	keywords := []string{"标题", "关键词", "keyword", "title", "搜索", "search"}
+	selectorLower := strings.ToLower(selector)
+	for _, kw := range keywords {
+		if strings.Contains(selectorLower, kw) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCaptchaInput 判断是否是验证码输入
+func isCaptchaInput(selector string, value string) bool {
+	if strings.Contains(selector, "验证码") || strings.Contains(strings.ToLower(selector), "captcha") {
+		return true
+	}
+	// 4位数字/字母组合通常是验证码
+	return len(value) == 4 && !strings.Contains(value, " ")
+}
+
+// isSelectElement 选择器是否指向 <select> 下拉框
+func isSelectElement(selector string) bool {
+	return selectTagPattern.MatchString(selector)
+}
+
+// sameFrame 两个 iframe 路径是否相同
+func sameFrame(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+package traceconv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ==================== 录制格式识别 ====================
+
+// 支持的录制格式
+const (
+	FormatTrace      = "trace"      // 本项目的轨迹格式
+	FormatChrome     = "chrome"     // Chrome DevTools Recorder JSON
+	FormatSelenium   = "selenium"   // Selenium IDE 项目文件（.side）
+	FormatPlaywright = "playwright" // Playwright codegen 脚本（JS/TS/Python）
+	FormatPuppeteer  = "puppeteer"  // Puppeteer 脚本
+)
+
+var (
+	scriptPagePattern     = regexp.MustCompile(`\bpage\d*\s*\.\s*(goto|click|fill|type|locator|get_?[bB]y)`)
+	playwrightHintPattern = regexp.MustCompile(`playwright|\.getBy|\.get_by_|\.locator\(|\.fill\(|\.selectOption\(|\.select_option\(`)
+)
+
+// DetectFormat 识别录制内容的格式，无法识别时返回空字符串
+func DetectFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var probe struct {
+			Steps []struct {
+				Action string `json:"action"`
+				Type   string `json:"type"`
+			} `json:"steps"`
+			Tests []struct {
+				Commands []json.RawMessage `json:"commands"`
+			} `json:"tests"`
+		}
+		if err := json.Unmarshal(trimmed, &probe); err != nil {
+			return ""
+		}
+		switch {
+		case len(probe.Tests) > 0:
+			return FormatSelenium
+		case len(probe.Steps) > 0 && probe.Steps[0].Action != "":
+			return FormatTrace
+		case len(probe.Steps) > 0:
+			return FormatChrome
+		}
+		return ""
+	}
+
+	if !scriptPagePattern.Match(trimmed) {
+		return ""
+	}
+	if playwrightHintPattern.Match(trimmed) {
+		return FormatPlaywright
+	}
+	return FormatPuppeteer
+}
+
+// ParseAnyRecording 自动识别并解析录制（Chrome、Selenium IDE、Playwright、Puppeteer），
+// 统一转为 Chrome 录制结构，以便复用同一套转换规则。返回识别出的格式
+func ParseAnyRecording(data []byte) (*ChromeRecording, string, error) {
+	format := DetectFormat(data)
+	var rec *ChromeRecording
+	var err error
+	switch format {
+	case FormatChrome:
+		rec, err = ParseRecording(data)
+	case FormatSelenium:
+		rec, err = ParseSelenium(data)
+	case FormatPlaywright, FormatPuppeteer:
+		rec, err = ParseScript(data)
+	case FormatTrace:
+		return nil, format, fmt.Errorf("内容已是轨迹格式，无需转换")
+	default:
+		// 无法识别时按 Chrome 录制解析，给出原有的错误信息
+		rec, err = ParseRecording(data)
+		format = FormatChrome
+	}
+	return rec, format, err
+}
+
+// ==================== 定位器转换 ====================
+
+// locatorGroups 把导入的选择器包装为 Chrome 录制的候选选择器结构（每个候选一组）
+func locatorGroups(selectors ...string) [][]string {
+	var groups [][]string
+	seen := make(map[string]bool)
+	for _, sel := range selectors {
+		if sel == "" || seen[sel] {
+			continue
+		}
+		seen[sel] = true
+		groups = append(groups, []string{sel})
+		// aria 选择器运行时不支持，补充等价的属性选择器
+		if label := strings.TrimPrefix(sel, "aria/"); label != sel {
+			groups = append(groups, []string{fmt.Sprintf("[aria-label=%s]", cssString(label))})
+		}
+	}
+	return groups
+}
+
+// normalizeLocator 把 Chrome 风格前缀（text/、xpath/）以外的常见写法转为运行时支持的选择器
+func normalizeLocator(sel string) string {
+	sel = strings.TrimSpace(sel)
+	switch {
+	case strings.HasPrefix(sel, "text/"):
+		// bestSelector 会跳过 text/ 候选，导入时改为等价的 text= 写法
+		return "text=" + strings.TrimPrefix(sel, "text/")
+	case strings.HasPrefix(sel, "//"), strings.HasPrefix(sel, "(//"):
+		return "xpath/" + sel
+	}
+	return sel
+}
+
+// cssString 生成 CSS 属性选择器中的带引号字符串
+func cssString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// textLocator 按标签和文本生成选择器：文本不含单引号时用 :contains，否则退化为 text=
+func textLocator(tag, text string) string {
+	if tag == "" || strings.Contains(text, "'") {
+		return "text=" + text
+	}
+	return fmt.Sprintf("%s:contains('%s')", tag, text)
+}
+
+// selectLocator 下拉框选择器：不含 select 标签时补上，使转换时识别为 select 步骤
+func selectLocator(sel string) string {
+	if isSelectElement(sel) {
+		return sel
+	}
+	if strings.HasPrefix(sel, "#") || strings.HasPrefix(sel, ".") || strings.HasPrefix(sel, "[") {
+		return "select" + sel
+	}
+	return sel
+}
@@ -0,0 +1,420 @@
+package traceconv
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ==================== 轨迹校验 ====================
+
+// Schema 轨迹文件的 JSON Schema（draft-07）
+//
+//go:embed schema.json
+var Schema []byte
+
+// 问题级别：error 会导致轨迹无法正常执行，warning 是可能出问题的写法
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+)
+
+// Issue 校验发现的问题，Step 为步骤路径，如 "3"、"5.steps.2"（序号从 1 开始）
+type Issue struct {
+	Level   string `json:"level"`
+	Step    string `json:"step,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	loc := ""
+	if i.Step != "" {
+		loc = "步骤 " + i.Step
+		if i.Field != "" {
+			loc += " " + i.Field
+		}
+		loc += ": "
+	} else if i.Field != "" {
+		loc = i.Field + ": "
+	}
+	return fmt.Sprintf("[%s] %s%s", i.Level, loc, i.Message)
+}
+
+// HasErrors 是否存在 error 级别的问题
+func HasErrors(issues []Issue) bool {
+	for _, i := range issues {
+		if i.Level == LevelError {
+			return true
+		}
+	}
+	return false
+}
+
+// Actions 运行时支持的全部步骤
+var Actions = []string{
+	"navigate", "click", "input", "wait", "captcha", "extract",
+	"if", "foreach", "set",
+	"select", "scroll", "hover", "press", "switch_frame", "switch_tab", "close_tab", "eval", "screenshot",
+}
+
+// httpActions HTTP 模式支持的步骤
+var httpActions = map[string]bool{
+	"navigate": true, "wait": true, "extract": true, "if": true, "foreach": true, "set": true,
+}
+
+// PressKeys press 步骤支持的按键（不区分大小写）
+var PressKeys = []string{
+	"enter", "tab", "escape", "esc", "backspace", "delete", "space",
+	"arrowup", "arrowdown", "arrowleft", "arrowright", "pageup", "pagedown", "home", "end",
+}
+
+// builtinParams 执行轨迹时传入的参数：列表轨迹 Keyword，详情轨迹 URL，登录轨迹 Username/Password
+var builtinParams = map[string]bool{"Keyword": true, "URL": true, "Username": true, "Password": true}
+
+var (
+	templateAction  = regexp.MustCompile(`\{\{(.*?)\}\}`)
+	templateParam   = regexp.MustCompile(`(^|[\s(|])\.([A-Za-z_][A-Za-z0-9_]*)`)
+	nthPseudo       = regexp.MustCompile(`:nth-(child|of-type)\(`)
+	xpathPosition   = regexp.MustCompile(`\[\d+\]`)
+	numericIDSuffix = regexp.MustCompile(`#[A-Za-z_-]*\d{4,}`)
+)
+
+// LintJSON 解析并校验轨迹 JSON：轨迹格式会额外检查未知字段；Chrome 录制先转换再校验
+func LintJSON(data []byte) (*TraceFile, []Issue, error) {
+	var trace TraceFile
+	if err := json.Unmarshal(data, &trace); err == nil && len(trace.Steps) > 0 && trace.Steps[0].Action != "" {
+		issues := unknownFieldIssues(data)
+		return &trace, append(issues, Lint(&trace)...), nil
+	}
+
+	rec, _, err := ParseAnyRecording(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	converted := Convert(rec, "")
+	return converted, Lint(converted), nil
+}
+
+// unknownFieldIssues 严格解析一次，找出拼写错误等未知字段
+func unknownFieldIssues(data []byte) []Issue {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var trace TraceFile
+	if err := dec.Decode(&trace); err != nil && strings.Contains(err.Error(), "unknown field") {
+		return []Issue{{Level: LevelWarning, Message: fmt.Sprintf("存在未知字段，执行时会被忽略: %v", strings.TrimPrefix(err.Error(), "json: "))}}
+	}
+	return nil
+}
+
+// Lint 校验轨迹，返回所有问题（无问题时为空）
+func Lint(trace *TraceFile) []Issue {
+	l := &linter{trace: trace, vars: map[string]bool{}, refs: map[string]string{}, decls: map[string]string{}, condVars: map[string]bool{}}
+
+	switch trace.Mode {
+	case "", "browser", "http":
+	default:
+		l.add(LevelError, "", "mode", "未知执行模式 %q（应为 browser 或 http）", trace.Mode)
+	}
+	switch trace.Type {
+	case "", "list", "detail", "login":
+	default:
+		l.add(LevelWarning, "", "type", "未知轨迹类型 %q（应为 list、detail 或 login）", trace.Type)
+	}
+	if len(trace.Steps) == 0 {
+		l.add(LevelError, "", "steps", "轨迹没有步骤")
+		return l.issues
+	}
+
+	l.collectVars(trace.Steps)
+	l.lintSteps(trace.Steps, "")
+
+	// 轨迹级检查
+	switch trace.Type {
+	case "list":
+		if !l.hasExtract["list"] {
+			l.add(LevelError, "", "steps", "列表轨迹缺少 type 为 list 的 extract 步骤")
+		}
+		if _, ok := l.refs["Keyword"]; !ok {
+			l.add(LevelWarning, "", "steps", "列表轨迹没有引用 {{.Keyword}}，所有关键词的搜索结果都相同")
+		}
+	case "detail":
+		if !l.hasExtract["detail"] {
+			l.add(LevelError, "", "steps", "详情轨迹缺少 type 为 detail 的 extract 步骤")
+		}
+		if _, ok := l.refs["URL"]; !ok {
+			l.add(LevelWarning, "", "steps", "详情轨迹没有引用 {{.URL}}，传入的详情地址不会被使用")
+		}
+	case "login":
+		for _, name := range []string{"Username", "Password"} {
+			if _, ok := l.refs[name]; !ok {
+				l.add(LevelWarning, "", "steps", "登录轨迹没有引用 {{.%s}}，保存的凭据不会被使用", name)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(l.refs))
+	for name := range l.refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !builtinParams[name] && !l.vars[name] {
+			l.add(LevelWarning, l.refs[name], "", "引用的参数 {{.%s}} 未定义（既不是内置参数，也没有步骤写入该变量），执行时原样保留该占位符", name)
+		}
+	}
+
+	// set/foreach 定义的变量没有被模板或 if 条件引用，通常是变量名写错
+	names = names[:0]
+	for name := range l.decls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := l.refs[name]; !ok && !l.condVars[name] {
+			l.add(LevelWarning, l.decls[name], "var", "变量 %s 已定义但没有被引用", name)
+		}
+	}
+	return l.issues
+}
+
+// candidateActions 支持候选选择器的步骤（候选选择器作用于 selector，wait 步骤作用于 wait_for_visible）
+var candidateActions = map[string]bool{
+	"click": true, "input": true, "select": true, "hover": true, "press": true, "scroll": true, "wait": true,
+}
+
+type linter struct {
+	trace      *TraceFile
+	issues     []Issue
+	vars       map[string]bool   // 步骤写入的变量
+	refs       map[string]string // 模板引用的参数 -> 首次出现的步骤
+	decls      map[string]string // set/foreach 定义的变量 -> 首次定义的步骤
+	condVars   map[string]bool   // if 条件中按名称比较的变量
+	hasExtract map[string]bool
+}
+
+func (l *linter) add(level, step, field, format string, args ...interface{}) {
+	l.issues = append(l.issues, Issue{Level: level, Step: step, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// collectVars 收集所有 set/foreach/eval/screenshot 写入的变量（变量可在定义之前的 if 分支中引用，这里不区分顺序）
+func (l *linter) collectVars(steps []TraceStep) {
+	for _, s := range steps {
+		if (s.Action == "set" || s.Action == "foreach" || s.Action == "eval" || s.Action == "screenshot") && s.Var != "" {
+			l.vars[s.Var] = true
+			if s.Action == "foreach" {
+				l.vars[s.Var+"Index"] = true
+			}
+		}
+		l.collectVars(s.Steps)
+		l.collectVars(s.Else)
+	}
+}
+
+func (l *linter) lintSteps(steps []TraceStep, prefix string) {
+	if l.hasExtract == nil {
+		l.hasExtract = map[string]bool{}
+	}
+	for i, s := range steps {
+		path := prefix + strconv.Itoa(i+1)
+		l.lintStep(s, path)
+		if len(s.Steps) > 0 {
+			l.lintSteps(s.Steps, path+".steps.")
+		}
+		if len(s.Else) > 0 {
+			l.lintSteps(s.Else, path+".else.")
+		}
+	}
+}
+
+func (l *linter) lintStep(s TraceStep, path string) {
+	known := false
+	for _, a := range Actions {
+		if s.Action == a {
+			known = true
+			break
+		}
+	}
+	if !known {
+		l.add(LevelError, path, "action", "未知步骤类型 %q，执行时会被跳过", s.Action)
+		return
+	}
+	if l.trace.Mode == "http" && !httpActions[s.Action] {
+		l.add(LevelError, path, "action", "HTTP 模式不支持 %s 步骤", s.Action)
+	}
+
+	require := func(field, value string) {
+		if strings.TrimSpace(value) == "" {
+			l.add(LevelError, path, field, "%s 步骤缺少 %s", s.Action, field)
+		}
+	}
+
+	switch s.Action {
+	case "navigate":
+		require("url", s.URL)
+	case "click", "hover", "select":
+		require("selector", s.Selector)
+	case "input":
+		require("selector", s.Selector)
+		if s.Value == "" {
+			l.add(LevelWarning, path, "value", "input 步骤的 value 为空")
+		}
+	case "wait":
+		if s.WaitTime <= 0 && s.WaitForVisible == "" {
+			l.add(LevelWarning, path, "", "wait 步骤未设置 wait_time 或 wait_for_visible，不会等待")
+		}
+	case "captcha":
+		require("image_selector", s.ImageSelector)
+		require("input_selector", s.InputSelector)
+	case "extract":
+		switch s.Type {
+		case "list":
+			if s.Selector == "" && s.XPath == "" {
+				l.add(LevelError, path, "selector", "列表 extract 缺少行选择器 selector")
+			}
+			if len(s.Fields) == 0 {
+				l.add(LevelError, path, "fields", "列表 extract 没有配置 fields")
+			} else if _, ok := s.Fields["title"]; !ok {
+				l.add(LevelWarning, path, "fields", "列表 extract 没有 title 字段")
+			}
+			if s.Pagination != nil && strings.TrimSpace(s.Pagination.NextButton) == "" {
+				l.add(LevelError, path, "pagination", "翻页配置缺少 next_button")
+			}
+			if s.Pagination != nil && l.trace.Mode == "http" {
+				l.add(LevelWarning, path, "pagination", "HTTP 模式不支持翻页，pagination 会被忽略")
+			}
+		case "detail":
+			if len(s.Fields) == 0 && len(s.MultiFields) == 0 {
+				l.add(LevelWarning, path, "fields", "详情 extract 没有配置 fields")
+			}
+		default:
+			l.add(LevelError, path, "type", "extract 的 type 应为 list 或 detail，当前为 %q", s.Type)
+		}
+		l.hasExtract[s.Type] = true
+	case "if":
+		if s.Condition == nil || *s.Condition == (TraceCondition{}) {
+			l.add(LevelError, path, "condition", "if 步骤缺少 condition")
+		} else {
+			c := s.Condition
+			if c.Var != "" {
+				l.condVars[c.Var] = true
+			}
+			for _, value := range []string{c.Exists, c.URLMatches, c.TextContains, c.Selector, c.Equals} {
+				l.collectRefs(value, path)
+			}
+		}
+		if len(s.Steps) == 0 && len(s.Else) == 0 {
+			l.add(LevelWarning, path, "steps", "if 步骤没有子步骤")
+		}
+	case "foreach":
+		require("var", s.Var)
+		l.declare(s.Var, path)
+		if len(s.Values) == 0 && s.Selector == "" {
+			l.add(LevelError, path, "", "foreach 需要 values 或 selector")
+		}
+		if len(s.Steps) == 0 {
+			l.add(LevelWarning, path, "steps", "foreach 步骤没有子步骤")
+		}
+	case "set":
+		require("var", s.Var)
+		l.declare(s.Var, path)
+	case "press":
+		if !containsFold(PressKeys, s.Value) {
+			l.add(LevelError, path, "value", "不支持的按键 %q", s.Value)
+		}
+	case "scroll":
+		if s.Value != "" && s.Value != "bottom" && s.Value != "top" {
+			_, err := strconv.Atoi(s.Value)
+			if _, _, ok := ParseScrollPosition(s.Value); !ok && err != nil {
+				l.add(LevelError, path, "value", "scroll 的 value 应为 bottom、top、像素数或 x,y 位置")
+			}
+		}
+	case "switch_frame":
+		if s.Selector == "" && s.Value != "main" && !IsFrameIndexPath(s.Value) {
+			l.add(LevelError, path, "selector", "switch_frame 需要 iframe 的 selector，或 value 为 main 或 frame 下标路径（如 0.1）")
+		}
+	case "eval":
+		require("value", s.Value)
+	}
+
+	// 候选选择器
+	if len(s.Candidates) > 0 && !candidateActions[s.Action] {
+		l.add(LevelWarning, path, "candidates", "%s 步骤不使用候选选择器", s.Action)
+	}
+	for i, c := range s.Candidates {
+		if strings.TrimSpace(c.Selector) == "" {
+			l.add(LevelError, path, fmt.Sprintf("candidates[%d]", i), "候选选择器为空")
+		}
+		l.collectRefs(c.Selector, path)
+	}
+
+	// 模板引用与选择器稳定性
+	for _, value := range append([]string{s.URL, s.Selector, s.Value, s.XPath, s.WaitForVisible, s.ImageSelector, s.InputSelector}, s.Values...) {
+		l.collectRefs(value, path)
+	}
+	for _, fields := range []map[string]string{s.Fields, s.MultiFields} {
+		for _, value := range fields {
+			l.collectRefs(value, path)
+		}
+	}
+	for _, f := range []struct{ field, sel string }{
+		{"selector", s.Selector}, {"xpath", s.XPath}, {"image_selector", s.ImageSelector},
+		{"input_selector", s.InputSelector}, {"wait_for_visible", s.WaitForVisible},
+	} {
+		if reason := fragileReason(f.sel); reason != "" {
+			l.add(LevelWarning, path, f.field, "选择器 %s %s，页面改版后容易失效", f.sel, reason)
+		}
+	}
+}
+
+// declare 记录 set/foreach 定义的变量
+func (l *linter) declare(name, path string) {
+	if _, ok := l.decls[name]; !ok && name != "" {
+		l.decls[name] = path
+	}
+}
+
+// collectRefs 记录模板中引用的参数
+func (l *linter) collectRefs(s, path string) {
+	for _, action := range templateAction.FindAllStringSubmatch(s, -1) {
+		for _, m := range templateParam.FindAllStringSubmatch(action[1], -1) {
+			if _, ok := l.refs[m[2]]; !ok {
+				l.refs[m[2]] = path
+			}
+		}
+	}
+}
+
+// fragileReason 选择器不稳定的原因，稳定时返回空
+func fragileReason(sel string) string {
+	if sel == "" || strings.HasPrefix(sel, "@item") {
+		return ""
+	}
+	switch {
+	case containsDynamicID(sel):
+		return "包含组件库动态生成的 ID"
+	case numericIDSuffix.MatchString(sel):
+		return "包含带长数字的 ID（可能是自动生成的）"
+	case len(nthPseudo.FindAllString(sel, -1)) >= 3:
+		return "包含多层 nth-child/nth-of-type"
+	case strings.HasPrefix(strings.TrimPrefix(strings.TrimPrefix(sel, "xpath:"), "xpath/"), "/html"):
+		return "是从 /html 开始的绝对 XPath"
+	case strings.HasPrefix(sel, "xpath") && len(xpathPosition.FindAllString(sel, -1)) >= 4:
+		return "包含多层位置下标"
+	}
+	return ""
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, strings.TrimSpace(s)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package traceconv
+
+import (
+	"strings"
+	"testing"
+)
+
+// findIssue 返回第一个消息包含 text 的问题
+func findIssue(issues []Issue, text string) *Issue {
+	for i := range issues {
+		if strings.Contains(issues[i].Message, text) {
+			return &issues[i]
+		}
+	}
+	return nil
+}
+
+// TestLintParamReferences 未定义的参数按原样保留；定义了但没有引用的变量和内置参数给出警告
+func TestLintParamReferences(t *testing.T) {
+	trace := &TraceFile{
+		Type: "list",
+		Steps: []TraceStep{
+			{Action: "navigate", URL: "https://example.com/search?q={{.Keyword}}&page={{.Page}}"},
+			{Action: "set", Var: "Region", Value: "广东"},
+			{Action: "set", Var: "Unused", Value: "1"},
+			{Action: "foreach", Var: "Category", Values: []string{"货物", "服务"}, Steps: []TraceStep{
+				{Action: "if", Condition: &TraceCondition{Var: "Region", Equals: "广东"}, Steps: []TraceStep{
+					{Action: "click", Selector: "text={{.Category}}"},
+				}},
+			}},
+			{Action: "extract", Type: "list", Selector: "tbody tr", Fields: map[string]string{"title": "td a"}},
+		},
+	}
+	issues := Lint(trace)
+
+	undefined := findIssue(issues, "{{.Page}}")
+	if undefined == nil || undefined.Step != "1" || !strings.Contains(undefined.Message, "原样保留") {
+		t.Errorf("未定义参数的问题 = %+v", undefined)
+	}
+	if issue := findIssue(issues, "将渲染为空"); issue != nil {
+		t.Errorf("不应提示渲染为空: %+v", issue)
+	}
+
+	unused := findIssue(issues, "变量 Unused")
+	if unused == nil || unused.Step != "3" || unused.Field != "var" || unused.Level != LevelWarning {
+		t.Errorf("未引用变量的问题 = %+v", unused)
+	}
+	// 条件中按名称比较和模板中引用的变量都算作已引用
+	for _, name := range []string{"变量 Region", "变量 Category"} {
+		if issue := findIssue(issues, name); issue != nil {
+			t.Errorf("%s 已被引用，不应报告: %+v", name, issue)
+		}
+	}
+}
+
+// TestLintUnreferencedBuiltinParams 详情轨迹不引用 URL、登录轨迹不引用账号密码时给出警告
+func TestLintUnreferencedBuiltinParams(t *testing.T) {
+	detail := &TraceFile{Type: "detail", Steps: []TraceStep{
+		{Action: "navigate", URL: "https://example.com/notice/1"},
+		{Action: "extract", Type: "detail", Fields: map[string]string{"amount": ".amount"}},
+	}}
+	if findIssue(Lint(detail), "{{.URL}}") == nil {
+		t.Error("详情轨迹没有引用 {{.URL}} 时应给出警告")
+	}
+
+	login := &TraceFile{Type: "login", Steps: []TraceStep{
+		{Action: "navigate", URL: "https://example.com/login"},
+		{Action: "input", Selector: "#user", Value: "{{.Username}}"},
+		{Action: "input", Selector: "#pass", Value: "secret"},
+	}}
+	issues := Lint(login)
+	if findIssue(issues, "{{.Password}}") == nil {
+		t.Error("登录轨迹没有引用 {{.Password}} 时应给出警告")
+	}
+	if issue := findIssue(issues, "{{.Username}}"); issue != nil {
+		t.Errorf("已引用 {{.Username}}，不应报告: %+v", issue)
+	}
+}
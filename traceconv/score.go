@@ -0,0 +1,144 @@
+package traceconv
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ==================== 选择器评分 ====================
+
+// MaxCandidates 每个步骤最多保留的候选选择器数
+const MaxCandidates = 4
+
+var (
+	cssIDPattern    = regexp.MustCompile(`#[A-Za-z_][\w-]*`)
+	stableAttr      = regexp.MustCompile(`\[(name|placeholder|data-testid|data-test|title|alt|aria-label|type|href)[*^$~|]?=`)
+	xpathStableAttr = regexp.MustCompile(`@(id|name|placeholder|title|aria-label|data-testid)\s*=`)
+	ariaRoleSuffix  = regexp.MustCompile(`^(.*?)\[role="(\w+)"\]$`)
+)
+
+// ScoreSelector 选择器稳健性评分（0-100，越高越不容易因页面改版失效）：
+// 稳定 ID > 语义属性 > 文本 > 普通 CSS > XPath > 绝对 XPath，
+// 动态 ID、多层 nth-child、过深的层级和 XPath 位置下标会扣分
+func ScoreSelector(sel string) int {
+	sel = strings.TrimSpace(strings.TrimPrefix(sel, "pierce/"))
+	if sel == "" {
+		return 0
+	}
+
+	var score int
+	switch {
+	case strings.HasPrefix(sel, "xpath"):
+		expr := strings.TrimPrefix(strings.TrimPrefix(sel, "xpath:"), "xpath/")
+		score = 45
+		if xpathStableAttr.MatchString(expr) {
+			score = 65
+		}
+		if strings.HasPrefix(expr, "/html") {
+			score = 15
+		}
+		score -= 5 * len(xpathPosition.FindAllString(expr, -1))
+	case strings.HasPrefix(sel, "text=") || strings.HasPrefix(sel, "text/"):
+		score = 60
+	case strings.HasPrefix(sel, "aria/"):
+		score = 70
+	case strings.HasPrefix(sel, "regex="):
+		score = 55
+	default:
+		score = 55
+		if cssIDPattern.MatchString(sel) {
+			score = 90
+		} else if stableAttr.MatchString(sel) {
+			score = 80
+		} else if strings.Contains(sel, ":contains(") || strings.Contains(sel, ":text(") {
+			score = 65
+		}
+		score -= 8 * len(nthPseudo.FindAllString(sel, -1))
+		if depth := selectorDepth(sel); depth > 2 {
+			score -= 3 * (depth - 2)
+		}
+	}
+
+	if containsDynamicID(sel) {
+		score -= 50
+	} else if numericIDSuffix.MatchString(sel) {
+		score -= 25
+	}
+	if score < 0 {
+		return 0
+	}
+	if score > 100 {
+		return 100
+	}
+	return score
+}
+
+// selectorDepth CSS 选择器的层级数（后代、子元素组合符分隔的段数）
+func selectorDepth(sel string) int {
+	depth, inBracket, inToken := 0, 0, false
+	for _, c := range sel {
+		switch {
+		case c == '[' || c == '(':
+			inBracket++
+		case c == ']' || c == ')':
+			inBracket--
+		case inBracket > 0:
+		case c == ' ' || c == '>' || c == '+' || c == '~':
+			inToken = false
+			continue
+		}
+		if !inToken {
+			depth++
+			inToken = true
+		}
+	}
+	return depth
+}
+
+// runtimeSelector 录制中的选择器转为运行时语法：text/ 转为 text=，aria/ 转为属性或文本选择器，去掉 pierce/
+func runtimeSelector(sel string) string {
+	switch {
+	case strings.HasPrefix(sel, "text/"):
+		return "text=" + strings.TrimPrefix(sel, "text/")
+	case strings.HasPrefix(sel, "pierce/"):
+		return strings.TrimPrefix(sel, "pierce/")
+	case strings.HasPrefix(sel, "aria/"):
+		name := strings.TrimPrefix(sel, "aria/")
+		if m := ariaRoleSuffix.FindStringSubmatch(name); m != nil {
+			switch m[2] {
+			case "button":
+				return textLocator("button", m[1])
+			case "link":
+				return textLocator("a", m[1])
+			}
+			name = m[1]
+		}
+		return fmt.Sprintf("[aria-label=%s]", cssString(name))
+	}
+	return sel
+}
+
+// candidateSelectors 录制中除主选择器外的候选选择器（转为运行时语法），
+// 按评分从高到低排列；动态 ID 下次加载就会变化，不作为候选
+func candidateSelectors(groups [][]string, primary string) []SelectorCandidate {
+	var candidates []SelectorCandidate
+	seen := map[string]bool{primary: true}
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		sel := runtimeSelector(group[0])
+		if sel == "" || seen[sel] || containsDynamicID(sel) {
+			continue
+		}
+		seen[sel] = true
+		candidates = append(candidates, SelectorCandidate{Selector: sel, Score: ScoreSelector(sel)})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	if len(candidates) > MaxCandidates {
+		candidates = candidates[:MaxCandidates]
+	}
+	return candidates
+}
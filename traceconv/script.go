@@ -0,0 +1,603 @@
+package traceconv
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ==================== Playwright / Puppeteer 导入 ====================
+//
+// 按行解析 codegen 生成的脚本（Playwright JS/TS/Python、Puppeteer），识别以下写法：
+//
+//	page.goto(url)
+//	page.getByRole('button', { name: '查询' }).click()      // 以及 locator、getByText、getByPlaceholder 等
+//	page.get_by_placeholder("请输入标题").fill("电梯")
+//	page.click(selector) / page.fill(selector, value) / page.type(selector, value)
+//	page.keyboard.press('Enter')
+//	page.frameLocator('iframe#main').locator('#kw').fill('x')
+//	const page1 = await page1Promise / page1 = popup_info.value   // 新标签页
+
+var (
+	scriptAssignPattern = regexp.MustCompile(`^(?:(?:const|let|var)\s+)?([A-Za-z_$][\w$]*)\s*=\s*(.*)$`)
+	scriptWithPattern   = regexp.MustCompile(`^(?:async\s+)?with\s+([A-Za-z_]\w*)\.expect_(popup|navigation)\(.*\)\s+as\s+([A-Za-z_]\w*)\s*:$`)
+	scriptTitlePattern  = regexp.MustCompile(`^test\(\s*['"\x60](.*?)['"\x60]`)
+)
+
+// scriptValue 脚本中的参数值
+type scriptValue struct {
+	str   string
+	isStr bool
+	raw   string
+}
+
+// scriptCall 调用链中的一次调用（或属性访问）
+type scriptCall struct {
+	name   string
+	called bool
+	args   []scriptValue
+	opts   map[string]scriptValue // 对象参数 { name: 'x' } 或 Python 关键字参数 name="x"
+}
+
+// firstArg 第一个字符串参数
+func (c scriptCall) firstArg() string {
+	for _, a := range c.args {
+		if a.isStr {
+			return a.str
+		}
+	}
+	return ""
+}
+
+// stringArgs 所有字符串参数
+func (c scriptCall) stringArgs() []string {
+	var args []string
+	for _, a := range c.args {
+		if a.isStr {
+			args = append(args, a.str)
+		}
+	}
+	return args
+}
+
+// scriptImporter 解析状态
+type scriptImporter struct {
+	rec        *ChromeRecording
+	mainPage   string
+	pages      map[string]bool // 新标签页变量
+	popups     map[string]bool // 等待新标签页的 Promise / Python expect_popup 变量
+	navigation bool            // Python expect_navigation 块中，下一个点击导致跳转
+	lastClick  int             // 最近一次点击在 rec.Steps 中的下标
+}
+
+// ParseScript 解析 Playwright / Puppeteer 脚本
+func ParseScript(data []byte) (*ChromeRecording, error) {
+	im := &scriptImporter{
+		rec:       &ChromeRecording{},
+		pages:     make(map[string]bool),
+		popups:    make(map[string]bool),
+		lastClick: -1,
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		im.parseLine(strings.TrimSpace(line))
+	}
+	if len(im.rec.Steps) == 0 {
+		return nil, fmt.Errorf("脚本中没有可转换的页面操作")
+	}
+	return im.rec, nil
+}
+
+func (im *scriptImporter) parseLine(line string) {
+	line = strings.TrimSuffix(line, ";")
+	if line == "" || strings.HasPrefix(line, "//") || strings.HasPrefix(line, "#") {
+		return
+	}
+	if m := scriptTitlePattern.FindStringSubmatch(line); m != nil && im.rec.Title == "" {
+		im.rec.Title = m[1]
+		return
+	}
+	if m := scriptWithPattern.FindStringSubmatch(line); m != nil {
+		if m[2] == "popup" {
+			im.popups[m[3]] = true
+		} else {
+			im.navigation = true
+		}
+		return
+	}
+
+	lhs := ""
+	if m := scriptAssignPattern.FindStringSubmatch(line); m != nil && !strings.HasPrefix(m[2], "=") {
+		lhs, line = m[1], m[2]
+	}
+	line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "await "))
+
+	receiver, calls := parseScriptChain(line)
+	if receiver == "" {
+		return
+	}
+
+	// 新标签页：const page1 = await page1Promise / page1 = popup_info.value
+	if lhs != "" && im.popups[receiver] && (len(calls) == 0 || (len(calls) == 1 && calls[0].name == "value")) {
+		im.pages[lhs] = true
+		im.markNavigation()
+		return
+	}
+	if len(calls) == 0 || !im.isPage(receiver) {
+		return
+	}
+	if lhs != "" && calls[len(calls)-1].name == "waitForEvent" && calls[len(calls)-1].firstArg() == "popup" {
+		im.popups[lhs] = true
+		return
+	}
+	im.convertCall(receiver, calls)
+}
+
+// isPage 变量是否是页面：主页面（第一个出现的 page 变量）或新标签页
+func (im *scriptImporter) isPage(name string) bool {
+	if im.pages[name] || name == im.mainPage {
+		return true
+	}
+	if im.mainPage == "" && strings.HasPrefix(name, "page") {
+		im.mainPage = name
+		return true
+	}
+	return false
+}
+
+// markNavigation 最近一次点击导致了页面跳转或打开了新标签页
+func (im *scriptImporter) markNavigation() {
+	if im.lastClick >= 0 {
+		step := &im.rec.Steps[im.lastClick]
+		if !step.navigates() {
+			step.AssertedEvents = append(step.AssertedEvents, AssertedEvent{Type: "navigation"})
+		}
+	}
+}
+
+func (im *scriptImporter) add(page string, frames []string, step ChromeStep) {
+	step.Target = "main"
+	if page != im.mainPage {
+		step.Target = page
+	}
+	step.frameSelectors = append([]string{}, frames...)
+	if step.Type == "click" {
+		im.lastClick = len(im.rec.Steps)
+		if im.navigation {
+			step.AssertedEvents = []AssertedEvent{{Type: "navigation"}}
+			im.navigation = false
+		}
+	}
+	im.rec.Steps = append(im.rec.Steps, step)
+}
+
+// convertCall 把一条页面调用链转为录制步骤
+func (im *scriptImporter) convertCall(page string, calls []scriptCall) {
+	var parts []locatorPart
+	var frames []string
+	for i, call := range calls {
+		last := i == len(calls)-1
+		switch call.name {
+		case "keyboard":
+			if !last && calls[i+1].name == "press" {
+				im.add(page, frames, ChromeStep{Type: "keyDown", Key: calls[i+1].firstArg()})
+			}
+			return
+		case "mouse":
+			if !last && calls[i+1].name == "wheel" {
+				im.add(page, frames, ChromeStep{Type: "scroll"})
+			}
+			return
+		case "frameLocator":
+			frames = append(frames, normalizeLocator(call.firstArg()))
+			parts = nil
+			continue
+		case "contentFrame":
+			if len(parts) > 0 {
+				frames = append(frames, joinLocatorParts(parts))
+				parts = nil
+			}
+			continue
+		}
+		if part, ok := scriptLocator(call); ok {
+			if part.sel != "" {
+				parts = append(parts, part)
+			}
+			continue
+		}
+		if !last {
+			// 其他中间调用（如 Python 的 first 属性）不影响定位
+			continue
+		}
+		im.convertAction(page, frames, parts, call)
+	}
+}
+
+// convertAction 转换调用链末尾的操作
+func (im *scriptImporter) convertAction(page string, frames []string, parts []locatorPart, call scriptCall) {
+	args := call.stringArgs()
+	selector := joinLocatorParts(parts)
+	// page.click(selector) 等直接传入选择器的写法
+	legacy := selector == ""
+	if legacy && len(args) > 0 && call.name != "goto" {
+		selector = scriptSelector(args[0])
+		args = args[1:]
+	}
+	arg := ""
+	if len(args) > 0 {
+		arg = args[0]
+	}
+
+	switch call.name {
+	case "goto":
+		if im.rec.URL == "" {
+			im.rec.URL = arg
+		}
+		im.add(page, frames, ChromeStep{Type: "navigate", URL: arg})
+	case "click", "dblclick", "check", "uncheck", "tap", "setChecked":
+		if selector != "" {
+			im.add(page, frames, ChromeStep{Type: "click", Selectors: locatorGroups(selector)})
+		}
+	case "fill", "type", "pressSequentially":
+		if selector != "" {
+			im.add(page, frames, ChromeStep{Type: "change", Selectors: locatorGroups(selector), Value: arg})
+		}
+	case "press":
+		im.add(page, frames, ChromeStep{Type: "keyDown", Key: arg})
+	case "selectOption", "select":
+		value := arg
+		for _, key := range []string{"label", "value"} {
+			if v, ok := call.opts[key]; ok && value == "" {
+				value = v.str
+			}
+		}
+		if selector != "" {
+			im.add(page, frames, ChromeStep{Type: "change", Selectors: locatorGroups(selectLocator(selector)), Value: value})
+		}
+	case "hover":
+		if selector != "" {
+			im.add(page, frames, ChromeStep{Type: "hover", Selectors: locatorGroups(selector)})
+		}
+	case "waitForSelector", "waitFor":
+		if selector != "" {
+			im.add(page, frames, ChromeStep{Type: "waitForElement", Selectors: locatorGroups(selector)})
+		}
+	case "waitForURL", "waitForNavigation":
+		im.markNavigation()
+	case "close":
+		if page != im.mainPage {
+			im.add(page, nil, ChromeStep{Type: "close"})
+		}
+	}
+}
+
+// ==================== 定位器 ====================
+
+// locatorPart 调用链中的一段定位，css 表示可以与其他段用空格拼接为后代选择器
+type locatorPart struct {
+	sel string
+	css bool
+}
+
+// joinLocatorParts 拼接定位链：全部是 CSS 时拼为后代选择器，否则取最后一段
+func joinLocatorParts(parts []locatorPart) string {
+	if len(parts) == 0 {
+		return ""
+	}
+	var sels []string
+	for _, p := range parts {
+		if !p.css {
+			return parts[len(parts)-1].sel
+		}
+		sels = append(sels, p.sel)
+	}
+	return strings.Join(sels, " ")
+}
+
+// scriptRoleTags getByRole 的角色对应的标签
+var scriptRoleTags = map[string]string{
+	"link": "a", "button": "button", "row": "tr", "cell": "td", "gridcell": "td",
+	"listitem": "li", "option": "option", "heading": "",
+}
+
+// scriptLocator 定位方法转为选择器，不是定位方法时返回 false
+func scriptLocator(call scriptCall) (locatorPart, bool) {
+	arg := call.firstArg()
+	switch call.name {
+	case "locator", "$":
+		sel := scriptSelector(arg)
+		if hasText, ok := call.opts["hasText"]; ok && hasText.isStr && isCSS(sel) && !strings.Contains(hasText.str, "'") {
+			sel += fmt.Sprintf(":contains('%s')", hasText.str)
+		}
+		return locatorPart{sel: sel, css: isCSS(sel)}, true
+	case "getByRole":
+		name := call.opts["name"].str
+		switch arg {
+		case "textbox", "searchbox", "combobox", "spinbutton":
+			if name == "" {
+				return locatorPart{sel: "input", css: true}, true
+			}
+			sel := fmt.Sprintf("[aria-label=%s], [placeholder*=%s]", cssString(name), cssString(name))
+			if arg == "combobox" {
+				sel = fmt.Sprintf("select[aria-label=%s], [aria-label=%s], [placeholder*=%s]", cssString(name), cssString(name), cssString(name))
+			}
+			return locatorPart{sel: sel}, true
+		}
+		tag, known := scriptRoleTags[arg]
+		if name == "" {
+			if !known || tag == "" {
+				tag = fmt.Sprintf("[role=%s]", cssString(arg))
+			}
+			return locatorPart{sel: tag, css: true}, true
+		}
+		sel := textLocator(tag, name)
+		return locatorPart{sel: sel, css: isCSS(sel)}, true
+	case "getByText":
+		return locatorPart{sel: "text=" + arg}, true
+	case "getByPlaceholder":
+		return locatorPart{sel: fmt.Sprintf("[placeholder=%s]", cssString(arg)), css: true}, true
+	case "getByLabel":
+		return locatorPart{sel: fmt.Sprintf("[aria-label=%s]", cssString(arg)), css: true}, true
+	case "getByTestId":
+		return locatorPart{sel: fmt.Sprintf("[data-testid=%s]", cssString(arg)), css: true}, true
+	case "getByTitle":
+		return locatorPart{sel: fmt.Sprintf("[title=%s]", cssString(arg)), css: true}, true
+	case "getByAltText":
+		return locatorPart{sel: fmt.Sprintf("[alt=%s]", cssString(arg)), css: true}, true
+	case "first", "last", "nth", "filter", "and", "or":
+		if call.name == "nth" || call.name == "last" {
+			log.Printf("⚠️ 脚本使用了 %s() 定位，转换后取第一个匹配元素，请核对", call.name)
+		}
+		return locatorPart{}, true
+	}
+	return locatorPart{}, false
+}
+
+// scriptSelector Playwright / Puppeteer 选择器字符串转为运行时选择器
+func scriptSelector(sel string) string {
+	sel = strings.TrimSpace(sel)
+	switch {
+	case strings.HasPrefix(sel, "xpath="):
+		return "xpath/" + strings.TrimPrefix(sel, "xpath=")
+	case strings.HasPrefix(sel, "css="):
+		return strings.TrimPrefix(sel, "css=")
+	case strings.HasPrefix(sel, "id="):
+		return "#" + strings.TrimPrefix(sel, "id=")
+	case strings.HasPrefix(sel, "text="):
+		return "text=" + strings.Trim(strings.TrimPrefix(sel, "text="), `"'`)
+	}
+	// Playwright 的 :has-text() 对应运行时的 :contains()
+	return normalizeLocator(strings.ReplaceAll(sel, ":has-text(", ":contains("))
+}
+
+// isCSS 选择器是否是 CSS（可以拼接为后代选择器）
+func isCSS(sel string) bool {
+	return sel != "" && !strings.HasPrefix(sel, "text=") && !strings.HasPrefix(sel, "xpath") &&
+		!strings.HasPrefix(sel, "aria/") && !strings.HasPrefix(sel, "regex=")
+}
+
+// ==================== 调用链解析 ====================
+
+// parseScriptChain 解析 receiver.a(...).b(...) 形式的调用链，方法名统一为驼峰写法
+func parseScriptChain(s string) (string, []scriptCall) {
+	p := &scriptParser{s: s}
+	receiver := p.ident()
+	if receiver == "" {
+		return "", nil
+	}
+	var calls []scriptCall
+	for {
+		p.space()
+		if !p.consume('.') {
+			break
+		}
+		p.space()
+		name := p.ident()
+		if name == "" {
+			break
+		}
+		call := scriptCall{name: camelCase(name), opts: map[string]scriptValue{}}
+		p.space()
+		if p.consume('(') {
+			call.called = true
+			p.args(&call)
+		}
+		calls = append(calls, call)
+	}
+	return receiver, calls
+}
+
+// camelCase Python 风格的 get_by_role 转为 getByRole
+func camelCase(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// scriptParser 简单的 JS / Python 表达式扫描器，只识别调用链需要的字面量
+type scriptParser struct {
+	s string
+	i int
+}
+
+func (p *scriptParser) eof() bool { return p.i >= len(p.s) }
+
+func (p *scriptParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.i]
+}
+
+func (p *scriptParser) consume(c byte) bool {
+	if p.peek() == c {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *scriptParser) space() {
+	for !p.eof() && unicode.IsSpace(rune(p.s[p.i])) {
+		p.i++
+	}
+}
+
+func (p *scriptParser) ident() string {
+	start := p.i
+	for !p.eof() {
+		c := p.s[p.i]
+		if c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || p.i > start && c >= '0' && c <= '9' {
+			p.i++
+			continue
+		}
+		break
+	}
+	return p.s[start:p.i]
+}
+
+// args 解析参数列表直到右括号：字符串、对象（展开到 opts）、数组（取第一个元素）、关键字参数
+func (p *scriptParser) args(call *scriptCall) {
+	for {
+		p.space()
+		if p.eof() || p.consume(')') {
+			return
+		}
+		if p.consume(',') {
+			continue
+		}
+		// Python 关键字参数 name="x"
+		save := p.i
+		if key := p.ident(); key != "" {
+			p.space()
+			if p.peek() == '=' && p.i+1 < len(p.s) && p.s[p.i+1] != '=' {
+				p.i++
+				call.opts[key] = p.value(call)
+				continue
+			}
+		}
+		p.i = save
+		if v := p.value(call); v.isStr || v.raw != "" {
+			call.args = append(call.args, v)
+		}
+		if p.i == save {
+			p.i++ // 不匹配的括号等，跳过避免死循环
+		}
+	}
+}
+
+// value 解析一个参数值；对象字面量的键值写入 call.opts
+func (p *scriptParser) value(call *scriptCall) scriptValue {
+	p.space()
+	switch c := p.peek(); c {
+	case '\'', '"', '`':
+		return scriptValue{str: p.str(c), isStr: true}
+	case '{':
+		p.i++
+		for {
+			p.space()
+			if p.eof() || p.consume('}') {
+				return scriptValue{}
+			}
+			if p.consume(',') {
+				continue
+			}
+			key := p.ident()
+			if key == "" {
+				if q := p.peek(); q == '\'' || q == '"' {
+					key = p.str(q)
+				}
+			}
+			p.space()
+			if key == "" || !p.consume(':') {
+				start := p.i
+				p.skip()
+				if p.i == start {
+					p.i++
+				}
+				continue
+			}
+			call.opts[key] = p.value(call)
+		}
+	case '[':
+		p.i++
+		var first scriptValue
+		for {
+			p.space()
+			if p.eof() || p.consume(']') {
+				return first
+			}
+			if p.consume(',') {
+				continue
+			}
+			start := p.i
+			if v := p.value(call); !first.isStr && v.isStr {
+				first = v
+			}
+			if p.i == start {
+				p.i++
+			}
+		}
+	}
+	start := p.i
+	p.skip()
+	return scriptValue{raw: strings.TrimSpace(p.s[start:p.i])}
+}
+
+// str 解析带引号的字符串字面量（处理常见转义）
+func (p *scriptParser) str(quote byte) string {
+	p.i++
+	var b strings.Builder
+	for !p.eof() {
+		c := p.s[p.i]
+		p.i++
+		switch {
+		case c == '\\' && !p.eof():
+			next := p.s[p.i]
+			p.i++
+			switch next {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(next)
+			}
+		case c == quote:
+			return b.String()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// skip 跳过一个无法识别的值（正则、数字、箭头函数等），停在同层的逗号或右括号
+func (p *scriptParser) skip() {
+	depth := 0
+	for !p.eof() {
+		c := p.s[p.i]
+		switch c {
+		case '\'', '"', '`':
+			p.str(c)
+			continue
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			if depth == 0 {
+				return
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				return
+			}
+		}
+		p.i++
+	}
+}
@@ -0,0 +1,213 @@
+package traceconv
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ==================== Selenium IDE 导入 ====================
+
+// SeleniumProject Selenium IDE 导出的项目文件（.side）
+type SeleniumProject struct {
+	Name  string         `json:"name"`
+	URL   string         `json:"url"`
+	Tests []SeleniumTest `json:"tests"`
+}
+
+// SeleniumTest 项目中的一个测试用例
+type SeleniumTest struct {
+	Name     string            `json:"name"`
+	Commands []SeleniumCommand `json:"commands"`
+}
+
+// SeleniumCommand 测试用例中的一条命令
+type SeleniumCommand struct {
+	Command     string     `json:"command"`
+	Target      string     `json:"target"`
+	Targets     [][]string `json:"targets,omitempty"` // 候选定位器：[定位器, 策略]
+	Value       string     `json:"value"`
+	OpensWindow bool       `json:"opensWindow,omitempty"`
+}
+
+// seleniumKeyPattern sendKeys 中的特殊按键，如 ${KEY_ENTER}
+var seleniumKeyPattern = regexp.MustCompile(`\$\{KEY_([A-Z_]+)\}`)
+
+// seleniumKeyNames Selenium 按键名到 Chrome 录制按键名
+var seleniumKeyNames = map[string]string{
+	"ENTER": "Enter", "RETURN": "Enter", "TAB": "Tab", "ESCAPE": "Escape", "ESC": "Escape",
+}
+
+// ParseSelenium 解析 Selenium IDE 项目文件，转换第一个包含命令的测试用例
+func ParseSelenium(data []byte) (*ChromeRecording, error) {
+	var project SeleniumProject
+	if err := json.Unmarshal(data, &project); err != nil {
+		return nil, fmt.Errorf("无法解析Selenium IDE项目: %v", err)
+	}
+
+	var test *SeleniumTest
+	for i := range project.Tests {
+		if len(project.Tests[i].Commands) > 0 {
+			test = &project.Tests[i]
+			break
+		}
+	}
+	if test == nil {
+		return nil, fmt.Errorf("Selenium IDE项目中没有测试命令")
+	}
+	if len(project.Tests) > 1 {
+		log.Printf("⚠️ Selenium IDE 项目包含 %d 个测试，只转换第一个: %s", len(project.Tests), test.Name)
+	}
+
+	rec := &ChromeRecording{Title: firstNonEmpty(test.Name, project.Name)}
+	target := "main"
+	var frames []string
+	add := func(step ChromeStep) {
+		step.Target = target
+		step.frameSelectors = append([]string{}, frames...)
+		rec.Steps = append(rec.Steps, step)
+	}
+
+	for _, cmd := range test.Commands {
+		// 以 // 开头的命令在 Selenium IDE 中被禁用
+		if strings.HasPrefix(cmd.Command, "//") {
+			continue
+		}
+		selectors := seleniumSelectors(cmd)
+
+		switch cmd.Command {
+		case "open":
+			url := cmd.Target
+			if !strings.Contains(url, "://") {
+				url = strings.TrimSuffix(project.URL, "/") + "/" + strings.TrimPrefix(url, "/")
+			}
+			if rec.URL == "" {
+				rec.URL = url
+			}
+			add(ChromeStep{Type: "navigate", URL: url})
+
+		case "click", "clickAt", "doubleClick", "doubleClickAt", "check", "uncheck":
+			step := ChromeStep{Type: "click", Selectors: locatorGroups(selectors...)}
+			if cmd.OpensWindow {
+				// 打开新窗口的点击（如列表行打开详情页）视为页面跳转
+				step.AssertedEvents = []AssertedEvent{{Type: "navigation"}}
+			}
+			add(step)
+
+		case "type", "sendKeys", "editContent":
+			text := cmd.Value
+			keys := seleniumKeyPattern.FindAllStringSubmatch(text, -1)
+			text = seleniumKeyPattern.ReplaceAllString(text, "")
+			if text != "" {
+				add(ChromeStep{Type: "change", Selectors: locatorGroups(selectors...), Value: text})
+			}
+			for _, key := range keys {
+				if name, ok := seleniumKeyNames[key[1]]; ok {
+					add(ChromeStep{Type: "keyDown", Key: name})
+				}
+			}
+
+		case "select", "addSelection":
+			// 选项定位器：label=文本、value=值、index=下标
+			value := cmd.Value
+			if idx := strings.Index(value, "="); idx > 0 {
+				value = value[idx+1:]
+			}
+			for i, sel := range selectors {
+				selectors[i] = selectLocator(sel)
+			}
+			add(ChromeStep{Type: "change", Selectors: locatorGroups(selectors...), Value: value})
+
+		case "mouseOver":
+			add(ChromeStep{Type: "hover", Selectors: locatorGroups(selectors...)})
+
+		case "waitForElementVisible", "waitForElementPresent":
+			add(ChromeStep{Type: "waitForElement", Selectors: locatorGroups(selectors...)})
+
+		case "selectFrame":
+			switch {
+			case cmd.Target == "relative=top":
+				frames = nil
+			case cmd.Target == "relative=parent":
+				if len(frames) > 0 {
+					frames = frames[:len(frames)-1]
+				}
+			case strings.HasPrefix(cmd.Target, "index="):
+				var idx int
+				fmt.Sscanf(strings.TrimPrefix(cmd.Target, "index="), "%d", &idx)
+				frames = append(frames, strconv.Itoa(idx)) // 下标路径，按 window.frames 顺序定位
+			default:
+				frames = append(frames, bestSelector(locatorGroups(selectors...)))
+			}
+
+		case "selectWindow":
+			// handle=${root} 等回到原窗口，其余视为切换到新打开的窗口
+			if strings.Contains(cmd.Target, "root") || strings.Contains(cmd.Target, "main") {
+				target = "main"
+			} else {
+				target = cmd.Target
+			}
+			frames = nil
+
+		case "close":
+			add(ChromeStep{Type: "close"})
+			target, frames = "main", nil
+
+		case "runScript", "executeScript", "executeAsyncScript":
+			log.Printf("⚠️ Selenium 命令 %s 未转换，需要时请手动添加 eval 步骤: %s", cmd.Command, cmd.Target)
+		}
+	}
+
+	if len(rec.Steps) == 0 {
+		return nil, fmt.Errorf("录制中没有步骤")
+	}
+	return rec, nil
+}
+
+// seleniumSelectors 命令的定位器（主定位器在前，其次是候选定位器）
+func seleniumSelectors(cmd SeleniumCommand) []string {
+	var selectors []string
+	if sel := seleniumLocator(cmd.Target); sel != "" {
+		selectors = append(selectors, sel)
+	}
+	for _, t := range cmd.Targets {
+		if len(t) > 0 {
+			if sel := seleniumLocator(t[0]); sel != "" {
+				selectors = append(selectors, sel)
+			}
+		}
+	}
+	return selectors
+}
+
+// seleniumLocator 把 Selenium 定位器（id=、name=、css=、xpath=、linkText= 等）转为选择器
+func seleniumLocator(locator string) string {
+	strategy, value, ok := strings.Cut(locator, "=")
+	if !ok {
+		return normalizeLocator(locator)
+	}
+	switch strategy {
+	case "id":
+		if cssIdentPattern.MatchString(value) {
+			return "#" + value
+		}
+		return fmt.Sprintf("[id=%s]", cssString(value))
+	case "name":
+		return fmt.Sprintf("[name=%s]", cssString(value))
+	case "css":
+		return value
+	case "xpath":
+		return "xpath/" + value
+	case "linkText", "link":
+		return textLocator("a", value)
+	case "partialLinkText":
+		return "text=" + value
+	}
+	return normalizeLocator(locator)
+}
+
+// cssIdentPattern 可以直接写成 #id 的 ID
+var cssIdentPattern = regexp.MustCompile(`^[A-Za-z_][\w-]*$`)
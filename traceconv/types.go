@@ -0,0 +1,173 @@
+// Package traceconv 定义轨迹文件格式，并把 Chrome DevTools Recorder、Selenium IDE、
+// Playwright/Puppeteer 录制转换为轨迹。
+// 服务端上传（/api/traces）和 cmd/convert-trace 命令行工具共用这里的转换逻辑。
+package traceconv
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ==================== 轨迹格式 ====================
+
+// TraceFile 轨迹文件
+type TraceFile struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	URL      string            `json:"url"`
+	Mode     string            `json:"mode,omitempty"`     // 执行模式: browser（默认）/ http
+	Headers  map[string]string `json:"headers,omitempty"`  // http 模式附加的请求头
+	Encoding string            `json:"encoding,omitempty"` // http 模式强制页面编码（默认自动检测）
+	Steps    []TraceStep       `json:"steps"`
+}
+
+// TraceStep 轨迹步骤
+type TraceStep struct {
+	Action         string              `json:"action"`
+	URL            string              `json:"url,omitempty"`
+	Selector       string              `json:"selector,omitempty"`
+	Candidates     []SelectorCandidate `json:"candidates,omitempty"` // 主选择器未匹配时依次尝试的候选选择器
+	XPath          string              `json:"xpath,omitempty"`
+	Value          string              `json:"value,omitempty"`
+	ImageSelector  string              `json:"image_selector,omitempty"`
+	InputSelector  string              `json:"input_selector,omitempty"`
+	Type           string              `json:"type,omitempty"`
+	Fields         map[string]string   `json:"fields,omitempty"`
+	MultiFields    map[string]string   `json:"multi_fields,omitempty"`
+	WaitTime       int                 `json:"wait_time,omitempty"`
+	WaitForVisible string              `json:"wait_for_visible,omitempty"`
+	MaxItems       int                 `json:"max_items,omitempty"`  // extract 列表最多提取条数（0=不限制）；foreach 最多遍历元素数
+	Pagination     *Pagination         `json:"pagination,omitempty"` // extract 列表翻页
+
+	// 流程控制
+	Var       string          `json:"var,omitempty"`       // foreach 循环变量 / set 目标变量
+	Values    []string        `json:"values,omitempty"`    // foreach 遍历的值列表（支持模板）
+	Condition *TraceCondition `json:"condition,omitempty"` // if 条件
+	Steps     []TraceStep     `json:"steps,omitempty"`     // if 成立时 / foreach 每次执行的子步骤
+	Else      []TraceStep     `json:"else,omitempty"`      // if 不成立时执行的子步骤
+}
+
+// TraceCondition if 步骤的条件，多个条件同时设置时需全部成立
+type TraceCondition struct {
+	Exists       string `json:"exists,omitempty"`        // 元素存在
+	URLMatches   string `json:"url_matches,omitempty"`   // 当前页面 URL 匹配正则
+	TextContains string `json:"text_contains,omitempty"` // 文本包含，范围由 selector 指定（默认整个页面）
+	Selector     string `json:"selector,omitempty"`
+	Var          string `json:"var,omitempty"` // 变量等于 equals
+	Equals       string `json:"equals,omitempty"`
+	Not          bool   `json:"not,omitempty"` // 条件取反
+}
+
+// SelectorCandidate 候选选择器，score 为稳健性评分（见 ScoreSelector）
+type SelectorCandidate struct {
+	Selector string `json:"selector"`
+	Score    int    `json:"score,omitempty"`
+}
+
+// Pagination 列表翻页：提取完当前页后点击下一页按钮继续提取
+type Pagination struct {
+	NextButton string `json:"next_button"`
+	MaxPages   int    `json:"max_pages,omitempty"` // 最多提取页数（含第一页），默认 DefaultMaxPages
+}
+
+// ==================== Chrome 录制格式 ====================
+
+// ChromeRecording Chrome DevTools Recorder 导出的录制
+type ChromeRecording struct {
+	Title string       `json:"title"`
+	URL   string       `json:"url,omitempty"`
+	Steps []ChromeStep `json:"steps"`
+}
+
+// ChromeStep 录制中的一个步骤
+type ChromeStep struct {
+	Type           string          `json:"type"`
+	URL            string          `json:"url,omitempty"`
+	Selectors      [][]string      `json:"selectors,omitempty"`
+	Value          string          `json:"value,omitempty"`   // change 事件的输入值
+	Key            string          `json:"key,omitempty"`     // keyDown/keyUp 的按键
+	Target         string          `json:"target,omitempty"`  // 所在标签页：main 或新标签页的 URL
+	Frame          []int           `json:"frame,omitempty"`   // 所在 iframe 的下标路径
+	OffsetX        float64         `json:"offsetX,omitempty"` // click 在元素内的点击位置
+	OffsetY        float64         `json:"offsetY,omitempty"`
+	X              int             `json:"x,omitempty"` // scroll 滚动到的位置
+	Y              int             `json:"y,omitempty"`
+	AssertedEvents []AssertedEvent `json:"assertedEvents,omitempty"`
+
+	// frameSelectors 按选择器定位的 iframe 路径（导入其他录制格式时使用，Chrome 录制只有下标）
+	frameSelectors []string
+}
+
+// AssertedEvent 步骤触发的事件，navigation 表示该步骤导致了页面跳转
+type AssertedEvent struct {
+	Type  string `json:"type"`
+	URL   string `json:"url,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+// navigates 步骤是否导致页面跳转
+func (s ChromeStep) navigates() bool {
+	for _, e := range s.AssertedEvents {
+		if e.Type == "navigation" {
+			return true
+		}
+	}
+	return false
+}
+
+// framePath 步骤所在 iframe 的路径，为空表示顶层页面。
+// 导入的录制按 iframe 选择器逐层定位；Chrome 录制只有下标，整条路径记为一项 "0.1"（见 IsFrameIndexPath）
+func (s ChromeStep) framePath() []string {
+	if s.frameSelectors != nil {
+		return s.frameSelectors
+	}
+	if len(s.Frame) == 0 {
+		return nil
+	}
+	return []string{FrameIndexPath(s.Frame)}
+}
+
+// FrameIndexPath 把 frame 下标路径格式化为 switch_frame 的 value，如 [0 1] -> "0.1"
+func FrameIndexPath(frame []int) string {
+	parts := make([]string, len(frame))
+	for i, idx := range frame {
+		parts[i] = strconv.Itoa(idx)
+	}
+	return strings.Join(parts, ".")
+}
+
+// ParseFrameIndexPath 解析 switch_frame 的下标路径，格式无效时返回 false
+func ParseFrameIndexPath(value string) ([]int, bool) {
+	if !frameIndexPathPattern.MatchString(value) {
+		return nil, false
+	}
+	var frame []int
+	for _, part := range strings.Split(value, ".") {
+		idx, _ := strconv.Atoi(part)
+		frame = append(frame, idx)
+	}
+	return frame, true
+}
+
+// IsFrameIndexPath value 是否为 frame 下标路径：按 window.frames 的顺序逐层进入子框架，
+// 与 Chrome 录制的 frame 字段一致，不受 iframe 在 DOM 中的位置和嵌套影响
+func IsFrameIndexPath(value string) bool {
+	return frameIndexPathPattern.MatchString(value)
+}
+
+var frameIndexPathPattern = regexp.MustCompile(`^\d+(\.\d+)*$`)
+
+// ParseScrollPosition 解析 scroll 的 "x,y" 滚动位置（来自录制的 x/y）
+func ParseScrollPosition(value string) (int, int, bool) {
+	xs, ys, found := strings.Cut(value, ",")
+	if !found {
+		return 0, 0, false
+	}
+	x, errX := strconv.Atoi(strings.TrimSpace(xs))
+	y, errY := strconv.Atoi(strings.TrimSpace(ys))
+	if errX != nil || errY != nil {
+		return 0, 0, false
+	}
+	return x, y, true
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ==================== URL 规范化 ====================
+
+// defaultStripParams 默认剔除的跟踪/会话参数（支持 * 结尾的前缀匹配）
+var defaultStripParams = []string{
+	"utm_*", "spm", "jsessionid", "phpsessid", "aspsessionid*", "sessionid", "sid", "_t", "_", "timestamp",
+}
+
+// jsURLPattern 从 javascript: 链接中提取引号内的地址
+var jsURLPattern = regexp.MustCompile(`['"]((?:https?://|/|\./|\.\./)[^'"]+|[^'"\s]+\.(?:s?html?|jsp|aspx?|php)(?:\?[^'"]*)?)['"]`)
+
+// sessionPathParam 路径中的会话参数，如 /detail.jsp;jsessionid=xxx
+var sessionPathParam = regexp.MustCompile(`(?i);(jsessionid|sid|phpsessid)=[^/?#]*`)
+
+// resolveURL 将相对地址解析为绝对地址
+func resolveURL(baseURL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || baseURL == "" {
+		return ref
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return ref
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+// parseStripParams 解析逗号分隔的参数列表
+func parseStripParams(s string) []string {
+	var params []string
+	for _, p := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == '，' || r == ' ' || r == '\n'
+	}) {
+		params = append(params, strings.ToLower(p))
+	}
+	return params
+}
+
+// shouldStripParam 判断查询参数是否需要剔除
+func shouldStripParam(name string, strip []string) bool {
+	name = strings.ToLower(name)
+	for _, p := range strip {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(p, "*")) {
+				return true
+			}
+		} else if name == p {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeURL 解析并规范化采集到的链接，无法使用的链接返回空字符串
+//   - 相对地址按 baseURL 解析，javascript: 链接尝试提取其中的地址
+//   - scheme/host 转小写，去掉默认端口和路径中的会话参数
+//   - 剔除默认及 extraStrip 指定的查询参数，其余参数排序
+//   - 保留 SPA 路由片段（#/、#!），丢弃普通锚点
+func normalizeURL(raw, baseURL string, extraStrip []string) string {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(strings.ToLower(raw), "javascript:") {
+		m := jsURLPattern.FindStringSubmatch(raw)
+		if m == nil {
+			return ""
+		}
+		raw = m[1]
+	}
+	if raw == "" || strings.HasPrefix(raw, "#") && !isRouteFragment(raw[1:]) {
+		return ""
+	}
+
+	u, err := url.Parse(resolveURL(baseURL, raw))
+	if err != nil {
+		return ""
+	}
+	if u.Scheme == "" && u.Host == "" {
+		// 无法解析为绝对地址，原样返回
+		return raw
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	u.Host = host
+	u.User = nil
+
+	u.Path = sessionPathParam.ReplaceAllString(u.Path, "")
+	u.RawPath = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	strip := append(append([]string{}, defaultStripParams...), extraStrip...)
+	query := u.Query()
+	for name := range query {
+		if shouldStripParam(name, strip) {
+			query.Del(name)
+		}
+	}
+	u.RawQuery = query.Encode() // Encode 按参数名排序
+
+	if !isRouteFragment(u.Fragment) {
+		u.Fragment = ""
+	}
+
+	return u.String()
+}
+
+// isRouteFragment 判断锚点是否为 SPA 路由
+func isRouteFragment(fragment string) bool {
+	return strings.HasPrefix(fragment, "/") || strings.HasPrefix(fragment, "!")
+}
+
+// canonicalURLKey 计算用于去重的规范化键：忽略 scheme、结尾斜杠和参数顺序
+func canonicalURLKey(raw string, extraStrip []string) string {
+	normalized := normalizeURL(raw, "", extraStrip)
+	if normalized == "" {
+		return strings.TrimSpace(raw)
+	}
+	u, err := url.Parse(normalized)
+	if err != nil || u.Host == "" {
+		return normalized
+	}
+
+	p := u.Path
+	if p != "/" {
+		p = strings.TrimSuffix(path.Clean(p), "/")
+	}
+	key := u.Host + p
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	if u.Fragment != "" {
+		// 路由片段中的参数同样排序，避免顺序不同产生重复
+		frag := u.Fragment
+		if idx := strings.Index(frag, "?"); idx >= 0 {
+			parts := strings.Split(frag[idx+1:], "&")
+			sort.Strings(parts)
+			frag = frag[:idx+1] + strings.Join(parts, "&")
+		}
+		key += "#" + frag
+	}
+	return key
+}
+
+// sourceStripParams 获取采集源配置的额外剔除参数
+func sourceStripParams(sourceID int) []string {
+	if sourceID <= 0 || db == nil {
+		return nil
+	}
+	var stripParams string
+	db.QueryRow("SELECT COALESCE(strip_params, '') FROM sources WHERE id = ?", sourceID).Scan(&stripParams)
+	return parseStripParams(stripParams)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// setupTestDB 在临时目录中初始化数据库，测试结束后关闭
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	saved := dataDir
+	dataDir = t.TempDir()
+	if err := initDB(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		dataDir = saved
+	})
+}
+
+// TestSaveTenderConcurrentSameURL 并发保存同一条目（URL 只有跟踪参数不同）只产生一条记录
+func TestSaveTenderConcurrentSameURL(t *testing.T) {
+	setupTestDB(t)
+	db.SetMaxOpenConns(1) // 串行执行语句，使查询与插入在多个保存之间交错
+
+	urls := []string{
+		"https://example.com/notice?id=42",
+		"https://example.com/notice?id=42&utm_source=feed",
+		"http://example.com/notice?id=42",
+	}
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, 30)
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			_, err := saveTender(&Tender{Title: "视频监控采购", URL: urls[i%len(urls)], Keywords: "视频监控", Status: "active"})
+			errs <- err
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("保存失败: %v", err)
+		}
+	}
+
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM tenders").Scan(&n)
+	if n != 1 {
+		t.Errorf("记录数 = %d, 期望 1", n)
+	}
+}
+
+// TestEnsureUniqueTenderURLKeys 建唯一索引前已存在的重复记录保留，后出现的改为不参与去重的占位键
+func TestEnsureUniqueTenderURLKeys(t *testing.T) {
+	setupTestDB(t)
+	db.Exec("DROP INDEX idx_url_key_unique")
+	for i, key := range []string{"example.com/a", "example.com/a", "example.com/b", "nokey:9", "nokey:9"} {
+		if _, err := db.Exec("INSERT INTO tenders (title, url, url_key) VALUES ('t', ?, ?)", fmt.Sprintf("u%d", i), key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ensureUniqueTenderURLKeys()
+
+	rows, err := db.Query("SELECT id, url_key FROM tenders ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var id int
+		var key string
+		rows.Scan(&id, &key)
+		got = append(got, key)
+	}
+	want := []string{"example.com/a", "nokey:2", "example.com/b", "nokey:9", "nokey:9"}
+	if len(got) != len(want) {
+		t.Fatalf("url_key = %v, 期望 %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("url_key = %v, 期望 %v", got, want)
+			break
+		}
+	}
+	if _, err := db.Exec("INSERT INTO tenders (title, url, url_key) VALUES ('t', 'u9', 'example.com/b')"); !isUniqueConstraintError(err) {
+		t.Errorf("重复的去重键应被唯一索引拒绝，实际错误: %v", err)
+	}
+}